@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"labdb.org/labdb/auth"
+	"labdb.org/labdb/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultAuditEntries = 100
+
+// adminUser is a user as admins see them.
+type adminUser struct {
+	ID            uint        `json:"id"`
+	Email         string      `json:"email"`
+	Name          string      `json:"name"`
+	Read          bool        `json:"read"`
+	Write         bool        `json:"write"`
+	Admin         bool        `json:"admin"`
+	Role          string      `json:"role"`
+	EffectiveRole models.Role `json:"effectiveRole"`
+	Deactivated   bool        `json:"deactivated"`
+	// Invited users haven't signed in yet.
+	Invited     bool       `json:"invited"`
+	LastLoginAt *time.Time `json:"lastLoginAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+func asAdminUser(u models.User) adminUser {
+	return adminUser{
+		ID:            u.ID,
+		Email:         u.Email,
+		Name:          u.Name,
+		Read:          u.AuthRead,
+		Write:         u.AuthWrite,
+		Admin:         u.AuthAdmin,
+		Role:          u.Role,
+		EffectiveRole: u.EffectiveRole(),
+		Deactivated:   u.Deactivated,
+		Invited:       u.LastLoginAt == nil,
+		LastLoginAt:   u.LastLoginAt,
+		CreatedAt:     u.CreatedAt,
+	}
+}
+
+type invitation struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+	Read  bool   `json:"read"`
+	Write bool   `json:"write"`
+	Admin bool   `json:"admin"`
+	Role  string `json:"role"`
+}
+
+// userChange is a change to a user. Fields left out stay as they are.
+type userChange struct {
+	Name        *string `json:"name"`
+	Read        *bool   `json:"read"`
+	Write       *bool   `json:"write"`
+	Admin       *bool   `json:"admin"`
+	Role        *string `json:"role"`
+	Deactivated *bool   `json:"deactivated"`
+}
+
+func (ch userChange) apply(u *models.User) {
+	if ch.Name != nil {
+		u.Name = strings.TrimSpace(*ch.Name)
+	}
+	if ch.Read != nil {
+		u.AuthRead = *ch.Read
+	}
+	if ch.Write != nil {
+		u.AuthWrite = *ch.Write
+	}
+	if ch.Admin != nil {
+		u.AuthAdmin = *ch.Admin
+	}
+	if ch.Role != nil {
+		u.Role = *ch.Role
+	}
+	if ch.Deactivated != nil {
+		u.Deactivated = *ch.Deactivated
+	}
+}
+
+// requireAdmin only lets admins through.
+func requireAdmin(s *models.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, err := auth.CurrentPermissions(c, s)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		if !p.IsAdmin() {
+			c.String(403, "Forbidden")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// admin returns the signed in admin making a change.
+func admin(c *gin.Context, s *models.Store) (models.User, bool) {
+	p, err := auth.CurrentPermissions(c, s)
+	if err != nil {
+		c.AbortWithError(500, err)
+		return models.User{}, false
+	}
+	return p.User, true
+}
+
+// userParam looks up the user named by the :id param, writing an error
+// response and returning false if there isn't one.
+func userParam(c *gin.Context, s *models.Store) (models.User, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.String(400, "Bad ID")
+		return models.User{}, false
+	}
+	u, err := s.UserByID(c.Request.Context(), uint(id))
+	if err == models.ErrNotFound {
+		c.String(404, "Not found.")
+		return models.User{}, false
+	}
+	if err != nil {
+		c.AbortWithError(500, err)
+		return models.User{}, false
+	}
+	return u, true
+}
+
+func adminAPI(r *gin.Engine, s *models.Store) {
+	api := r.Group("/api/v1/admin")
+	api.Use(requireAdmin(s))
+
+	api.GET("/users", func(c *gin.Context) {
+		us, err := s.Users(c.Request.Context())
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		result := []adminUser{}
+		for _, u := range us {
+			result = append(result, asAdminUser(u))
+		}
+		c.JSON(200, result)
+	})
+
+	api.GET("/users/:id", func(c *gin.Context) {
+		u, ok := userParam(c, s)
+		if !ok {
+			return
+		}
+		gs, err := s.Grants(c.Request.Context(), u)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		grants := map[string]models.Access{}
+		for _, g := range gs {
+			grants[g.ItemKind] = g.Access
+		}
+		c.JSON(200, gin.H{"user": asAdminUser(u), "grants": grants})
+	})
+
+	// Invites someone by email address, adding their user so that they have
+	// the given access when they first sign in.
+	api.POST("/users", func(c *gin.Context) {
+		actor, ok := admin(c, s)
+		if !ok {
+			return
+		}
+		inv := invitation{}
+		if err := json.NewDecoder(c.Request.Body).Decode(&inv); err != nil {
+			c.String(400, "Bad invitation: %s", err.Error())
+			return
+		}
+		inv.Email = strings.TrimSpace(inv.Email)
+		if !strings.Contains(inv.Email, "@") {
+			c.String(400, "Bad email address")
+			return
+		}
+		if inv.Role != "" && !models.Role(inv.Role).Valid() {
+			c.String(400, "Unknown role %s", inv.Role)
+			return
+		}
+		u := &models.User{
+			Email:     inv.Email,
+			Name:      strings.TrimSpace(inv.Name),
+			AuthRead:  inv.Read,
+			AuthWrite: inv.Write,
+			AuthAdmin: inv.Admin,
+			Role:      inv.Role,
+		}
+		err := s.InviteUser(c.Request.Context(), actor.Email, u)
+		if err == models.ErrExists {
+			c.String(409, "%s already has a user", inv.Email)
+			return
+		}
+		if err == models.ErrNeedsName {
+			c.String(400, "Only viewers can be invited without a name")
+			return
+		}
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		c.JSON(201, asAdminUser(*u))
+	})
+
+	// Changes a user's access, or deactivates them.
+	api.PATCH("/users/:id", func(c *gin.Context) {
+		actor, ok := admin(c, s)
+		if !ok {
+			return
+		}
+		before, ok := userParam(c, s)
+		if !ok {
+			return
+		}
+		ch := userChange{}
+		if err := json.NewDecoder(c.Request.Body).Decode(&ch); err != nil {
+			c.String(400, "Bad change: %s", err.Error())
+			return
+		}
+		u := before
+		ch.apply(&u)
+		if u.Role != "" && !models.Role(u.Role).Valid() {
+			c.String(400, "Unknown role %s", u.Role)
+			return
+		}
+		if u.ID == actor.ID && !(models.Permissions{User: u, Role: u.EffectiveRole()}).IsAdmin() {
+			c.String(400, "You can't remove your own admin access")
+			return
+		}
+		err := s.UpdateUser(c.Request.Context(), actor.Email, before, &u)
+		if err == models.ErrNeedsName {
+			c.String(400, "%s needs a name to add entries", u.Email)
+			return
+		}
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		c.JSON(200, asAdminUser(u))
+	})
+
+	// Sets a user's access to particular kinds of model, overriding their
+	// role. Kinds given "" go back to the role's access.
+	api.PUT("/users/:id/grants", func(c *gin.Context) {
+		actor, ok := admin(c, s)
+		if !ok {
+			return
+		}
+		u, ok := userParam(c, s)
+		if !ok {
+			return
+		}
+		grants := map[string]models.Access{}
+		if err := json.NewDecoder(c.Request.Body).Decode(&grants); err != nil {
+			c.String(400, "Bad grants: %s", err.Error())
+			return
+		}
+		for kind, a := range grants {
+			if _, ok := models.KnownKind(kind); !ok {
+				c.String(400, "Unknown kind %s", kind)
+				return
+			}
+			if a != "" && !a.Valid() {
+				c.String(400, "Unknown access %s", a)
+				return
+			}
+			if a.AtLeast(models.AccessOwn) && strings.TrimSpace(u.Name) == "" {
+				c.String(400, "%s needs a name to add entries", u.Email)
+				return
+			}
+		}
+		for kind, a := range grants {
+			if err := s.SetGrant(c.Request.Context(), actor.Email, u, kind, a); err != nil {
+				c.AbortWithError(500, err)
+				return
+			}
+		}
+		c.Status(204)
+	})
+
+	api.GET("/audit", func(c *gin.Context) {
+		userID, err := strconv.ParseUint(c.DefaultQuery("user", "0"), 10, 32)
+		if err != nil {
+			c.String(400, "Bad user")
+			return
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultAuditEntries)))
+		if err != nil || limit <= 0 || limit > maxPageSize {
+			c.String(400, "Bad limit")
+			return
+		}
+		entries, err := s.AuditLog(c.Request.Context(), uint(userID), limit)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		c.JSON(200, entries)
+	})
+}
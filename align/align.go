@@ -0,0 +1,204 @@
+// Package align does pairwise alignment of DNA sequences.
+package align
+
+import (
+	"labdb.org/labdb/sequence"
+)
+
+// Scoring is an affine gap scoring scheme: a gap of k bases scores
+// GapOpen + k*GapExtend.
+type Scoring struct {
+	Match     int
+	Mismatch  int
+	GapOpen   int
+	GapExtend int
+}
+
+// DefaultScoring suits aligning reads to a reference they should match
+// closely.
+var DefaultScoring = Scoring{Match: 2, Mismatch: -3, GapOpen: -4, GapExtend: -2}
+
+func (sc Scoring) score(a, b byte) int {
+	if a == b && isBase(a) {
+		return sc.Match
+	}
+	if !isBase(a) || !isBase(b) {
+		// N and other ambiguity codes are neither rewarded nor penalized
+		// unless they rule the other base out.
+		if sequence.Compatible(a, b) {
+			return 0
+		}
+	}
+	return sc.Mismatch
+}
+
+func isBase(b byte) bool {
+	return b == 'A' || b == 'C' || b == 'G' || b == 'T'
+}
+
+// Alignment is an alignment of part of A to part of B. A and B hold the
+// aligned bases, with - for gaps, and are the same length. AStart:AEnd and
+// BStart:BEnd are the aligned regions of the original sequences.
+type Alignment struct {
+	A      string
+	B      string
+	AStart int
+	AEnd   int
+	BStart int
+	BEnd   int
+	Score  int
+}
+
+// The alignment of a pair of prefixes ends in one of three states: with a
+// and b aligned (m), a gap in b (x) or a gap in a (y). Tracing back, each
+// cell records which state led to m, and whether x and y were extended
+// rather than opened.
+const (
+	fromStop byte = iota
+	fromM
+	fromX
+	fromY
+
+	stateBits        = 3
+	xExtended   byte = 1 << 2
+	yExtended   byte = 1 << 3
+	negInfinity      = -1 << 30
+)
+
+// Local finds the best alignment of any part of a with any part of b
+// (Smith-Waterman). Both should be normalized.
+func Local(a, b string, sc Scoring) Alignment {
+	return run(a, b, sc, true)
+}
+
+// Global aligns all of a with all of b (Needleman-Wunsch). Both should be
+// normalized.
+func Global(a, b string, sc Scoring) Alignment {
+	return run(a, b, sc, false)
+}
+
+func run(a, b string, sc Scoring, local bool) Alignment {
+	cols := len(b) + 1
+	trace := make([]byte, (len(a)+1)*cols)
+	row := func() []int {
+		r := make([]int, cols)
+		for j := range r {
+			r[j] = negInfinity
+		}
+		return r
+	}
+	prevM, prevX, prevY := row(), row(), row()
+	currM, currX, currY := row(), row(), row()
+	prevM[0] = 0
+	if !local {
+		for j := 1; j < cols; j++ {
+			prevY[j] = sc.GapOpen + j*sc.GapExtend
+			if j > 1 {
+				trace[j] = yExtended
+			}
+		}
+	}
+
+	best, bestI, bestJ, bestState := 0, 0, 0, fromStop
+	for i := 1; i <= len(a); i++ {
+		currM[0], currX[0], currY[0] = negInfinity, negInfinity, negInfinity
+		if !local {
+			currX[0] = sc.GapOpen + i*sc.GapExtend
+			if i > 1 {
+				trace[i*cols] = xExtended
+			}
+		}
+		for j := 1; j < cols; j++ {
+			var t byte
+			from, src := prevM[j-1], fromM
+			if prevX[j-1] > from {
+				from, src = prevX[j-1], fromX
+			}
+			if prevY[j-1] > from {
+				from, src = prevY[j-1], fromY
+			}
+			if local && from <= 0 {
+				from, src = 0, fromStop
+			}
+			currM[j] = from + sc.score(a[i-1], b[j-1])
+			t = src
+
+			currX[j] = prevM[j] + sc.GapOpen + sc.GapExtend
+			if s := prevX[j] + sc.GapExtend; s > currX[j] {
+				currX[j] = s
+				t |= xExtended
+			}
+			currY[j] = currM[j-1] + sc.GapOpen + sc.GapExtend
+			if s := currY[j-1] + sc.GapExtend; s > currY[j] {
+				currY[j] = s
+				t |= yExtended
+			}
+			trace[i*cols+j] = t
+			if local && currM[j] > best {
+				best, bestI, bestJ, bestState = currM[j], i, j, fromM
+			}
+		}
+		prevM, currM = currM, prevM
+		prevX, currX = currX, prevX
+		prevY, currY = currY, prevY
+	}
+	if !local {
+		bestI, bestJ = len(a), len(b)
+		best, bestState = prevM[len(b)], fromM
+		if prevX[len(b)] > best {
+			best, bestState = prevX[len(b)], fromX
+		}
+		if prevY[len(b)] > best {
+			best, bestState = prevY[len(b)], fromY
+		}
+		if len(a) == 0 && len(b) == 0 {
+			best, bestState = 0, fromStop
+		}
+	}
+
+	alnA := []byte{}
+	alnB := []byte{}
+	i, j, state := bestI, bestJ, bestState
+	for state != fromStop && (i > 0 || j > 0) {
+		t := trace[i*cols+j]
+		switch state {
+		case fromM:
+			state = t & stateBits
+			i--
+			j--
+			alnA = append(alnA, a[i])
+			alnB = append(alnB, b[j])
+		case fromX:
+			if t&xExtended == 0 {
+				state = fromM
+			}
+			i--
+			alnA = append(alnA, a[i])
+			alnB = append(alnB, '-')
+		case fromY:
+			if t&yExtended == 0 {
+				state = fromM
+			}
+			j--
+			alnA = append(alnA, '-')
+			alnB = append(alnB, b[j])
+		}
+	}
+	reverse(alnA)
+	reverse(alnB)
+	return Alignment{
+		A:      string(alnA),
+		B:      string(alnB),
+		AStart: i,
+		AEnd:   bestI,
+		BStart: j,
+		BEnd:   bestJ,
+		Score:  best,
+	}
+}
+
+func reverse(s []byte) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
@@ -0,0 +1,95 @@
+package align
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func randomSeq(r *rand.Rand, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = "ACGT"[r.Intn(4)]
+	}
+	return string(b)
+}
+
+// other returns a base that is neither a nor b.
+func other(a, b byte) string {
+	for _, c := range []byte("ACGT") {
+		if c != a && c != b {
+			return string(c)
+		}
+	}
+	return ""
+}
+
+func ungapped(s string) string {
+	return strings.Replace(s, "-", "", -1)
+}
+
+func TestLocal(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	// The patch around 100 leaves only one place for each gap.
+	ref := randomSeq(r, 98) + "AACCGG" + randomSeq(r, 96)
+	mismatched := ref[50:80] + other(ref[80], ref[80]) + ref[81:150]
+
+	tests := []struct {
+		name   string
+		read   string
+		a, b   string
+		aStart int
+		aEnd   int
+		score  int
+	}{
+		{"exact", ref[50:150], ref[50:150], ref[50:150], 50, 150, 200},
+		{"mismatch", mismatched, ref[50:150], mismatched, 50, 150, 99*2 - 3},
+		// T goes between the Cs and Gs.
+		{"insertion", ref[50:102] + "T" + ref[102:150], ref[50:102] + "-" + ref[102:150], ref[50:102] + "T" + ref[102:150], 50, 150, 100*2 - 4 - 2},
+		// The Cs are deleted.
+		{"deletion", ref[50:100] + ref[102:150], ref[50:150], ref[50:100] + "--" + ref[102:150], 50, 150, 98*2 - 4 - 2*2},
+		// Unrelated flanks aren't aligned.
+		{"flanks", strings.Repeat(other(ref[119], ref[119]), 10) + ref[120:180] + strings.Repeat(other(ref[180], ref[180]), 10), ref[120:180], ref[120:180], 120, 180, 120},
+	}
+	for _, test := range tests {
+		aln := Local(ref, test.read, DefaultScoring)
+		if aln.A != test.a || aln.B != test.b {
+			t.Errorf("%s: aligned\n%s\n%s\nwant\n%s\n%s", test.name, aln.A, aln.B, test.a, test.b)
+		}
+		if aln.AStart != test.aStart || aln.AEnd != test.aEnd || aln.Score != test.score {
+			t.Errorf("%s: aligned %d-%d scoring %d, want %d-%d scoring %d", test.name, aln.AStart, aln.AEnd, aln.Score, test.aStart, test.aEnd, test.score)
+		}
+		if ungapped(aln.A) != ref[aln.AStart:aln.AEnd] || ungapped(aln.B) != test.read[aln.BStart:aln.BEnd] {
+			t.Errorf("%s: alignment doesn't match its bounds", test.name)
+		}
+	}
+}
+
+func TestGlobal(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		alnA  string
+		alnB  string
+		score int
+	}{
+		{"", "", "", "", 0},
+		{"ACGT", "", "ACGT", "----", -4 - 4*2},
+		{"GATTACA", "GATTACA", "GATTACA", "GATTACA", 14},
+		{"GATTACA", "GACTACA", "GATTACA", "GACTACA", 12 - 3},
+		{"GATTACA", "GATGTACA", "GAT-TACA", "GATGTACA", 14 - 6},
+		{"GCCCATG", "GATG", "GCCCATG", "G---ATG", 8 - 4 - 6},
+		// N is neither a match nor a mismatch.
+		{"GATTACA", "GANTACA", "GATTACA", "GANTACA", 12},
+		// End gaps count, unlike in Local.
+		{"CCCCGATTACA", "GATTACA", "CCCCGATTACA", "----GATTACA", 14 - 4 - 8},
+	}
+	for _, test := range tests {
+		aln := Global(test.a, test.b, DefaultScoring)
+		if aln.A != test.alnA || aln.B != test.alnB || aln.Score != test.score {
+			t.Errorf("Global(%s, %s) =\n%s\n%s\nscoring %d, want\n%s\n%s\nscoring %d", test.a, test.b, aln.A, aln.B, aln.Score, test.alnA, test.alnB, test.score)
+		}
+		if aln.AStart != 0 || aln.AEnd != len(test.a) || aln.BStart != 0 || aln.BEnd != len(test.b) {
+			t.Errorf("Global(%s, %s) covers %d-%d and %d-%d", test.a, test.b, aln.AStart, aln.AEnd, aln.BStart, aln.BEnd)
+		}
+	}
+}
@@ -4,12 +4,8 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
-	"net/url"
 	"strings"
 	"time"
 
@@ -20,14 +16,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// appID is the Google client ID used unless GOOGLE_CLIENT_ID is set.
 var appID = "146923434465-alq7iagpanjvoag20smuirj0ivdtfldk.apps.googleusercontent.com"
 
-type authResponse struct {
-	Aud           string `json:"aud"`
-	EmailVerified string `json:"email_verified"`
-	Email         string `json:"email"`
-}
-
 func AddAuthHeaders(userID string, h http.Header) {
 	ts := time.Now().UTC().Format("2006-01-02T15:04:05")
 	mac := hmac.New(sha256.New, []byte(env.SigningKey))
@@ -40,51 +31,52 @@ func AddAuthHeaders(userID string, h http.Header) {
 	h.Add("X-LabDB-Signature-Timestamp", ts)
 }
 
-func GetVerifiedIdentity(token string) string {
-	params := url.Values{}
-	params.Set("id_token", token)
-	url := url.URL{
-		Scheme:   "https",
-		Host:     "www.googleapis.com",
-		Path:     "/oauth2/v3/tokeninfo",
-		RawQuery: params.Encode(),
-	}
-	fmt.Println(url.String())
-	resp, err := http.Post(url.String(), "text/plain", strings.NewReader(""))
-	if err != nil || resp.StatusCode != 200 {
-		fmt.Printf("Got error %+v, %+v\n", err, resp.StatusCode)
-		defer resp.Body.Close()
-		body, err := ioutil.ReadAll(resp.Body)
+// Keys under which APITokens records a token's user on the request.
+const (
+	tokenUserIDKey = "labdb.tokenUserID"
+	tokenKey       = "labdb.apiToken"
+	permissionsKey = "labdb.permissions"
+)
+
+// APITokens authenticates requests carrying a personal API token as an
+// "Authorization: Bearer" header, rejecting unknown or expired tokens.
+// Requests without one fall through to the session.
+func APITokens(s *models.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h := c.GetHeader("Authorization")
+		if !strings.HasPrefix(h, "Bearer ") {
+			c.Next()
+			return
+		}
+		u, t, err := s.UserByAPIToken(c.Request.Context(), strings.TrimSpace(h[len("Bearer "):]))
+		if err == models.ErrNotFound || err == models.ErrExpired {
+			c.String(401, "Invalid token")
+			c.Abort()
+			return
+		}
 		if err != nil {
-			panic(err)
+			c.AbortWithError(500, err)
+			return
 		}
-		fmt.Printf("Body: %+v\n", string(body))
-
-		return ""
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		panic(err)
-	}
-	var authResp authResponse
-	if env.Dev {
-		fmt.Printf("Body: %+v\n", string(body))
-	}
-	err = json.Unmarshal(body, &authResp)
-	if env.Dev {
-		fmt.Printf("Auth resp: %+v\n", authResp)
-	}
-	if err != nil {
-		panic(err)
+		c.Set(tokenUserIDKey, u.Email)
+		c.Set(tokenKey, &t)
+		c.Next()
 	}
-	if strings.Contains(authResp.Aud, appID) && authResp.EmailVerified == "true" {
-		return authResp.Email
+}
+
+// CurrentAPIToken returns the API token the request was authenticated with,
+// or nil if it wasn't.
+func CurrentAPIToken(c *gin.Context) *models.APIToken {
+	if t, ok := c.Get(tokenKey); ok {
+		return t.(*models.APIToken)
 	}
-	return ""
+	return nil
 }
 
 func CurrentUserID(c *gin.Context) string {
+	if id, ok := c.Get(tokenUserIDKey); ok {
+		return id.(string)
+	}
 	session := sessions.Default(c)
 	maybeID := session.Get("userID")
 	if maybeID != nil {
@@ -93,7 +85,34 @@ func CurrentUserID(c *gin.Context) string {
 	return ""
 }
 
-func CurrentUser(c *gin.Context) models.User {
+// CurrentUser looks up the signed in user, returning models.ErrNotFound if
+// there isn't one.
+func CurrentUser(c *gin.Context, s *models.Store) (models.User, error) {
 	uid := CurrentUserID(c)
-	return models.UserByEmail(uid)
+	if uid == "" {
+		return models.User{}, models.ErrNotFound
+	}
+	return s.UserByEmail(c.Request.Context(), uid)
+}
+
+// CurrentPermissions looks up what the signed in user may do, limited to
+// reading if they're using a read-only API token. Someone not signed in gets
+// no access.
+func CurrentPermissions(c *gin.Context, s *models.Store) (models.Permissions, error) {
+	if p, ok := c.Get(permissionsKey); ok {
+		return p.(models.Permissions), nil
+	}
+	u, err := CurrentUser(c, s)
+	if err != nil && err != models.ErrNotFound {
+		return models.Permissions{}, err
+	}
+	p, err := s.Permissions(c.Request.Context(), u)
+	if err != nil {
+		return models.Permissions{}, err
+	}
+	if t := CurrentAPIToken(c); t != nil && t.ReadOnly {
+		p.ReadOnly = true
+	}
+	c.Set(permissionsKey, p)
+	return p, nil
 }
@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strings"
+)
+
+// xmlNode is an element of a parsed XML document, kept with its namespace
+// prefixes and declarations as written so that it can be canonicalized.
+type xmlNode struct {
+	Prefix string
+	Local  string
+	// Attrs are as written, including namespace declarations (prefix
+	// "xmlns", or name "xmlns" for the default namespace).
+	Attrs    []xml.Attr
+	Children []interface{} // *xmlNode or string
+	Parent   *xmlNode
+}
+
+// parseXML parses a document into a tree of xmlNodes, dropping comments and
+// processing instructions.
+func parseXML(data []byte) (*xmlNode, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var root, current *xmlNode
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{Prefix: t.Name.Space, Local: t.Name.Local, Attrs: t.Copy().Attr, Parent: current}
+			if current == nil {
+				if root != nil {
+					return nil, errXML("more than one root element")
+				}
+				root = n
+			} else {
+				current.Children = append(current.Children, n)
+			}
+			current = n
+		case xml.EndElement:
+			if current == nil || t.Name.Space != current.Prefix || t.Name.Local != current.Local {
+				return nil, errXML("mismatched end element")
+			}
+			current = current.Parent
+		case xml.CharData:
+			if current != nil {
+				current.Children = append(current.Children, string(t))
+			}
+		case xml.Directive:
+			// DTDs could define entities or default attributes we wouldn't
+			// account for.
+			return nil, errXML("DTDs aren't allowed")
+		}
+	}
+	if root == nil || current != nil {
+		return nil, errXML("incomplete document")
+	}
+	return root, nil
+}
+
+type errXML string
+
+func (e errXML) Error() string { return "bad XML: " + string(e) }
+
+// namespace returns the URI prefix is bound to at n.
+func (n *xmlNode) namespace(prefix string) string {
+	if prefix == "xml" {
+		return "http://www.w3.org/XML/1998/namespace"
+	}
+	for e := n; e != nil; e = e.Parent {
+		for _, a := range e.Attrs {
+			if (prefix == "" && a.Name.Space == "" && a.Name.Local == "xmlns") || (a.Name.Space == "xmlns" && a.Name.Local == prefix) {
+				return a.Value
+			}
+		}
+	}
+	return ""
+}
+
+// is reports whether n is the element local in namespace ns.
+func (n *xmlNode) is(ns, local string) bool {
+	return n.Local == local && n.namespace(n.Prefix) == ns
+}
+
+// attr returns the value of n's unprefixed attribute name.
+func (n *xmlNode) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Space == "" && a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// children returns n's child elements named local in namespace ns.
+func (n *xmlNode) children(ns, local string) []*xmlNode {
+	found := []*xmlNode{}
+	for _, c := range n.Children {
+		if e, ok := c.(*xmlNode); ok && e.is(ns, local) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// child returns n's only child element named local in namespace ns, or nil
+// if there isn't exactly one.
+func (n *xmlNode) child(ns, local string) *xmlNode {
+	found := n.children(ns, local)
+	if len(found) != 1 {
+		return nil
+	}
+	return found[0]
+}
+
+// text returns the character data directly inside n.
+func (n *xmlNode) text() string {
+	var b strings.Builder
+	for _, c := range n.Children {
+		if s, ok := c.(string); ok {
+			b.WriteString(s)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func isNamespaceDecl(a xml.Attr) bool {
+	return a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns")
+}
+
+// canonicalize writes n in exclusive XML canonical form without comments
+// (http://www.w3.org/2001/10/xml-exc-c14n#), leaving out exclude if it's a
+// descendant (for the enveloped signature transform). Namespaces with
+// prefixes in inclusive are treated as in inclusive canonicalization.
+func canonicalize(n *xmlNode, exclude *xmlNode, inclusive []string) []byte {
+	var buf bytes.Buffer
+	writeCanonical(&buf, n, exclude, inclusive, map[string]string{"": ""})
+	return buf.Bytes()
+}
+
+func writeCanonical(buf *bytes.Buffer, n *xmlNode, exclude *xmlNode, inclusive []string, rendered map[string]string) {
+	// The namespaces this element needs declared: those its name and
+	// attributes use, and any in scope listed as inclusive.
+	used := map[string]bool{n.Prefix: true}
+	for _, a := range n.Attrs {
+		if !isNamespaceDecl(a) && a.Name.Space != "" && a.Name.Space != "xml" {
+			used[a.Name.Space] = true
+		}
+	}
+	for _, p := range inclusive {
+		if p == "#default" {
+			p = ""
+		}
+		if n.namespace(p) != "" {
+			used[p] = true
+		}
+	}
+	prefixes := []string{}
+	for p := range used {
+		if n.namespace(p) != rendered[p] {
+			prefixes = append(prefixes, p)
+		}
+	}
+	sort.Strings(prefixes)
+	inScope := map[string]string{}
+	for p, uri := range rendered {
+		inScope[p] = uri
+	}
+
+	name := n.Local
+	if n.Prefix != "" {
+		name = n.Prefix + ":" + n.Local
+	}
+	buf.WriteString("<" + name)
+	for _, p := range prefixes {
+		uri := n.namespace(p)
+		inScope[p] = uri
+		if p == "" {
+			buf.WriteString(` xmlns="`)
+		} else {
+			buf.WriteString(" xmlns:" + p + `="`)
+		}
+		buf.WriteString(escapeAttr(uri) + `"`)
+	}
+	attrs := []xml.Attr{}
+	for _, a := range n.Attrs {
+		if !isNamespaceDecl(a) {
+			attrs = append(attrs, a)
+		}
+	}
+	// Attributes are ordered by namespace URI, then local name, with
+	// unqualified ones first.
+	sort.SliceStable(attrs, func(i, j int) bool {
+		nsI, nsJ := "", ""
+		if attrs[i].Name.Space != "" {
+			nsI = n.namespace(attrs[i].Name.Space)
+		}
+		if attrs[j].Name.Space != "" {
+			nsJ = n.namespace(attrs[j].Name.Space)
+		}
+		if nsI != nsJ {
+			return nsI < nsJ
+		}
+		return attrs[i].Name.Local < attrs[j].Name.Local
+	})
+	for _, a := range attrs {
+		attrName := a.Name.Local
+		if a.Name.Space != "" {
+			attrName = a.Name.Space + ":" + a.Name.Local
+		}
+		buf.WriteString(" " + attrName + `="` + escapeAttr(a.Value) + `"`)
+	}
+	buf.WriteString(">")
+	for _, c := range n.Children {
+		switch c := c.(type) {
+		case string:
+			buf.WriteString(escapeText(c))
+		case *xmlNode:
+			if c != exclude {
+				writeCanonical(buf, c, exclude, inclusive, inScope)
+			}
+		}
+	}
+	buf.WriteString("</" + name + ">")
+}
+
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+var attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+
+func escapeText(s string) string { return textEscaper.Replace(s) }
+func escapeAttr(s string) string { return attrEscaper.Replace(s) }
@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// KeySource looks up the public keys ID tokens are signed with by key ID.
+type KeySource interface {
+	Key(kid string) (*rsa.PublicKey, error)
+}
+
+// StaticKeys is a fixed set of keys, e.g. a local keypair for tests.
+type StaticKeys map[string]*rsa.PublicKey
+
+func (k StaticKeys) Key(kid string) (*rsa.PublicKey, error) {
+	key, ok := k[kid]
+	if !ok {
+		return nil, fmt.Errorf("no key with ID %q", kid)
+	}
+	return key, nil
+}
+
+// GoogleCertsURL is where Google publishes the keys it signs ID tokens with.
+const GoogleCertsURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+// JWKS fetches keys from a JSON Web Key Set, keeping them for as long as the
+// response's Cache-Control max-age allows.
+type JWKS struct {
+	URL    string
+	Client *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	expires time.Time
+}
+
+// refreshBackoff is how long old keys are kept after a failed refresh before
+// trying again.
+const refreshBackoff = time.Minute
+
+func NewJWKS(url string) *JWKS {
+	return &JWKS{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (j *JWKS) Key(kid string) (*rsa.PublicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.keys == nil || time.Now().After(j.expires) {
+		if err := j.refresh(); err != nil {
+			if j.keys == nil {
+				return nil, err
+			}
+			// Better to carry on with the old keys than lock everyone out.
+			// Wait a while before trying again, rather than holding every
+			// request up on a server that's down.
+			log.Printf("Couldn't refresh keys from %s: %v\n", j.URL, err)
+			j.expires = time.Now().Add(refreshBackoff)
+		}
+	}
+	key, ok := j.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("no key with ID %q", kid)
+	}
+	return key, nil
+}
+
+var maxAgeRegexp = regexp.MustCompile(`max-age=(\d+)`)
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// refresh fetches the key set. j.mu must be held.
+func (j *JWKS) refresh() error {
+	resp, err := j.Client.Get(j.URL)
+	if err != nil {
+		return fmt.Errorf("fetching keys: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("fetching keys: %s", resp.Status)
+	}
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("fetching keys: %v", err)
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Alg != "" && k.Alg != "RS256") {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return fmt.Errorf("key %q: %v", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	maxAge := 0
+	if m := maxAgeRegexp.FindStringSubmatch(resp.Header.Get("Cache-Control")); m != nil {
+		maxAge, _ = strconv.Atoi(m[1])
+	}
+	j.keys = keys
+	j.expires = time.Now().Add(time.Duration(maxAge) * time.Second)
+	return nil
+}
+
+func (k jwk) publicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("bad exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestJWKSBackoff(t *testing.T) {
+	m := newTestIdP(t)
+	requests, failing := 0, false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if failing {
+			http.Error(w, "down", 503)
+			return
+		}
+		m.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	j := NewJWKS(server.URL + "/jwks")
+
+	if _, err := j.Key(mockKeyID); err != nil {
+		t.Fatal(err)
+	}
+	if j.expires.Before(time.Now().Add(59 * time.Minute)) {
+		t.Errorf("keys expire at %v, not in an hour", j.expires)
+	}
+
+	failing = true
+	j.expires = time.Now().Add(-time.Second)
+	for i := 0; i < 3; i++ {
+		if _, err := j.Key(mockKeyID); err != nil {
+			t.Errorf("old key not used: %v", err)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("%d requests, want 2", requests)
+	}
+
+	// After the backoff it tries again, and picks up the keys once it can.
+	failing = false
+	j.expires = time.Now().Add(-time.Second)
+	if _, err := j.Key(mockKeyID); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 3 || j.expires.Before(time.Now().Add(59*time.Minute)) {
+		t.Errorf("%d requests, keys expiring at %v", requests, j.expires)
+	}
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Claims are the claims of an ID token that labdb checks or uses.
+type Claims struct {
+	Issuer        string    `json:"iss"`
+	Audience      audience  `json:"aud"`
+	Expiry        int64     `json:"exp"`
+	Subject       string    `json:"sub"`
+	Email         string    `json:"email"`
+	EmailVerified looseBool `json:"email_verified"`
+}
+
+// audience is either a single string or a list of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// looseBool accepts "true" as well as true, as some providers send strings.
+type looseBool bool
+
+func (b *looseBool) UnmarshalJSON(data []byte) error {
+	*b = looseBool(string(data) == "true" || string(data) == `"true"`)
+	return nil
+}
+
+// clockSkew is how far past its expiry a token is still accepted.
+const clockSkew = time.Minute
+
+// Verifier checks the signatures and claims of RS256 signed ID tokens.
+type Verifier struct {
+	Keys     KeySource
+	Audience string
+	// Issuers are the acceptable values of iss.
+	Issuers []string
+	// RequireVerifiedEmail rejects tokens without email_verified. Not every
+	// provider sends it (Entra ID doesn't), but those that do may issue
+	// tokens for addresses nobody has proven they own.
+	RequireVerifiedEmail bool
+}
+
+var GoogleIssuers = []string{"accounts.google.com", "https://accounts.google.com"}
+
+// Verify checks token, returning its claims if it's validly signed, issued
+// for v.Audience by one of v.Issuers, unexpired and, if v requires it, for a
+// verified email address.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("bad token header: %v", err)
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+	key, err := v.Keys.Key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("bad signature: %v", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, errors.New("bad signature")
+	}
+
+	claims := &Claims{}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, fmt.Errorf("bad token claims: %v", err)
+	}
+	if !contains(v.Issuers, claims.Issuer) {
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if !contains(claims.Audience, v.Audience) {
+		return nil, errors.New("token is for another audience")
+	}
+	if time.Now().Add(-clockSkew).After(time.Unix(claims.Expiry, 0)) {
+		return nil, errors.New("token has expired")
+	}
+	if v.RequireVerifiedEmail && !bool(claims.EmailVerified) {
+		return nil, errors.New("unverified email address")
+	}
+	return claims, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAudience = "labdb-test"
+
+func newTestIdP(t *testing.T) *MockIdP {
+	t.Helper()
+	m, err := NewMockIdP("https://idp.example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func testClaims(m *MockIdP) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":            m.Issuer,
+		"aud":            testAudience,
+		"sub":            "1234",
+		"email":          "ada@example.org",
+		"email_verified": true,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestVerify(t *testing.T) {
+	m := newTestIdP(t)
+	v := &Verifier{Keys: m.Keys(), Audience: testAudience, Issuers: []string{m.Issuer}}
+	token, err := m.IDToken(testAudience, "ada@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := v.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Email != "ada@example.org" {
+		t.Errorf("Email = %q", claims.Email)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	m := newTestIdP(t)
+	other := newTestIdP(t)
+	sign := func(idp *MockIdP, change func(map[string]interface{})) string {
+		claims := testClaims(m)
+		change(claims)
+		token, err := idp.sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	valid := sign(m, func(map[string]interface{}) {})
+	parts := strings.Split(valid, ".")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"signed by another key", sign(other, func(map[string]interface{}) {})},
+		{"claims changed after signing", parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"`+m.Issuer+`","aud":"`+testAudience+`","email":"eve@example.org","email_verified":true,"exp":9999999999}`)) + "." + parts[2]},
+		{"no signature", parts[0] + "." + parts[1] + "."},
+		{"unsigned", base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"mock"}`)) + "." + parts[1] + "."},
+		{"malformed", "not a token"},
+		{"wrong audience", sign(m, func(c map[string]interface{}) { c["aud"] = "someone-else" })},
+		{"wrong audiences", sign(m, func(c map[string]interface{}) { c["aud"] = []string{"a", "b"} })},
+		{"wrong issuer", sign(m, func(c map[string]interface{}) { c["iss"] = "https://evil.example.org" })},
+		{"expired", sign(m, func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() })},
+		{"no expiry", sign(m, func(c map[string]interface{}) { delete(c, "exp") })},
+		{"unverified email", sign(m, func(c map[string]interface{}) { c["email_verified"] = false })},
+		{"no email_verified", sign(m, func(c map[string]interface{}) { delete(c, "email_verified") })},
+	}
+	v := &Verifier{Keys: m.Keys(), Audience: testAudience, Issuers: []string{m.Issuer}, RequireVerifiedEmail: true}
+	for _, test := range tests {
+		if _, err := v.Verify(test.token); err == nil {
+			t.Errorf("%s: accepted", test.name)
+		}
+	}
+}
+
+func TestVerifyAudienceList(t *testing.T) {
+	m := newTestIdP(t)
+	claims := testClaims(m)
+	claims["aud"] = []string{"another-app", testAudience}
+	token, err := m.sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &Verifier{Keys: m.Keys(), Audience: testAudience, Issuers: []string{m.Issuer}}
+	if _, err := v.Verify(token); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVerifyWithoutEmailVerified(t *testing.T) {
+	m := newTestIdP(t)
+	claims := testClaims(m)
+	delete(claims, "email_verified")
+	token, err := m.sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &Verifier{Keys: m.Keys(), Audience: testAudience, Issuers: []string{m.Issuer}}
+	if _, err := v.Verify(token); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const mockKeyID = "mock"
+
+// MockIdP is a local identity provider for tests and development. It issues
+// ID tokens and signed SAML responses for any email address, and serves an
+// OpenID Connect discovery document and JWKS so NewOIDC can use it when
+// served at Issuer.
+type MockIdP struct {
+	Issuer string
+	key    *rsa.PrivateKey
+	cert   []byte
+}
+
+func NewMockIdP(issuer string) (*MockIdP, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "labdb mock IdP"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return &MockIdP{Issuer: issuer, key: key, cert: cert}, nil
+}
+
+// Keys returns the keys the IdP signs ID tokens with.
+func (m *MockIdP) Keys() StaticKeys {
+	return StaticKeys{mockKeyID: &m.key.PublicKey}
+}
+
+// Certificate returns the IdP's certificate as PEM, for NewSAML.
+func (m *MockIdP) Certificate() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: m.cert}))
+}
+
+// IDToken issues an hour long ID token for email.
+func (m *MockIdP) IDToken(audience, email string) (string, error) {
+	return m.sign(map[string]interface{}{
+		"iss":            m.Issuer,
+		"aud":            audience,
+		"sub":            email,
+		"email":          email,
+		"email_verified": true,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	})
+}
+
+// sign makes an RS256 signed token of claims.
+func (m *MockIdP) sign(claims map[string]interface{}) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": mockKeyID, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// SAMLResponse issues a base64 encoded SAML response with a signed
+// assertion for email, as an IdP would POST it to recipient.
+func (m *MockIdP) SAMLResponse(audience, recipient, email string) (string, error) {
+	now := time.Now().UTC()
+	ts := func(t time.Time) string { return t.Format(time.RFC3339) }
+	responseID := fmt.Sprintf("_r%d", now.UnixNano())
+	assertionID := fmt.Sprintf("_a%d", now.UnixNano())
+	head := `<samlp:Response xmlns:samlp="` + samlProtocolNS + `" xmlns:saml="` + samlAssertionNS + `" ID="` + responseID + `" Version="2.0" IssueInstant="` + ts(now) + `">` +
+		`<saml:Issuer>` + escapeText(m.Issuer) + `</saml:Issuer>` +
+		`<samlp:Status><samlp:StatusCode Value="` + statusSuccess + `"/></samlp:Status>` +
+		`<saml:Assertion ID="` + assertionID + `" Version="2.0" IssueInstant="` + ts(now) + `">` +
+		`<saml:Issuer>` + escapeText(m.Issuer) + `</saml:Issuer>`
+	tail := `<saml:Subject><saml:NameID>` + escapeText(email) + `</saml:NameID>` +
+		`<saml:SubjectConfirmation Method="` + bearer + `"><saml:SubjectConfirmationData Recipient="` + escapeAttr(recipient) + `" NotOnOrAfter="` + ts(now.Add(5*time.Minute)) + `"/></saml:SubjectConfirmation></saml:Subject>` +
+		`<saml:Conditions NotBefore="` + ts(now) + `" NotOnOrAfter="` + ts(now.Add(5*time.Minute)) + `">` +
+		`<saml:AudienceRestriction><saml:Audience>` + escapeText(audience) + `</saml:Audience></saml:AudienceRestriction></saml:Conditions>` +
+		`</saml:Assertion></samlp:Response>`
+	signed, err := m.signAssertion(head, tail)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString([]byte(signed)), nil
+}
+
+// signAssertion signs the assertion in the response head+tail, which is split
+// just after the assertion's issuer, where the signature goes.
+func (m *MockIdP) signAssertion(head, tail string) (string, error) {
+	unsigned, err := parseXML([]byte(head + tail))
+	if err != nil {
+		return "", err
+	}
+	assertion := unsigned.child(samlAssertionNS, "Assertion")
+	if assertion == nil {
+		return "", errors.New("expected a single assertion")
+	}
+	digest := sha256.Sum256(canonicalize(assertion, nil, nil))
+	signedInfo := `<ds:SignedInfo xmlns:ds="` + dsigNS + `">` +
+		`<ds:CanonicalizationMethod Algorithm="` + excC14N + `"/>` +
+		`<ds:SignatureMethod Algorithm="` + rsaSHA256 + `"/>` +
+		`<ds:Reference URI="#` + escapeAttr(assertion.attr("ID")) + `"><ds:Transforms>` +
+		`<ds:Transform Algorithm="` + envelopedSig + `"/><ds:Transform Algorithm="` + excC14N + `"/>` +
+		`</ds:Transforms><ds:DigestMethod Algorithm="` + digestSHA256 + `"/>` +
+		`<ds:DigestValue>` + base64.StdEncoding.EncodeToString(digest[:]) + `</ds:DigestValue></ds:Reference>` +
+		`</ds:SignedInfo>`
+	parsed, err := parseXML([]byte(signedInfo))
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(canonicalize(parsed, nil, nil))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, h[:])
+	if err != nil {
+		return "", err
+	}
+	signature := `<ds:Signature xmlns:ds="` + dsigNS + `">` + strings.Replace(signedInfo, ` xmlns:ds="`+dsigNS+`"`, "", 1) +
+		`<ds:SignatureValue>` + base64.StdEncoding.EncodeToString(sig) + `</ds:SignatureValue></ds:Signature>`
+	return head + signature + tail, nil
+}
+
+// ServeHTTP serves the IdP's discovery document and JWKS.
+func (m *MockIdP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body interface{}
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		body = map[string]string{
+			"issuer":   m.Issuer,
+			"jwks_uri": strings.TrimSuffix(m.Issuer, "/") + "/jwks",
+		}
+	case "/jwks":
+		body = map[string]interface{}{"keys": []jwk{{
+			Kid: mockKeyID,
+			Kty: "RSA",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}}}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"labdb.org/labdb/env"
+)
+
+// IdentityProvider establishes who is signing in from the request posted to
+// /api/verify, returning their email address.
+type IdentityProvider interface {
+	Name() string
+	Identify(r *http.Request) (string, error)
+}
+
+// TokenProvider accepts ID tokens passed as the token parameter.
+type TokenProvider struct {
+	name     string
+	Verifier *Verifier
+}
+
+func (p *TokenProvider) Name() string { return p.name }
+
+func (p *TokenProvider) Identify(r *http.Request) (string, error) {
+	token := r.FormValue("token")
+	if token == "" {
+		return "", errors.New("no token")
+	}
+	claims, err := p.Verifier.Verify(token)
+	if err != nil {
+		return "", err
+	}
+	return claims.Email, nil
+}
+
+// NewGoogle makes a provider for Google Sign-In ID tokens issued to
+// clientID.
+func NewGoogle(clientID string) *TokenProvider {
+	return &TokenProvider{
+		name:     "google",
+		Verifier: &Verifier{Keys: NewJWKS(GoogleCertsURL), Audience: clientID, Issuers: GoogleIssuers, RequireVerifiedEmail: true},
+	}
+}
+
+// NewOIDC makes a provider for ID tokens from any OpenID Connect provider,
+// finding its keys through its discovery document. requireVerifiedEmail
+// should be set for providers that send email_verified.
+func NewOIDC(issuer, clientID string, requireVerifiedEmail bool) *TokenProvider {
+	issuer = strings.TrimSuffix(issuer, "/")
+	return &TokenProvider{
+		name: "oidc",
+		Verifier: &Verifier{
+			Keys:                 &discoveredKeys{issuer: issuer, client: &http.Client{Timeout: 10 * time.Second}},
+			Audience:             clientID,
+			Issuers:              []string{issuer},
+			RequireVerifiedEmail: requireVerifiedEmail,
+		},
+	}
+}
+
+// discoveredKeys fetches an issuer's discovery document the first time a key
+// is needed, then uses the JWKS it points to.
+type discoveredKeys struct {
+	issuer string
+	client *http.Client
+
+	mu   sync.Mutex
+	jwks *JWKS
+}
+
+func (d *discoveredKeys) Key(kid string) (*rsa.PublicKey, error) {
+	d.mu.Lock()
+	if d.jwks == nil {
+		if err := d.discover(); err != nil {
+			d.mu.Unlock()
+			return nil, err
+		}
+	}
+	jwks := d.jwks
+	d.mu.Unlock()
+	return jwks.Key(kid)
+}
+
+// discover fetches the discovery document. d.mu must be held.
+func (d *discoveredKeys) discover() error {
+	resp, err := d.client.Get(d.issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return fmt.Errorf("fetching discovery document: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("fetching discovery document: %s", resp.Status)
+	}
+	doc := struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return fmt.Errorf("fetching discovery document: %v", err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != d.issuer {
+		return fmt.Errorf("discovery document is for issuer %q", doc.Issuer)
+	}
+	if doc.JWKSURI == "" {
+		return errors.New("discovery document has no jwks_uri")
+	}
+	d.jwks = &JWKS{URL: doc.JWKSURI, Client: d.client}
+	return nil
+}
+
+// ProviderFromEnv makes the identity provider configured by
+// IDENTITY_PROVIDER and the variables it needs.
+func ProviderFromEnv() (IdentityProvider, error) {
+	switch env.IdentityProvider {
+	case "", "google":
+		clientID := env.GoogleClientID
+		if clientID == "" {
+			clientID = appID
+		}
+		return NewGoogle(clientID), nil
+	case "oidc":
+		if env.OIDCIssuer == "" || env.OIDCClientID == "" {
+			return nil, errors.New("OIDC_ISSUER and OIDC_CLIENT_ID are required")
+		}
+		return NewOIDC(env.OIDCIssuer, env.OIDCClientID, env.OIDCRequireVerifiedEmail), nil
+	case "saml":
+		if env.SAMLIdPEntityID == "" || env.SAMLIdPCert == "" || env.SAMLEntityID == "" || env.SAMLACSURL == "" {
+			return nil, errors.New("SAML_IDP_ENTITY_ID, SAML_IDP_CERT, SAML_SP_ENTITY_ID and SAML_ACS_URL are required")
+		}
+		return NewSAML(env.SAMLIdPEntityID, env.SAMLEntityID, env.SAMLACSURL, env.SAMLIdPCert)
+	}
+	return nil, fmt.Errorf("unknown identity provider %q", env.IdentityProvider)
+}
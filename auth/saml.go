@@ -0,0 +1,336 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// XML namespaces and algorithm identifiers used in SAML responses.
+const (
+	samlProtocolNS  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	samlAssertionNS = "urn:oasis:names:tc:SAML:2.0:assertion"
+	dsigNS          = "http://www.w3.org/2000/09/xmldsig#"
+	excC14N         = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	envelopedSig    = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	rsaSHA256       = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	rsaSHA1         = "http://www.w3.org/2000/09/xmldsig#rsa-sha1"
+	digestSHA256    = "http://www.w3.org/2001/04/xmlenc#sha256"
+	digestSHA1      = "http://www.w3.org/2000/09/xmldsig#sha1"
+	statusSuccess   = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	bearer          = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+)
+
+// emailAttributes are the attribute names IdPs commonly send email
+// addresses as.
+var emailAttributes = []string{
+	"email",
+	"mail",
+	"urn:oid:0.9.2342.19200300.100.1.3",
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress",
+}
+
+// SAMLProvider accepts SAML 2.0 responses POSTed by an identity provider as
+// the SAMLResponse form field. The response or its assertion has to be
+// signed by the IdP's certificate, and each assertion is only accepted once.
+type SAMLProvider struct {
+	// IdPEntityID is the expected issuer of assertions.
+	IdPEntityID string
+	// EntityID is labdb's entity ID, which assertions have to be addressed
+	// to.
+	EntityID string
+	// ACSURL is where the IdP posts responses, which they have to name as
+	// their recipient.
+	ACSURL string
+	Key    *rsa.PublicKey
+
+	mu sync.Mutex
+	// seen holds the IDs of assertions already used, until they expire.
+	seen map[string]time.Time
+}
+
+// NewSAML makes a SAMLProvider trusting the IdP certificate cert, given as
+// PEM or as base64 DER as it appears in metadata.
+func NewSAML(idpEntityID, entityID, acsURL, cert string) (*SAMLProvider, error) {
+	der := []byte(cert)
+	if block, _ := pem.Decode([]byte(cert)); block != nil {
+		der = block.Bytes
+	} else {
+		decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(cert), ""))
+		if err != nil {
+			return nil, fmt.Errorf("bad IdP certificate: %v", err)
+		}
+		der = decoded
+	}
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("bad IdP certificate: %v", err)
+	}
+	key, ok := parsed.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("IdP certificate doesn't have an RSA key")
+	}
+	return &SAMLProvider{IdPEntityID: idpEntityID, EntityID: entityID, ACSURL: acsURL, Key: key}, nil
+}
+
+func (p *SAMLProvider) Name() string { return "saml" }
+
+func (p *SAMLProvider) Identify(r *http.Request) (string, error) {
+	encoded := r.FormValue("SAMLResponse")
+	if encoded == "" {
+		return "", errors.New("no SAMLResponse")
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+	if err != nil {
+		return "", fmt.Errorf("bad SAMLResponse: %v", err)
+	}
+	return p.verifyResponse(data, time.Now())
+}
+
+func (p *SAMLProvider) verifyResponse(data []byte, now time.Time) (string, error) {
+	resp, err := parseXML(data)
+	if err != nil {
+		return "", err
+	}
+	if !resp.is(samlProtocolNS, "Response") {
+		return "", errors.New("not a SAML response")
+	}
+	status := resp.child(samlProtocolNS, "Status")
+	if status == nil || status.child(samlProtocolNS, "StatusCode") == nil || status.child(samlProtocolNS, "StatusCode").attr("Value") != statusSuccess {
+		return "", errors.New("sign in failed at the IdP")
+	}
+	if len(resp.children(samlAssertionNS, "EncryptedAssertion")) > 0 {
+		return "", errors.New("encrypted assertions aren't supported")
+	}
+	// Everything used from here on has to come from within the element whose
+	// signature was checked, so that unsigned elements can't be swapped in.
+	assertion := resp.child(samlAssertionNS, "Assertion")
+	if assertion == nil {
+		return "", errors.New("expected a single assertion")
+	}
+	if assertion.attr("ID") == "" {
+		return "", errors.New("assertion without an ID")
+	}
+	if resp.child(dsigNS, "Signature") != nil {
+		err = p.verifySignature(resp)
+	} else {
+		err = p.verifySignature(assertion)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	issuer := assertion.child(samlAssertionNS, "Issuer")
+	if issuer == nil || issuer.text() != p.IdPEntityID {
+		return "", errors.New("assertion from an unexpected issuer")
+	}
+	conditions := assertion.child(samlAssertionNS, "Conditions")
+	if conditions == nil {
+		return "", errors.New("assertion without conditions")
+	}
+	if err := checkTimes(conditions, now); err != nil {
+		return "", err
+	}
+	addressed := false
+	for _, r := range conditions.children(samlAssertionNS, "AudienceRestriction") {
+		for _, a := range r.children(samlAssertionNS, "Audience") {
+			addressed = addressed || a.text() == p.EntityID
+		}
+	}
+	if !addressed {
+		return "", errors.New("assertion is for another audience")
+	}
+	subject := assertion.child(samlAssertionNS, "Subject")
+	if subject == nil {
+		return "", errors.New("assertion without a subject")
+	}
+	// Bearer confirmations have to be limited in time, so that the assertion
+	// only needs remembering until then.
+	var expiry time.Time
+	for _, sc := range subject.children(samlAssertionNS, "SubjectConfirmation") {
+		data := sc.child(samlAssertionNS, "SubjectConfirmationData")
+		if sc.attr("Method") != bearer || data == nil || data.attr("Recipient") != p.ACSURL {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, data.attr("NotOnOrAfter"))
+		if err != nil || checkTimes(data, now) != nil {
+			continue
+		}
+		if t.After(expiry) {
+			expiry = t
+		}
+	}
+	if expiry.IsZero() {
+		return "", errors.New("subject isn't confirmed")
+	}
+
+	email := assertionEmail(assertion, subject)
+	if email == "" {
+		return "", errors.New("assertion doesn't include an email address")
+	}
+	if err := p.use(assertion.attr("ID"), expiry.Add(clockSkew), now); err != nil {
+		return "", err
+	}
+	return email, nil
+}
+
+// assertionEmail returns the email address assertion is about, from its
+// subject or attributes.
+func assertionEmail(assertion, subject *xmlNode) string {
+	if nameID := subject.child(samlAssertionNS, "NameID"); nameID != nil && strings.Contains(nameID.text(), "@") {
+		return nameID.text()
+	}
+	if attrs := assertion.child(samlAssertionNS, "AttributeStatement"); attrs != nil {
+		for _, a := range attrs.children(samlAssertionNS, "Attribute") {
+			if !contains(emailAttributes, a.attr("Name")) {
+				continue
+			}
+			if v := a.children(samlAssertionNS, "AttributeValue"); len(v) > 0 && v[0].text() != "" {
+				return v[0].text()
+			}
+		}
+	}
+	return ""
+}
+
+// use records that the assertion with ID id has been used, rejecting it if
+// it already has been. It's remembered until expiry, after which it's
+// rejected anyway.
+func (p *SAMLProvider) use(id string, expiry, now time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.seen == nil {
+		p.seen = map[string]time.Time{}
+	}
+	for seenID, t := range p.seen {
+		if now.After(t) {
+			delete(p.seen, seenID)
+		}
+	}
+	if _, ok := p.seen[id]; ok {
+		return errors.New("assertion has already been used")
+	}
+	p.seen[id] = expiry
+	return nil
+}
+
+// checkTimes checks the NotBefore and NotOnOrAfter attributes of n, allowing
+// for clockSkew.
+func checkTimes(n *xmlNode, now time.Time) error {
+	if s := n.attr("NotBefore"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil || now.Add(clockSkew).Before(t) {
+			return errors.New("assertion isn't valid yet")
+		}
+	}
+	if s := n.attr("NotOnOrAfter"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil || !now.Add(-clockSkew).Before(t) {
+			return errors.New("assertion has expired")
+		}
+	}
+	return nil
+}
+
+// verifySignature checks the enveloped signature of n, which must cover
+// exactly n.
+func (p *SAMLProvider) verifySignature(n *xmlNode) error {
+	sig := n.child(dsigNS, "Signature")
+	if sig == nil {
+		return errors.New("response isn't signed")
+	}
+	signedInfo := sig.child(dsigNS, "SignedInfo")
+	if signedInfo == nil {
+		return errors.New("bad signature")
+	}
+	c14n := signedInfo.child(dsigNS, "CanonicalizationMethod")
+	if c14n == nil || c14n.attr("Algorithm") != excC14N {
+		return errors.New("unsupported canonicalization")
+	}
+	method := signedInfo.child(dsigNS, "SignatureMethod")
+	if method == nil {
+		return errors.New("bad signature")
+	}
+	var hash crypto.Hash
+	switch method.attr("Algorithm") {
+	case rsaSHA256:
+		hash = crypto.SHA256
+	case rsaSHA1:
+		hash = crypto.SHA1
+	default:
+		return fmt.Errorf("unsupported signature method %q", method.attr("Algorithm"))
+	}
+
+	ref := signedInfo.child(dsigNS, "Reference")
+	if ref == nil || n.attr("ID") == "" || ref.attr("URI") != "#"+n.attr("ID") {
+		return errors.New("signature doesn't cover the response")
+	}
+	var inclusive []string
+	if transforms := ref.child(dsigNS, "Transforms"); transforms != nil {
+		for _, t := range transforms.children(dsigNS, "Transform") {
+			switch t.attr("Algorithm") {
+			case envelopedSig:
+			case excC14N:
+				inclusive = inclusivePrefixes(t)
+			default:
+				return fmt.Errorf("unsupported transform %q", t.attr("Algorithm"))
+			}
+		}
+	}
+	digestMethod := ref.child(dsigNS, "DigestMethod")
+	digestValue := ref.child(dsigNS, "DigestValue")
+	if digestMethod == nil || digestValue == nil {
+		return errors.New("bad signature")
+	}
+	canonical := canonicalize(n, sig, inclusive)
+	var digest []byte
+	switch digestMethod.attr("Algorithm") {
+	case digestSHA256:
+		d := sha256.Sum256(canonical)
+		digest = d[:]
+	case digestSHA1:
+		d := sha1.Sum(canonical)
+		digest = d[:]
+	default:
+		return fmt.Errorf("unsupported digest method %q", digestMethod.attr("Algorithm"))
+	}
+	expected, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(digestValue.text()), ""))
+	if err != nil || subtle.ConstantTimeCompare(expected, digest) != 1 {
+		return errors.New("response was modified after signing")
+	}
+
+	sigValue := sig.child(dsigNS, "SignatureValue")
+	if sigValue == nil {
+		return errors.New("bad signature")
+	}
+	sigBytes, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(sigValue.text()), ""))
+	if err != nil {
+		return errors.New("bad signature")
+	}
+	h := hash.New()
+	h.Write(canonicalize(signedInfo, nil, inclusivePrefixes(c14n)))
+	if err := rsa.VerifyPKCS1v15(p.Key, hash, h.Sum(nil), sigBytes); err != nil {
+		return errors.New("bad signature")
+	}
+	return nil
+}
+
+// inclusivePrefixes returns the PrefixList of an exclusive canonicalization
+// algorithm element.
+func inclusivePrefixes(n *xmlNode) []string {
+	if inc := n.child(excC14N, "InclusiveNamespaces"); inc != nil {
+		return strings.Fields(inc.attr("PrefixList"))
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testEntityID = "https://labdb.example.org"
+	testACSURL   = "https://labdb.example.org/api/verify"
+)
+
+func testSAMLResponse(t *testing.T, m *MockIdP, audience, recipient, email string) string {
+	t.Helper()
+	encoded, err := m.SAMLResponse(audience, recipient, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func testSAMLProvider(t *testing.T, m *MockIdP) *SAMLProvider {
+	t.Helper()
+	p, err := NewSAML(m.Issuer, testEntityID, testACSURL, m.Certificate())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// assertionOf returns the assertion in a response.
+func assertionOf(response string) string {
+	start := strings.Index(response, "<saml:Assertion ")
+	end := strings.Index(response, "</saml:Assertion>") + len("</saml:Assertion>")
+	return response[start:end]
+}
+
+func TestVerifyResponse(t *testing.T) {
+	m := newTestIdP(t)
+	p := testSAMLProvider(t, m)
+	email, err := p.verifyResponse([]byte(testSAMLResponse(t, m, testEntityID, testACSURL, "ada@example.org")), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email != "ada@example.org" {
+		t.Errorf("email = %q", email)
+	}
+}
+
+// resigned changes a response with replace, then signs its assertion again.
+func resigned(t *testing.T, m *MockIdP, response string, replace func(string) string) string {
+	t.Helper()
+	start := strings.Index(response, "<ds:Signature")
+	end := strings.Index(response, "</ds:Signature>") + len("</ds:Signature>")
+	signed, err := m.signAssertion(replace(response[:start]), replace(response[end:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestVerifyResponseRejects(t *testing.T) {
+	m := newTestIdP(t)
+	response := testSAMLResponse(t, m, testEntityID, testACSURL, "ada@example.org")
+	unconfirmed := func(old, new string) string {
+		return resigned(t, m, response, func(s string) string {
+			return strings.Replace(s, old, new, 1)
+		})
+	}
+	assertion := assertionOf(response)
+	// The same assertion for someone else, without a signature.
+	forged := strings.Replace(assertion, "ada@example.org", "eve@example.org", 1)
+	forged = forged[:strings.Index(forged, "<ds:Signature")] + forged[strings.Index(forged, "</ds:Signature>")+len("</ds:Signature>"):]
+
+	tests := []struct {
+		name     string
+		response string
+		now      time.Time
+		provider *SAMLProvider
+	}{
+		{name: "wrong audience", response: testSAMLResponse(t, m, "https://other.example.org", testACSURL, "ada@example.org")},
+		{name: "wrong recipient", response: testSAMLResponse(t, m, testEntityID, "https://other.example.org/acs", "ada@example.org")},
+		{name: "no recipient", response: unconfirmed(`Recipient="`+testACSURL+`"`, "")},
+		{name: "no expiry", response: unconfirmed(`NotOnOrAfter=`, "Address=")},
+		{name: "not bearer", response: unconfirmed(bearer, "urn:oasis:names:tc:SAML:2.0:cm:holder-of-key")},
+		{name: "signed by another IdP", response: testSAMLResponse(t, newTestIdP(t), testEntityID, testACSURL, "ada@example.org")},
+		{name: "tampered", response: strings.Replace(response, "ada@example.org", "eve@example.org", 1)},
+		{name: "unsigned", response: strings.Replace(response, assertion, forged, 1)},
+		{name: "extra assertion", response: strings.Replace(response, assertion, assertion+forged, 1)},
+		{
+			// The signed assertion is kept where a careless verifier would
+			// find it, but the one used is the forgery.
+			name:     "wrapped",
+			response: strings.Replace(response, assertion, "<samlp:Extensions>"+assertion+"</samlp:Extensions>"+forged, 1),
+		},
+		{
+			// As above, but the forgery has the original's ID and signature.
+			name:     "wrapped with signature",
+			response: strings.Replace(response, assertion, "<samlp:Extensions>"+assertion+"</samlp:Extensions>"+strings.Replace(assertion, "ada@example.org", "eve@example.org", 1), 1),
+		},
+		{name: "expired", response: response, now: time.Now().Add(time.Hour)},
+		{name: "not yet valid", response: response, now: time.Now().Add(-time.Hour)},
+		{name: "wrong issuer", response: response, provider: &SAMLProvider{IdPEntityID: "https://evil.example.org", EntityID: testEntityID, ACSURL: testACSURL, Key: testSAMLProvider(t, m).Key}},
+		{name: "failed", response: strings.Replace(response, statusSuccess, "urn:oasis:names:tc:SAML:2.0:status:Requester", 1)},
+		{name: "not XML", response: "<samlp:Response"},
+	}
+	for _, test := range tests {
+		p := test.provider
+		if p == nil {
+			p = testSAMLProvider(t, m)
+		}
+		now := test.now
+		if now.IsZero() {
+			now = time.Now()
+		}
+		if email, err := p.verifyResponse([]byte(test.response), now); err == nil {
+			t.Errorf("%s: accepted for %s", test.name, email)
+		}
+	}
+}
+
+func TestVerifyResponseReplay(t *testing.T) {
+	m := newTestIdP(t)
+	p := testSAMLProvider(t, m)
+	response := []byte(testSAMLResponse(t, m, testEntityID, testACSURL, "ada@example.org"))
+	now := time.Now()
+	if _, err := p.verifyResponse(response, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.verifyResponse(response, now.Add(time.Minute)); err == nil {
+		t.Error("accepted twice")
+	}
+	if _, err := p.verifyResponse([]byte(testSAMLResponse(t, m, testEntityID, testACSURL, "ada@example.org")), now); err != nil {
+		t.Errorf("new assertion: %v", err)
+	}
+	// Long expired assertions are forgotten.
+	p.use("_other", now, now.Add(time.Hour))
+	if len(p.seen) != 1 {
+		t.Errorf("%d assertions remembered", len(p.seen))
+	}
+}
+
+func TestResigned(t *testing.T) {
+	m := newTestIdP(t)
+	response := testSAMLResponse(t, m, testEntityID, testACSURL, "ada@example.org")
+	changed := resigned(t, m, response, func(s string) string {
+		return strings.Replace(s, "ada@example.org", "grace@example.org", 1)
+	})
+	if email, err := testSAMLProvider(t, m).verifyResponse([]byte(changed), time.Now()); err != nil || email != "grace@example.org" {
+		t.Errorf("got %q, %v", email, err)
+	}
+}
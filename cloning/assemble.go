@@ -0,0 +1,352 @@
+package cloning
+
+import (
+	"fmt"
+	"strings"
+
+	"labdb.org/labdb/models"
+	"labdb.org/labdb/restriction"
+	"labdb.org/labdb/sequence"
+)
+
+type Method string
+
+const (
+	Gibson              Method = "gibson"
+	RestrictionLigation Method = "restriction"
+	GoldenGate          Method = "golden_gate"
+)
+
+// Name returns the method's name for people.
+func (m Method) Name() string {
+	switch m {
+	case Gibson:
+		return "Gibson"
+	case RestrictionLigation:
+		return "Restriction-ligation"
+	case GoldenGate:
+		return "Golden Gate"
+	}
+	return string(m)
+}
+
+// Overlaps Gibson assembly will join, in bases.
+const (
+	MinOverlap = 15
+	MaxOverlap = 150
+	// Overlaps shorter than this often assemble poorly.
+	recommendedOverlap = 20
+)
+
+// DefaultGoldenGateEnzyme is used when a Golden Gate assembly doesn't name
+// one.
+const DefaultGoldenGateEnzyme = "BsaI"
+
+// Assembly describes a cloning reaction. Inserts go into the backbone in
+// order for Gibson assembly and restriction-ligation; Golden Gate assembly
+// orders them by their overhangs.
+//
+// For Gibson assembly, the backbone is linearized with Enzymes if there are
+// any (keeping the largest fragment) and must otherwise already be linear.
+// Restriction-ligation cuts everything with Enzymes, keeping the largest
+// fragment of the backbone and the smallest of each insert with both ends
+// cut. Golden Gate uses the single enzyme in Enzymes and keeps the fragments
+// without a site, which are the ones that survive the reaction.
+type Assembly struct {
+	Method   Method
+	Backbone Part
+	Inserts  []Part
+	Enzymes  []*restriction.Enzyme
+}
+
+// Result is an assembled circular sequence.
+type Result struct {
+	Sequence    string
+	Annotations []models.Annotation
+	Warnings    []string
+}
+
+func (r *Result) warnf(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Run simulates the assembly, returning an *Error if it wouldn't work.
+func (a *Assembly) Run() (*Result, error) {
+	if len(a.Inserts) == 0 {
+		return nil, errorf("no inserts")
+	}
+	result := &Result{Warnings: []string{}}
+	switch a.Method {
+	case Gibson:
+		return result, a.gibson(result)
+	case RestrictionLigation:
+		return result, a.ligation(result)
+	case GoldenGate:
+		return result, a.goldenGate(result)
+	}
+	return nil, errorf("unknown assembly method %q", a.Method)
+}
+
+// join concatenates pieces into result, dropping the first trim[i] bases of
+// each and any annotations in them.
+func join(result *Result, pieces []Piece, trim []int) {
+	var seq strings.Builder
+	result.Annotations = []models.Annotation{}
+	for i, p := range pieces {
+		offset := seq.Len() - trim[i]
+		seq.WriteString(p.Sequence[trim[i]:])
+		for _, an := range p.Annotations {
+			if an.Start < trim[i] {
+				continue
+			}
+			an.Start += offset
+			an.End += offset
+			result.Annotations = append(result.Annotations, an)
+		}
+	}
+	result.Sequence = seq.String()
+}
+
+// overlap returns the length of the longest end of a that b starts with, if
+// it's long enough for Gibson assembly.
+func overlap(a, b string) int {
+	max := MaxOverlap
+	if len(a) < max {
+		max = len(a)
+	}
+	if len(b) < max {
+		max = len(b)
+	}
+	for k := max; k >= MinOverlap; k-- {
+		if a[len(a)-k:] == b[:k] {
+			return k
+		}
+	}
+	return 0
+}
+
+func (a *Assembly) gibson(result *Result) error {
+	var backbone Piece
+	if len(a.Enzymes) > 0 {
+		pieces, err := Digest(a.Backbone, a.Enzymes)
+		if err != nil {
+			return err
+		}
+		backbone = largest(pieces)
+	} else if a.Backbone.Circular {
+		return errorf("the backbone needs linearizing, with enzymes or by choosing a region of it")
+	} else {
+		backbone = Linear(a.Backbone)
+	}
+
+	pieces := []Piece{backbone}
+	for _, part := range a.Inserts {
+		if part.Circular {
+			return errorf("%s is circular; use a region of it or a PCR product", part.Name)
+		}
+		insert := Linear(part)
+		prev := pieces[len(pieces)-1].Sequence
+		if overlap(prev, insert.Sequence) == 0 {
+			flipped, err := insert.Flipped()
+			if err != nil {
+				return err
+			}
+			if overlap(prev, flipped.Sequence) > 0 {
+				insert = flipped
+			}
+		}
+		pieces = append(pieces, insert)
+	}
+
+	trim := make([]int, len(pieces))
+	for i := range pieces {
+		prev := pieces[(i+len(pieces)-1)%len(pieces)]
+		k := overlap(prev.Sequence, pieces[i].Sequence)
+		if k == 0 {
+			return errorf("%s and %s don't overlap by at least %d bp", prev.Name, pieces[i].Name, MinOverlap)
+		}
+		if k < recommendedOverlap {
+			result.warnf("%s and %s only overlap by %d bp", prev.Name, pieces[i].Name, k)
+		}
+		trim[i] = k
+	}
+	join(result, pieces, trim)
+	return nil
+}
+
+// largest returns the longest of pieces.
+func largest(pieces []Piece) Piece {
+	best := pieces[0]
+	for _, p := range pieces[1:] {
+		if len(p.Sequence) > len(best.Sequence) {
+			best = p
+		}
+	}
+	return best
+}
+
+// cutPieces returns the pieces of part cut at both ends by enzymes.
+func cutPieces(part Part, enzymes []*restriction.Enzyme) ([]Piece, error) {
+	pieces, err := Digest(part, enzymes)
+	if err != nil {
+		return nil, err
+	}
+	cut := []Piece{}
+	for _, p := range pieces {
+		if p.Left != nil && p.Right != nil {
+			cut = append(cut, p)
+		}
+	}
+	if len(cut) == 0 {
+		return nil, errorf("%s isn't cut on both sides of anything", part.Name)
+	}
+	return cut, nil
+}
+
+func (a *Assembly) ligation(result *Result) error {
+	if len(a.Enzymes) == 0 {
+		return errorf("restriction-ligation needs enzymes")
+	}
+	backbonePieces, err := cutPieces(a.Backbone, a.Enzymes)
+	if err != nil {
+		return err
+	}
+	pieces := []Piece{largest(backbonePieces)}
+	for _, part := range a.Inserts {
+		candidates, err := cutPieces(part, a.Enzymes)
+		if err != nil {
+			return err
+		}
+		insert := candidates[0]
+		for _, p := range candidates[1:] {
+			if len(p.Sequence) < len(insert.Sequence) {
+				insert = p
+			}
+		}
+		if len(candidates) > 1 {
+			result.warnf("%s has %d fragments cut at both ends; using the %d bp one", part.Name, len(candidates), len(insert.Sequence))
+		}
+		pieces = append(pieces, insert)
+	}
+
+	// Each insert has to join the piece before it, and the last the
+	// backbone, in one orientation or the other.
+	for i := 1; i < len(pieces); i++ {
+		prev := pieces[i-1]
+		next := pieces[(i+1)%len(pieces)]
+		fits := func(p Piece) bool {
+			if i == len(pieces)-1 {
+				return prev.Right.joins(p.Left) && p.Right.joins(next.Left)
+			}
+			return prev.Right.joins(p.Left)
+		}
+		forward := pieces[i]
+		flipped, err := forward.Flipped()
+		if err != nil {
+			return err
+		}
+		switch {
+		case fits(forward) && fits(flipped):
+			result.warnf("%s can go in either orientation", forward.Name)
+		case fits(forward):
+		case fits(flipped):
+			pieces[i] = flipped
+		default:
+			return errorf("%s can't ligate to %s: %s next to %s", forward.Name, prev.Name, prev.Right, forward.Left)
+		}
+	}
+	last := pieces[len(pieces)-1]
+	if !last.Right.joins(pieces[0].Left) {
+		return errorf("%s can't ligate to %s: %s next to %s", last.Name, pieces[0].Name, last.Right, pieces[0].Left)
+	}
+	join(result, pieces, make([]int, len(pieces)))
+	return nil
+}
+
+func (a *Assembly) goldenGate(result *Result) error {
+	if len(a.Enzymes) != 1 {
+		return errorf("Golden Gate assembly uses a single enzyme")
+	}
+	enzyme := a.Enzymes[0]
+	if enzyme.Palindromic() {
+		return errorf("%s cuts within its site, so can't be used for Golden Gate assembly", enzyme.Name)
+	}
+	// Pieces with a site left in them get cut again, so only the ones without
+	// end up in the product.
+	final := func(part Part) ([]Piece, error) {
+		pieces, err := cutPieces(part, a.Enzymes)
+		if err != nil {
+			return nil, err
+		}
+		kept := []Piece{}
+		for _, p := range pieces {
+			if len(sequence.Find(enzyme.Site, p.Sequence, 0, false)) == 0 {
+				kept = append(kept, p)
+			}
+		}
+		if len(kept) == 0 {
+			return nil, errorf("every fragment of %s still has a %s site", part.Name, enzyme.Name)
+		}
+		return kept, nil
+	}
+
+	backbonePieces, err := final(a.Backbone)
+	if err != nil {
+		return err
+	}
+	backbone := largest(backbonePieces)
+	unused := []Piece{}
+	for _, part := range a.Inserts {
+		pieces, err := final(part)
+		if err != nil {
+			return err
+		}
+		if len(pieces) > 1 {
+			return errorf("%s has %d fragments that would go into the assembly", part.Name, len(pieces))
+		}
+		unused = append(unused, pieces[0])
+	}
+	for _, p := range append([]Piece{backbone}, unused...) {
+		if p.Left.Seq == sequence.ReverseComplement(p.Left.Seq) || p.Right.Seq == sequence.ReverseComplement(p.Right.Seq) {
+			result.warnf("%s has a palindromic overhang, which can ligate to itself", p.Name)
+		}
+	}
+
+	// Follow the overhangs round from the backbone.
+	pieces := []Piece{backbone}
+	for len(unused) > 0 {
+		current := pieces[len(pieces)-1]
+		found := -1
+		var next Piece
+		for i, p := range unused {
+			flipped, err := p.Flipped()
+			if err != nil {
+				return err
+			}
+			for _, candidate := range []Piece{p, flipped} {
+				if current.Right.joins(candidate.Left) {
+					if found >= 0 {
+						return errorf("both %s and %s can follow %s", unused[found].Name, p.Name, current.Name)
+					}
+					found, next = i, candidate
+					break
+				}
+			}
+		}
+		if found < 0 {
+			names := []string{}
+			for _, p := range unused {
+				names = append(names, p.Name)
+			}
+			return errorf("nothing ligates to %s after %s; left over: %s", current.Right, current.Name, strings.Join(names, ", "))
+		}
+		pieces = append(pieces, next)
+		unused = append(unused[:found], unused[found+1:]...)
+	}
+	last := pieces[len(pieces)-1]
+	if !last.Right.joins(backbone.Left) {
+		return errorf("%s doesn't ligate back to the backbone: %s next to %s", last.Name, last.Right, backbone.Left)
+	}
+	join(result, pieces, make([]int, len(pieces)))
+	return nil
+}
@@ -0,0 +1,186 @@
+package cloning
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"labdb.org/labdb/models"
+	"labdb.org/labdb/restriction"
+	"labdb.org/labdb/sequence"
+)
+
+// testSites are kept out of the random filler around the parts under test.
+var testSites = []string{"GAATTC", "CTCGAG", "GGTCTC", "GAGACC"}
+
+// filler returns n random bases without any of testSites.
+func filler(r *rand.Rand, n int) string {
+	for {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = "ACGT"[r.Intn(4)]
+		}
+		clean := true
+		for _, site := range testSites {
+			clean = clean && !strings.Contains(string(b), site)
+		}
+		if clean {
+			return string(b)
+		}
+	}
+}
+
+func annotation(name string, start, end, strand int) models.Annotation {
+	return models.Annotation{Name: name, Start: start, End: end, Strand: strand}
+}
+
+// reversed returns p's reverse complement, with its annotations moved to
+// match.
+func reversed(p Part) Part {
+	n := len(p.Sequence)
+	flipped := Part{Name: p.Name, Sequence: sequence.ReverseComplement(p.Sequence), Circular: p.Circular}
+	for _, a := range p.Annotations {
+		a.Start, a.End, a.Strand = n-a.End, n-a.Start, -a.Strand
+		flipped.Annotations = append(flipped.Annotations, a)
+	}
+	return flipped
+}
+
+func enzymes(t *testing.T, names ...string) []*restriction.Enzyme {
+	t.Helper()
+	es := []*restriction.Enzyme{}
+	for _, name := range names {
+		e, ok := restriction.Lookup(name)
+		if !ok {
+			t.Fatalf("no %s", name)
+		}
+		es = append(es, e)
+	}
+	return es
+}
+
+func checkResult(t *testing.T, a *Assembly, seq string, annotations []models.Annotation, warnings int) {
+	t.Helper()
+	result, err := a.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sequence != seq {
+		t.Errorf("assembled\n%s\nwant\n%s", result.Sequence, seq)
+	}
+	if !reflect.DeepEqual(result.Annotations, annotations) {
+		t.Errorf("annotations\n%+v\nwant\n%+v", result.Annotations, annotations)
+	}
+	if len(result.Warnings) != warnings {
+		t.Errorf("warnings %q, want %d", result.Warnings, warnings)
+	}
+}
+
+func TestGibson(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	s0, s1, s2 := filler(r, 100), filler(r, 60), filler(r, 60)
+	o1, o2, o3 := filler(r, 18), filler(r, 25), filler(r, 30)
+
+	backbone := Part{
+		Name:     "backbone",
+		Sequence: o3 + s0 + o1,
+		Annotations: []models.Annotation{
+			// In the overlap trimmed off the backbone, so dropped.
+			annotation("in o3", 5, 20, 1),
+			annotation("bb", 40, 80, 1),
+		},
+	}
+	insert1 := Part{Name: "insert 1", Sequence: o1 + s1 + o2, Annotations: []models.Annotation{annotation("i1", 23, 63, -1)}}
+	insert2 := Part{Name: "insert 2", Sequence: o2 + s2 + o3, Annotations: []models.Annotation{annotation("i2", 35, 65, 1)}}
+	a := &Assembly{Method: Gibson, Backbone: backbone, Inserts: []Part{insert1, reversed(insert2)}}
+
+	// o1 is short enough to warn about.
+	checkResult(t, a, s0+o1+s1+o2+s2+o3, []models.Annotation{
+		annotation("bb", 10, 50, 1),
+		annotation("i1", 123, 163, -1),
+		annotation("i2", 213, 243, 1),
+	}, 1)
+}
+
+func TestRestrictionLigation(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	f1, f2, f3 := filler(r, 80), filler(r, 40), filler(r, 80)
+	ins := filler(r, 60)
+
+	// EcoRI cuts G^AATTC at 81 and XhoI C^TCGAG at 127, leaving the
+	// backbone from 127 round to 81.
+	backbone := Part{
+		Name:     "backbone",
+		Sequence: f1 + "GAATTC" + f2 + "CTCGAG" + f3,
+		Circular: true,
+		Annotations: []models.Annotation{
+			annotation("f1", 10, 30, 1),
+			annotation("f2", 90, 100, 1),
+			annotation("f3", 140, 180, -1),
+			annotation("origin", 200, 220, 1),
+		},
+	}
+	// Cut at 11 and 77.
+	insert := Part{
+		Name:        "insert",
+		Sequence:    "ACGTACGTAC" + "GAATTC" + ins + "CTCGAG" + "ACGTACGTAC",
+		Annotations: []models.Annotation{annotation("ins", 20, 50, 1)},
+	}
+	want := "TCGAG" + f3 + f1 + "G" + "AATTC" + ins + "C"
+	wantAnnotations := []models.Annotation{
+		annotation("f1", 95, 115, 1),
+		annotation("f3", 13, 53, -1),
+		annotation("origin", 73, 93, 1),
+		annotation("ins", 175, 205, 1),
+	}
+	es := enzymes(t, "EcoRI", "XhoI")
+	checkResult(t, &Assembly{Method: RestrictionLigation, Backbone: backbone, Inserts: []Part{insert}, Enzymes: es}, want, wantAnnotations, 0)
+	// The insert only fits one way round, so it's flipped to that.
+	checkResult(t, &Assembly{Method: RestrictionLigation, Backbone: backbone, Inserts: []Part{reversed(insert)}, Enzymes: es}, want, wantAnnotations, 0)
+}
+
+func TestGoldenGate(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	v, stuffer := filler(r, 150), filler(r, 30)
+	body1, body2 := filler(r, 60), filler(r, 50)
+	const ohA, ohB, ohC = "AATG", "GCTT", "TACT"
+	pad := "ACGTA"
+
+	// BsaI cuts GGTCTC(1/5), so the backbone is cut at 154 by the reverse
+	// site and at 0 by the forward one, leaving ohB+v.
+	backbone := Part{
+		Name:     "backbone",
+		Sequence: ohB + v + ohA + "A" + "GAGACC" + stuffer + "GGTCTC" + "A",
+		Circular: true,
+		Annotations: []models.Annotation{
+			annotation("v", 10, 60, 1),
+			annotation("stuffer", 170, 190, 1),
+		},
+	}
+	part := func(name, left, body, right string, annotations ...models.Annotation) Part {
+		return Part{
+			Name:        name,
+			Sequence:    pad + "GGTCTC" + "A" + left + body + right + "A" + "GAGACC" + pad,
+			Annotations: annotations,
+		}
+	}
+	// Cut at 12 and 76.
+	insert1 := part("insert 1", ohA, body1, ohC, annotation("body1", 20, 40, 1))
+	// Cut at 12 and 66.
+	insert2 := part("insert 2", ohC, body2, ohB, annotation("body2", 16, 66, 1))
+
+	a := &Assembly{
+		Method:   GoldenGate,
+		Backbone: backbone,
+		// Put together by their overhangs, whatever the order and
+		// orientation they're given in.
+		Inserts: []Part{reversed(insert2), insert1},
+		Enzymes: enzymes(t, "BsaI"),
+	}
+	checkResult(t, a, ohB+v+ohA+body1+ohC+body2, []models.Annotation{
+		annotation("v", 10, 60, 1),
+		annotation("body1", 162, 182, 1),
+		annotation("body2", 222, 272, 1),
+	}, 0)
+}
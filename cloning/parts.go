@@ -0,0 +1,288 @@
+// Package cloning simulates assembling plasmids from a backbone and inserts
+// by Gibson assembly, restriction-ligation or Golden Gate assembly.
+package cloning
+
+import (
+	"fmt"
+
+	"labdb.org/labdb/models"
+	"labdb.org/labdb/restriction"
+	"labdb.org/labdb/sequence"
+)
+
+// Error is a problem with a proposed assembly, as opposed to a failure
+// looking things up.
+type Error struct {
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+func errorf(format string, args ...interface{}) error {
+	return &Error{Msg: fmt.Sprintf(format, args...)}
+}
+
+// Part is a DNA molecule going into an assembly: a plasmid, a region of one
+// or a PCR product. Sequence is normalized and annotations are positioned
+// along it.
+type Part struct {
+	Name        string
+	Sequence    string
+	Circular    bool
+	Annotations []models.Annotation
+}
+
+// PartFrom makes a part from an item with a sequence, carrying over its
+// annotations.
+func PartFrom(e models.Entity, annotations []models.Annotation) Part {
+	return Part{
+		Name:        models.NameOf(e),
+		Sequence:    sequence.Normalize(e.GetSequence()),
+		Circular:    e.IsCircular(),
+		Annotations: annotations,
+	}
+}
+
+// wrapped returns seq[start:end], wrapping around the origin if circular.
+func wrapped(seq string, start, end int, circular bool) (string, error) {
+	n := len(seq)
+	if start < 0 || end < start || (!circular && end > n) || (circular && (start >= n || end-start > n)) {
+		return "", errorf("region %d..%d is outside the %d bp sequence", start+1, end, n)
+	}
+	if end <= n {
+		return seq[start:end], nil
+	}
+	return seq[start:] + seq[:end-n], nil
+}
+
+// Region returns the linear part of p from start to end, which may run past
+// the origin of circular parts.
+func (p Part) Region(start, end int) (Part, error) {
+	seq, err := wrapped(p.Sequence, start, end, p.Circular)
+	if err != nil {
+		return Part{}, err
+	}
+	return Part{
+		Name:        fmt.Sprintf("%s (%d..%d)", p.Name, start+1, end),
+		Sequence:    seq,
+		Annotations: within(p.Annotations, start, end, len(p.Sequence), p.Circular),
+	}, nil
+}
+
+// within returns the annotations lying entirely between start and end on a
+// sequence of length n, moved to be relative to start.
+func within(annotations []models.Annotation, start, end, n int, circular bool) []models.Annotation {
+	result := []models.Annotation{}
+	for _, a := range annotations {
+		shifts := []int{0}
+		if circular {
+			shifts = append(shifts, n)
+		}
+		for _, shift := range shifts {
+			if a.Start+shift >= start && a.End+shift <= end {
+				a.Start += shift - start
+				a.End += shift - start
+				result = append(result, a)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// End is an end left by a restriction enzyme. Overhang is as for
+// restriction.Enzyme, and Seq is the single stranded bases, read along the
+// top strand.
+type End struct {
+	Enzyme   string
+	Overhang int
+	Seq      string
+}
+
+// joins reports whether a right end can be ligated to a left one.
+func (e *End) joins(left *End) bool {
+	return e != nil && left != nil && e.Overhang == left.Overhang && e.Seq == left.Seq
+}
+
+func (e *End) String() string {
+	if e == nil {
+		return "an uncut end"
+	}
+	switch {
+	case e.Overhang > 0:
+		return fmt.Sprintf("a %s 5' overhang (%s)", e.Seq, e.Enzyme)
+	case e.Overhang < 0:
+		return fmt.Sprintf("a %s 3' overhang (%s)", e.Seq, e.Enzyme)
+	}
+	return fmt.Sprintf("a blunt end (%s)", e.Enzyme)
+}
+
+// Piece is a linear fragment ready to assemble. Sequence is its top strand,
+// from cut to cut for digested pieces, and Left and Right are the ends left
+// by enzymes, if any.
+type Piece struct {
+	Name        string
+	Sequence    string
+	Left        *End
+	Right       *End
+	Annotations []models.Annotation
+}
+
+// Linear makes a piece of a linear part, as is.
+func Linear(p Part) Piece {
+	return Piece{Name: p.Name, Sequence: p.Sequence, Annotations: p.Annotations}
+}
+
+// Flipped returns p the other way round. Its top strand is p's bottom
+// strand, which is offset from the top strand by the overhangs at each end.
+// Pieces too short to have a bottom strand between their overhangs, e.g. from
+// cuts a few bases apart, are an error.
+func (p Piece) Flipped() (Piece, error) {
+	single := 0
+	if p.Left != nil && p.Left.Overhang > 0 {
+		single += p.Left.Overhang
+	}
+	if p.Right != nil && p.Right.Overhang < 0 {
+		single -= p.Right.Overhang
+	}
+	if len(p.Sequence) < single {
+		return Piece{}, errorf("%s is only %d bp, shorter than its overhangs", p.Name, len(p.Sequence))
+	}
+	bottom := p.Sequence
+	shift := 0
+	if p.Left != nil {
+		shift = p.Left.Overhang
+		if shift > 0 {
+			bottom = bottom[shift:]
+		} else {
+			bottom = p.Left.Seq + bottom
+		}
+	}
+	if p.Right != nil {
+		if p.Right.Overhang > 0 {
+			bottom += p.Right.Seq
+		} else {
+			bottom = bottom[:len(bottom)+p.Right.Overhang]
+		}
+	}
+	n := len(bottom)
+	flipped := Piece{
+		Name:        p.Name + " (reversed)",
+		Sequence:    sequence.ReverseComplement(bottom),
+		Left:        flipEnd(p.Right),
+		Right:       flipEnd(p.Left),
+		Annotations: []models.Annotation{},
+	}
+	for _, a := range p.Annotations {
+		a.Start, a.End = n-(a.End-shift), n-(a.Start-shift)
+		if a.Start < 0 || a.End > n {
+			// In an overhang that isn't part of the bottom strand.
+			continue
+		}
+		a.Strand = -a.Strand
+		flipped.Annotations = append(flipped.Annotations, a)
+	}
+	return flipped, nil
+}
+
+func flipEnd(e *End) *End {
+	if e == nil {
+		return nil
+	}
+	return &End{Enzyme: e.Enzyme, Overhang: e.Overhang, Seq: sequence.ReverseComplement(e.Seq)}
+}
+
+// Digest cuts p with enzymes, returning the pieces in order along it.
+func Digest(p Part, enzymes []*restriction.Enzyme) ([]Piece, error) {
+	n := len(p.Sequence)
+	end := func(c *restriction.Cut) (*End, error) {
+		if c == nil {
+			return nil, nil
+		}
+		lo, hi := c.Position, c.Position+c.Overhang
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		if lo < 0 && p.Circular {
+			lo, hi = lo+n, hi+n
+		}
+		seq, err := wrapped(p.Sequence, lo, hi, p.Circular)
+		if err != nil {
+			return nil, err
+		}
+		return &End{Enzyme: c.Name, Overhang: c.Overhang, Seq: seq}, nil
+	}
+	pieces := []Piece{}
+	for i, f := range restriction.Digest(enzymes, p.Sequence, p.Circular) {
+		if f.Left == nil && f.Right == nil && p.Circular {
+			return nil, errorf("%s isn't cut", p.Name)
+		}
+		seq, err := wrapped(p.Sequence, f.Start, f.End, p.Circular)
+		if err != nil {
+			return nil, err
+		}
+		piece := Piece{
+			Name:        fmt.Sprintf("%s fragment %d", p.Name, i+1),
+			Sequence:    seq,
+			Annotations: within(p.Annotations, f.Start, f.End, n, p.Circular),
+		}
+		if piece.Left, err = end(f.Left); err != nil {
+			return nil, err
+		}
+		if piece.Right, err = end(f.Right); err != nil {
+			return nil, err
+		}
+		pieces = append(pieces, piece)
+	}
+	return pieces, nil
+}
+
+// PCRProduct returns the product of amplifying template with a pair of
+// primers, either of which may be the forward one. Tails on the primers are
+// included, and there must be exactly one product.
+func PCRProduct(template Part, name string, primerA, primerB string, maxMismatches int) (Part, error) {
+	primerA, primerB = sequence.Normalize(primerA), sequence.Normalize(primerB)
+	n := len(template.Sequence)
+	sitesA := sequence.BindingSites(primerA, template.Sequence, template.Circular, maxMismatches)
+	sitesB := sequence.BindingSites(primerB, template.Sequence, template.Circular, maxMismatches)
+	type candidate struct {
+		product          sequence.Product
+		forward, reverse string
+	}
+	candidates := []candidate{}
+	for _, p := range sequence.Products(sitesA, sitesB, n, template.Circular) {
+		candidates = append(candidates, candidate{p, primerA, primerB})
+	}
+	for _, p := range sequence.Products(sitesB, sitesA, n, template.Circular) {
+		candidates = append(candidates, candidate{p, primerB, primerA})
+	}
+	if len(candidates) == 0 {
+		return Part{}, errorf("the primers don't give a product from %s", template.Name)
+	}
+	if len(candidates) > 1 {
+		return Part{}, errorf("the primers give %d products from %s", len(candidates), template.Name)
+	}
+	c := candidates[0]
+	// The primers themselves, with any tails, then the template between them.
+	start, end := c.product.Forward.End, c.product.End-len(c.reverse)
+	if end < start {
+		return Part{}, errorf("the primers overlap on %s", template.Name)
+	}
+	middle, err := wrapped(template.Sequence, start, end, template.Circular)
+	if err != nil {
+		return Part{}, err
+	}
+	annotations := []models.Annotation{}
+	for _, a := range within(template.Annotations, start, end, n, template.Circular) {
+		a.Start += len(c.forward)
+		a.End += len(c.forward)
+		annotations = append(annotations, a)
+	}
+	return Part{
+		Name:        name,
+		Sequence:    c.forward + middle + sequence.ReverseComplement(c.reverse),
+		Annotations: annotations,
+	}, nil
+}
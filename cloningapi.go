@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"labdb.org/labdb/auth"
+	"labdb.org/labdb/cloning"
+	"labdb.org/labdb/models"
+	"labdb.org/labdb/restriction"
+
+	"github.com/gin-gonic/gin"
+)
+
+// partSpec names a part for an assembly: an item, a region of one (if Start
+// and End are given) or the PCR product of a pair of oligos on one (if
+// Forward and Reverse are).
+type partSpec struct {
+	Model   string `json:"model"`
+	ID      int    `json:"id"`
+	Start   *int   `json:"start"`
+	End     *int   `json:"end"`
+	Forward int    `json:"forward"`
+	Reverse int    `json:"reverse"`
+}
+
+type assemblyRequest struct {
+	Method   cloning.Method `json:"method"`
+	Backbone partSpec       `json:"backbone"`
+	Inserts  []partSpec     `json:"inserts"`
+	Enzymes  []string       `json:"enzymes"`
+	// If Save is set, the result is saved as a new plasmid.
+	Save        bool   `json:"save"`
+	Alias       string `json:"alias"`
+	Description string `json:"description"`
+}
+
+// errLookup is returned by resolvePart for failures that aren't the
+// request's fault.
+type errLookup struct {
+	err error
+}
+
+func (e errLookup) Error() string {
+	return e.err.Error()
+}
+
+// errForbidden is returned by resolvePart for items the user can't see.
+type errForbidden struct {
+	kind string
+}
+
+func (e errForbidden) Error() string {
+	return "you can't see " + e.kind + "s"
+}
+
+// resolvePart loads the part spec names, adding the items it comes from to
+// parents.
+func resolvePart(c *gin.Context, s *models.Store, spec partSpec, role string, parents *[]models.Parent) (cloning.Part, error) {
+	if spec.Model == "" {
+		spec.Model = "plasmid"
+	}
+	p, err := auth.CurrentPermissions(c, s)
+	if err != nil {
+		return cloning.Part{}, errLookup{err}
+	}
+	get := func(model string, id int) (models.Entity, error) {
+		e, err := s.GetByID(c.Request.Context(), model, id)
+		if err == models.ErrNotFound {
+			return nil, fmt.Errorf("no %s %d", model, id)
+		}
+		if err != nil {
+			return nil, errLookup{err}
+		}
+		if !p.CanRead(models.KindOf(e)) {
+			return nil, errForbidden{models.KindOf(e)}
+		}
+		return e, nil
+	}
+	e, err := get(spec.Model, spec.ID)
+	if err != nil {
+		return cloning.Part{}, err
+	}
+	annotations, err := s.Annotations(c.Request.Context(), e)
+	if err != nil {
+		return cloning.Part{}, errLookup{err}
+	}
+	part := cloning.PartFrom(e, annotations)
+	if part.Sequence == "" {
+		return cloning.Part{}, fmt.Errorf("%s has no sequence", part.Name)
+	}
+
+	switch {
+	case spec.Forward != 0 || spec.Reverse != 0:
+		*parents = append(*parents, models.ParentOf(e, "template"))
+		primers := []string{}
+		names := []string{}
+		for _, id := range []int{spec.Forward, spec.Reverse} {
+			o, err := get("oligo", id)
+			if err != nil {
+				return cloning.Part{}, err
+			}
+			*parents = append(*parents, models.ParentOf(o, "primer"))
+			primers = append(primers, o.GetSequence())
+			names = append(names, models.NameOf(o))
+		}
+		name := fmt.Sprintf("PCR of %s with %s", part.Name, strings.Join(names, "/"))
+		return cloning.PCRProduct(part, name, primers[0], primers[1], defaultMaxMismatches)
+	case spec.Start != nil || spec.End != nil:
+		if spec.Start == nil || spec.End == nil {
+			return cloning.Part{}, fmt.Errorf("regions need a start and an end")
+		}
+		*parents = append(*parents, models.ParentOf(e, role))
+		return part.Region(*spec.Start, *spec.End)
+	}
+	*parents = append(*parents, models.ParentOf(e, role))
+	return part, nil
+}
+
+func cloningAPI(r *gin.Engine, s *models.Store) {
+	// Simulates an assembly, saving the result as a new plasmid if asked to.
+	r.POST("/api/v1/cloning/assemble", func(c *gin.Context) {
+		req := assemblyRequest{}
+		if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+			c.String(400, "Bad assembly: %s", err.Error())
+			return
+		}
+		if req.Backbone.Model != "" && req.Backbone.Model != "plasmid" {
+			c.String(400, "The backbone has to be a plasmid")
+			return
+		}
+		if req.Method == cloning.GoldenGate && len(req.Enzymes) == 0 {
+			req.Enzymes = []string{cloning.DefaultGoldenGateEnzyme}
+		}
+		assembly := &cloning.Assembly{Method: req.Method}
+		for _, name := range req.Enzymes {
+			e, ok := restriction.Lookup(name)
+			if !ok {
+				c.String(400, "Unknown enzyme %s", name)
+				return
+			}
+			assembly.Enzymes = append(assembly.Enzymes, e)
+		}
+
+		parents := []models.Parent{}
+		fail := func(err error) {
+			if lookup, ok := err.(errLookup); ok {
+				c.AbortWithError(500, lookup.err)
+				return
+			}
+			if _, ok := err.(errForbidden); ok {
+				c.String(403, "Can't assemble: %s", err.Error())
+				return
+			}
+			c.String(400, "Can't assemble: %s", err.Error())
+		}
+		var err error
+		if assembly.Backbone, err = resolvePart(c, s, req.Backbone, "backbone", &parents); err != nil {
+			fail(err)
+			return
+		}
+		for _, spec := range req.Inserts {
+			part, err := resolvePart(c, s, spec, "insert", &parents)
+			if err != nil {
+				fail(err)
+				return
+			}
+			assembly.Inserts = append(assembly.Inserts, part)
+		}
+		result, err := assembly.Run()
+		if err != nil {
+			fail(err)
+			return
+		}
+		if !req.Save {
+			c.JSON(200, gin.H{
+				"sequence":    result.Sequence,
+				"length":      len(result.Sequence),
+				"annotations": result.Annotations,
+				"warnings":    result.Warnings,
+			})
+			return
+		}
+
+		if !canCreate(c, s, "plasmid") {
+			return
+		}
+		u, err := auth.CurrentUser(c, s)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		p := &models.Plasmid{}
+		p.AutoFill(u.Name)
+		p.Alias = req.Alias
+		p.Sequence = result.Sequence
+		p.Description = req.Description
+		if p.Description == "" {
+			names := []string{}
+			for _, part := range assembly.Inserts {
+				names = append(names, part.Name)
+			}
+			p.Description = fmt.Sprintf("%s assembly of %s into %s.", req.Method.Name(), strings.Join(names, ", "), assembly.Backbone.Name)
+		}
+		if err := s.CreateWith(c.Request.Context(), p, result.Annotations, parents); err != nil {
+			writeError(c, err)
+			return
+		}
+		p.Features = result.Annotations
+		c.JSON(201, gin.H{
+			"plasmid":  models.AsResourceDef(p),
+			"warnings": result.Warnings,
+		})
+	})
+
+	// What an item was made from.
+	r.GET("/api/v1/m/:model/:id/parents", func(c *gin.Context) {
+		m, ok := existingModel(c, s)
+		if !ok {
+			return
+		}
+		parents, err := s.Parents(c.Request.Context(), m)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		c.JSON(200, gin.H{"parents": parents})
+	})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"labdb.org/labdb/auth"
+	"labdb.org/labdb/models"
+	"labdb.org/labdb/primers"
+	"labdb.org/labdb/sequence"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultPrimerPairs = 5
+
+type designRequest struct {
+	Start       int                 `json:"start"`
+	End         int                 `json:"end"`
+	Count       int                 `json:"count"`
+	Constraints primers.Constraints `json:"constraints"`
+}
+
+// designedPair is a designed pair of primers along with the other plasmids
+// they'd prime from.
+type designedPair struct {
+	primers.Pair
+	OffTargets []models.OffTarget `json:"offTargets"`
+}
+
+// acceptedPrimer is a designed primer to save as an oligo.
+type acceptedPrimer struct {
+	Sequence string `json:"sequence"`
+	Alias    string `json:"alias"`
+	Purpose  string `json:"purpose"`
+}
+
+func designAPI(r *gin.Engine, s *models.Store) {
+	// As with /api/v1/verify, POSTs about an item can't go in /api/v1/m.
+	api := r.Group("/api/v1/design")
+
+	// Designs primer pairs amplifying start:end of an item's sequence and
+	// checks them against every other stored plasmid. Constraints not given
+	// take their defaults.
+	api.POST("/:model/:id/primers", func(c *gin.Context) {
+		m, ok := sequenceModel(c, s)
+		if !ok {
+			return
+		}
+		req := designRequest{Count: defaultPrimerPairs, Constraints: primers.DefaultConstraints}
+		if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+			c.String(400, "Bad design request: %s", err.Error())
+			return
+		}
+		if req.Count <= 0 {
+			c.String(400, "Bad count")
+			return
+		}
+		seq := sequence.Normalize(m.GetSequence())
+		pairs, err := primers.Design(seq, m.IsCircular(), req.Start, req.End, req.Constraints, req.Count)
+		if err != nil {
+			c.String(400, "Can't design primers: %s", err.Error())
+			return
+		}
+		plasmids, err := s.WithSequences(c.Request.Context(), "plasmid")
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		perms, err := auth.CurrentPermissions(c, s)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		// Only report off-targets the user could look up.
+		plasmids = readable(perms, plasmids)
+		designed := []designedPair{}
+		for _, p := range pairs {
+			designed = append(designed, designedPair{
+				Pair:       p,
+				OffTargets: models.OffTargets(p.Forward.Sequence, p.Reverse.Sequence, plasmids, m, defaultMaxMismatches),
+			})
+		}
+		c.JSON(200, gin.H{
+			"template":    templateInfo(m),
+			"constraints": req.Constraints,
+			"pairs":       designed,
+		})
+	})
+
+	// Saves accepted primers as new oligos, linked to the item they were
+	// designed for.
+	api.POST("/:model/:id/oligos", func(c *gin.Context) {
+		m, ok := sequenceModel(c, s)
+		if !ok {
+			return
+		}
+		accepted := []acceptedPrimer{}
+		if err := json.NewDecoder(c.Request.Body).Decode(&accepted); err != nil {
+			c.String(400, "Bad primers: %s", err.Error())
+			return
+		}
+		if len(accepted) == 0 {
+			c.String(400, "No primers")
+			return
+		}
+		if !canCreate(c, s, "oligo") {
+			return
+		}
+		for i := range accepted {
+			accepted[i].Sequence = sequence.Normalize(accepted[i].Sequence)
+			if !sequence.IsDNA(accepted[i].Sequence) {
+				c.String(400, "Primer %d isn't a DNA sequence", i+1)
+				return
+			}
+		}
+		u, err := auth.CurrentUser(c, s)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		oligos := []models.Entity{}
+		for _, p := range accepted {
+			o := &models.Oligo{}
+			o.AutoFill(u.Name)
+			o.Sequence = p.Sequence
+			o.Oligoalias = p.Alias
+			o.Purpose = p.Purpose
+			if o.Purpose == "" {
+				o.Purpose = fmt.Sprintf("Designed for %s.", models.NameOf(m))
+			}
+			oligos = append(oligos, o)
+		}
+		if err := s.CreateAll(c.Request.Context(), oligos, []models.Parent{models.ParentOf(m, "template")}); err != nil {
+			writeError(c, err)
+			return
+		}
+		created := []models.ResourceDef{}
+		for _, o := range oligos {
+			created = append(created, models.AsResourceDef(o))
+		}
+		c.JSON(201, created)
+	})
+}
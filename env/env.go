@@ -11,6 +11,10 @@ var SigningKey string
 var DbURL = os.Getenv("DATABASE_URL")
 var DebugDB = os.Getenv("DB_DEBUG") == "1"
 
+// PreserveSequenceCase keeps the case of sequences as entered, instead of
+// upper casing them.
+var PreserveSequenceCase = os.Getenv("PRESERVE_SEQUENCE_CASE") == "1"
+
 func init() {
 	if Dev {
 		SigningKey = "development-key"
@@ -29,3 +33,20 @@ func init() {
 		}
 	}
 }
+
+// IdentityProvider selects how users sign in: "google" (the default), "oidc"
+// or "saml". See auth.ProviderFromEnv.
+var IdentityProvider = os.Getenv("IDENTITY_PROVIDER")
+var GoogleClientID = os.Getenv("GOOGLE_CLIENT_ID")
+var OIDCIssuer = os.Getenv("OIDC_ISSUER")
+var OIDCClientID = os.Getenv("OIDC_CLIENT_ID")
+
+// OIDCRequireVerifiedEmail rejects ID tokens without email_verified. It's on
+// unless set to 0, for providers that don't send it, like Entra ID.
+var OIDCRequireVerifiedEmail = os.Getenv("OIDC_REQUIRE_VERIFIED_EMAIL") != "0"
+var SAMLIdPEntityID = os.Getenv("SAML_IDP_ENTITY_ID")
+var SAMLIdPCert = os.Getenv("SAML_IDP_CERT")
+var SAMLEntityID = os.Getenv("SAML_SP_ENTITY_ID")
+
+// SAMLACSURL is the URL the IdP posts responses to, i.e. labdb's /api/verify.
+var SAMLACSURL = os.Getenv("SAML_ACS_URL")
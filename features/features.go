@@ -0,0 +1,151 @@
+// Package features finds common plasmid features (resistance genes, origins,
+// promoters, tags and so on) in sequences by comparing them to a library of
+// known ones.
+package features
+
+import (
+	"sort"
+
+	"labdb.org/labdb/sequence"
+)
+
+// Def is a known feature. Exactly one of DNA and Protein is set: DNA features
+// are matched directly, and protein ones against the translation of all six
+// frames.
+type Def struct {
+	Name string
+	// Type is the GenBank feature key, e.g. CDS or promoter.
+	Type    string
+	DNA     string
+	Protein string
+}
+
+// length is the length of d in bases.
+func (d *Def) length() int {
+	if d.Protein != "" {
+		return 3 * len(d.Protein)
+	}
+	return len(d.DNA)
+}
+
+// maxMismatches is how different from the library a feature can be and still
+// be found: up to 1 in 20 bases or residues, so short features have to match
+// exactly.
+func (d *Def) maxMismatches() int {
+	if d.Protein != "" {
+		return len(d.Protein) / 20
+	}
+	return len(d.DNA) / 20
+}
+
+// Hit is a place a library feature was found, in the same coordinates as
+// sequence.Match. Mismatches counts bases for DNA features and residues for
+// protein ones.
+type Hit struct {
+	Def        *Def
+	Start      int
+	End        int
+	Strand     sequence.Strand
+	Mismatches int
+}
+
+// Scan finds the features in library along seq, which should be normalized.
+// Hits are ordered by position.
+func Scan(library []Def, seq string, circular bool) []Hit {
+	hits := []Hit{}
+	n := len(seq)
+	if n == 0 {
+		return hits
+	}
+
+	// Protein features are found in the six frame translation of seq,
+	// extended past the origin for circular sequences.
+	ext := seq
+	if circular {
+		longest := 0
+		for i := range library {
+			if l := library[i].length(); l > longest {
+				longest = l
+			}
+		}
+		if longest > n {
+			longest = n
+		}
+		ext = seq + seq[:longest]
+	}
+	rcExt := sequence.ReverseComplement(ext)
+	type frame struct {
+		strand      sequence.Strand
+		offset      int
+		translation string
+	}
+	frames := []frame{}
+	for offset := 0; offset < 3 && offset < len(ext); offset++ {
+		frames = append(frames,
+			frame{sequence.Forward, offset, sequence.Translate(ext[offset:])},
+			frame{sequence.Reverse, offset, sequence.Translate(rcExt[offset:])})
+	}
+
+	seen := map[Hit]bool{}
+	add := func(h Hit) {
+		if h.Start >= n {
+			h.Start -= n
+			h.End -= n
+		}
+		if h.End > n && !circular {
+			return
+		}
+		if !seen[h] {
+			seen[h] = true
+			hits = append(hits, h)
+		}
+	}
+	for i := range library {
+		def := &library[i]
+		if def.DNA != "" {
+			for _, m := range sequence.Find(def.DNA, seq, def.maxMismatches(), circular) {
+				add(Hit{Def: def, Start: m.Start, End: m.End, Strand: m.Strand, Mismatches: m.Mismatches})
+			}
+			continue
+		}
+		length := def.length()
+		for _, f := range frames {
+			for _, at := range findProtein(def.Protein, f.translation, def.maxMismatches()) {
+				h := Hit{Def: def, Strand: f.strand, Mismatches: at.mismatches}
+				start := f.offset + 3*at.pos
+				if f.strand == sequence.Forward {
+					h.Start = start
+				} else {
+					h.Start = len(ext) - start - length
+				}
+				h.End = h.Start + length
+				add(h)
+			}
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Start < hits[j].Start })
+	return hits
+}
+
+type proteinMatch struct {
+	pos        int
+	mismatches int
+}
+
+// findProtein finds where protein occurs in translation with at most
+// maxMismatches substitutions.
+func findProtein(protein, translation string, maxMismatches int) []proteinMatch {
+	matches := []proteinMatch{}
+	for i := 0; i+len(protein) <= len(translation); i++ {
+		mm := 0
+		for j := 0; j < len(protein) && mm <= maxMismatches; j++ {
+			if protein[j] != translation[i+j] {
+				mm++
+			}
+		}
+		if mm <= maxMismatches {
+			matches = append(matches, proteinMatch{i, mm})
+		}
+	}
+	return matches
+}
@@ -0,0 +1,54 @@
+package features
+
+// Library is the built in set of common features. Sites with their own
+// vectors can append to it at startup.
+var Library = []Def{
+	// Resistance genes
+	{
+		Name:    "AmpR",
+		Type:    "CDS",
+		Protein: "MSIQHFRVALIPFFAAFCLPVFAHPETLVKVKDAEDQLGARVGYIELDLNSGKILESFRPEERFPMMSTFKVLLCGAVLSRIDAGQEQLGRRIHYSQNDLVEYSPVTEKHLTDGMTVRELCSAAITMSDNTAANLLLTTIGGPKELTAFLHNMGDHVTRLDRWEPELNEAIPNDERDTTMPVAMATTLRKLLTGELLTLASRQQLIDWMEADKVAGPLLRSALPAGWFIADKSGAGERGSRGIIAALGPDGKPSRIVVIYTTGSQATMDERNRQIAEIGASLIKHW",
+	},
+	{
+		Name:    "KanR",
+		Type:    "CDS",
+		Protein: "MIEQDGLHAGSPAAWVERLFGYDWAQQTIGCSDAAVFRLSAQGRPVLFVKTDLSGALNELQDEAARLSWLATTGVPCAAVLDVVTEAGRDWLLLGEVPGQDLLSSHLAPAEKVSIMADAMRRLHTLDPATCPFDHQAKHRIERARTRMEAGLVDQDDLDEEHQGLAPAELFARLKARMPDGEDLVVTHGDACLPNIMVENGRFSGFIDCGRLGVADRYQDIALATRDIAEELGGEWADRFLVLYGIAAPDSQRIAFYRLLDEFF",
+	},
+	{
+		Name:    "CmR",
+		Type:    "CDS",
+		Protein: "MEKKITGYTTVDISQWHRKEHFEAFQSVAQCTYNQTVQLDITAFLKTVKKNKHKFYPAFIHILARLMNAHPEFRMAMKDGELVIWDSVHPCYTVFHEQTETFSSLWSEYHDDFRQFLHIYSQDVACYGENLAYFPKGFIENMFFVSANPWVSFTSFDLNVANMDNFFAPVFTMGKYYTQGDKVLMPLAIQVHHAVCDGFHVGRMLNELQQYCDEWQGGA",
+	},
+
+	// Origins
+	{
+		Name: "ori",
+		Type: "rep_origin",
+		DNA:  "TTGAGATCCTTTTTTTCTGCGCGTAATCTGCTGCTTGCAAACAAAAAAACCACCGCTACCAGCGGTGGTTTGTTTGCCGGATCAAGAGCTACCAACTCTTTTTCCGAAGGTAACTGGCTTCAGCAGAGCGCAGATACCAAATACTGTTCTTCTAGTGTAGCCGTAGTTAGGCCACCACTTCAAGAACTCTGTAGCACCGCCTACATACCTCGCTCTGCTAATCCTGTTACCAGTGGCTGCTGCCAGTGGCGATAAGTCGTGTCTTACCGGGTTGGACTCAAGACGATAGTTACCGGATAAGGCGCAGCGGTCGGGCTGAACGGGGGGTTCGTGCACACAGCCCAGCTTGGAGCGAACGACCTACACCGAACTGAGATACCTACAGCGTGAGCTATGAGAAAGCGCCACGCTTCCCGAAGGGAGAAAGGCGGACAGGTATCCGGTAAGCGGCAGGGTCGGAACAGGAGAGCGCACGAGGGAGCTTCCAGGGGGAAACGCCTGGTATCTTTATAGTCCTGTCGGGTTTCGCCACCTCTGACTTGAGCGTCGATTTTTGTGATGCTCGTCAGGGGGGCGGAGCCTATGGAAAAA",
+	},
+
+	// Promoters, operators and terminators
+	{Name: "T7 promoter", Type: "promoter", DNA: "TAATACGACTCACTATAGG"},
+	{Name: "T3 promoter", Type: "promoter", DNA: "AATTAACCCTCACTAAAGG"},
+	{Name: "SP6 promoter", Type: "promoter", DNA: "ATTTAGGTGACACTATAG"},
+	{Name: "lac promoter", Type: "promoter", DNA: "TTTACACTTTATGCTTCCGGCTCGTATGTTG"},
+	{Name: "tac promoter", Type: "promoter", DNA: "TTGACAATTAATCATCGGCTCGTATAATG"},
+	{Name: "lac operator", Type: "protein_bind", DNA: "TTGTGAGCGGATAACAA"},
+	{Name: "T7 terminator", Type: "terminator", DNA: "CTAGCATAACCCCTTGGGGCCTCTAAACGGGTCTTGAGGGGTTTTTTG"},
+
+	// Primer sites
+	{Name: "M13 fwd", Type: "primer_bind", DNA: "GTAAAACGACGGCCAGT"},
+	{Name: "M13 rev", Type: "primer_bind", DNA: "CAGGAAACAGCTATGAC"},
+
+	// Tags and reporters
+	{Name: "6xHis", Type: "CDS", Protein: "HHHHHH"},
+	{Name: "FLAG", Type: "CDS", Protein: "DYKDDDDK"},
+	{Name: "HA", Type: "CDS", Protein: "YPYDVPDYA"},
+	{Name: "Myc", Type: "CDS", Protein: "EQKLISEEDL"},
+	{Name: "V5", Type: "CDS", Protein: "GKPIPNPLLGLDST"},
+	{
+		Name:    "EGFP",
+		Type:    "CDS",
+		Protein: "MVSKGEELFTGVVPILVELDGDVNGHKFSVSGEGEGDATYGKLTLKFICTTGKLPVPWPTLVTTLTYGVQCFSRYPDHMKQHDFFKSAMPEGYVQERTIFFKDDGNYKTRAEVKFEGDTLVNRIELKGIDFKEDGNILGHKLEYNYNSHNVYIMADKQKNGIKVNFKIRHNIEDGSVQLADHYQQNTPIGDGPVLLPDNHYLSTQSALSKDPNEKRDHMVLLEFVTAAGITLGMDELYK",
+	},
+}
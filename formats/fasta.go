@@ -0,0 +1,99 @@
+// Package formats reads and writes the sequence file formats labdb exchanges
+// with other tools.
+package formats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"labdb.org/labdb/sequence"
+)
+
+// lineWidth is how many bases are written per line.
+const lineWidth = 60
+
+// ParseError describes where in a file parsing failed.
+type ParseError struct {
+	Line int
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// Record is a single FASTA entry.
+type Record struct {
+	Name        string
+	Description string
+	Sequence    string
+}
+
+// ParseFASTA reads all the records in r. Sequences are normalized.
+func ParseFASTA(r io.Reader) ([]Record, error) {
+	records := []Record{}
+	var current *Record
+	var seq strings.Builder
+	finish := func() {
+		if current != nil {
+			current.Sequence = sequence.Normalize(seq.String())
+			records = append(records, *current)
+		}
+		seq.Reset()
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, ";") {
+			continue
+		}
+		if strings.HasPrefix(text, ">") {
+			finish()
+			header := strings.TrimSpace(text[1:])
+			current = &Record{Name: header}
+			if i := strings.IndexAny(header, " \t"); i >= 0 {
+				current.Name = header[:i]
+				current.Description = strings.TrimSpace(header[i+1:])
+			}
+			continue
+		}
+		if current == nil {
+			return nil, &ParseError{Line: line, Msg: "sequence before the first > header"}
+		}
+		seq.WriteString(text)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	finish()
+	if len(records) == 0 {
+		return nil, &ParseError{Line: line, Msg: "no FASTA records"}
+	}
+	return records, nil
+}
+
+// WriteFASTA writes records to w.
+func WriteFASTA(w io.Writer, records []Record) error {
+	bw := bufio.NewWriter(w)
+	for _, rec := range records {
+		header := rec.Name
+		if rec.Description != "" {
+			header += " " + rec.Description
+		}
+		fmt.Fprintf(bw, ">%s\n", header)
+		for i := 0; i < len(rec.Sequence); i += lineWidth {
+			end := i + lineWidth
+			if end > len(rec.Sequence) {
+				end = len(rec.Sequence)
+			}
+			fmt.Fprintln(bw, rec.Sequence[i:end])
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,420 @@
+package formats
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"labdb.org/labdb/sequence"
+)
+
+// GenBank is a single GenBank flat file entry.
+type GenBank struct {
+	Locus      string
+	Molecule   string
+	Circular   bool
+	Definition string
+	Features   []Feature
+	Sequence   string
+}
+
+type Qualifier struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type Feature struct {
+	Type       string
+	Location   Location
+	Qualifiers []Qualifier
+}
+
+// Label returns the feature's name, taken from the qualifiers GenBank files
+// usually put it in.
+func (f Feature) Label() string {
+	for _, key := range []string{"label", "gene", "product", "standard_name", "note"} {
+		for _, q := range f.Qualifiers {
+			if q.Key == key && q.Value != "" {
+				return q.Value
+			}
+		}
+	}
+	return f.Type
+}
+
+// Span is a 0-based, end-exclusive range.
+type Span struct {
+	Start int
+	End   int
+}
+
+// Location is where a feature lies on the sequence. Features split into
+// several parts (join(...)) have one span per part, in order.
+type Location struct {
+	Parts  []Span
+	Strand int
+}
+
+// Bounds returns the extent of the location on a sequence of length n. As
+// elsewhere, end is past n for features spanning the origin of a circular
+// sequence.
+func (l Location) Bounds(n int) (start int, end int) {
+	if len(l.Parts) == 0 {
+		return 0, 0
+	}
+	start, end = l.Parts[0].Start, l.Parts[len(l.Parts)-1].End
+	if end < start || (end == start && len(l.Parts) > 1) {
+		end += n
+	}
+	return start, end
+}
+
+// ErrRemoteLocation is returned by ParseLocation for locations on another
+// sequence, like J00194.1:100..202.
+var ErrRemoteLocation = errors.New("location on another sequence")
+
+// ParseLocation parses a GenBank location like 12..340,
+// complement(join(1..10,20..30)) or <1..>200. Partial markers are dropped.
+func ParseLocation(s string) (Location, error) {
+	s = strings.Replace(s, " ", "", -1)
+	if inner, ok := unwrap(s, "complement"); ok {
+		loc, err := ParseLocation(inner)
+		loc.Strand = -loc.Strand
+		if loc.Strand == 0 {
+			loc.Strand = -1
+		}
+		return loc, err
+	}
+	inner, isJoin := unwrap(s, "join")
+	if !isJoin {
+		inner, isJoin = unwrap(s, "order")
+	}
+	if isJoin {
+		loc := Location{Strand: -1}
+		for _, part := range splitTopLevel(inner) {
+			p, err := ParseLocation(part)
+			if err != nil {
+				return Location{}, err
+			}
+			loc.Parts = append(loc.Parts, p.Parts...)
+			if p.Strand > 0 {
+				loc.Strand = 1
+			}
+		}
+		if loc.Strand < 0 {
+			// join(complement(b),complement(a)) lists the parts along the
+			// reverse strand; keep them in forward order.
+			for i, j := 0, len(loc.Parts)-1; i < j; i, j = i+1, j-1 {
+				loc.Parts[i], loc.Parts[j] = loc.Parts[j], loc.Parts[i]
+			}
+		}
+		return loc, nil
+	}
+
+	if strings.Contains(s, ":") {
+		return Location{}, fmt.Errorf("%w: %q", ErrRemoteLocation, s)
+	}
+	s = strings.NewReplacer("<", "", ">", "").Replace(s)
+	if i := strings.Index(s, "^"); i >= 0 {
+		// Between two bases: a zero length span.
+		after, err := strconv.Atoi(s[:i])
+		if err != nil || after < 0 {
+			return Location{}, fmt.Errorf("bad location %q", s)
+		}
+		return Location{Parts: []Span{{Start: after, End: after}}, Strand: 1}, nil
+	}
+	startStr, endStr := s, s
+	if i := strings.Index(s, ".."); i >= 0 {
+		startStr, endStr = s[:i], s[i+2:]
+	}
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return Location{}, fmt.Errorf("bad location %q", s)
+	}
+	end, err := strconv.Atoi(endStr)
+	if err != nil || end < start || start < 1 {
+		return Location{}, fmt.Errorf("bad location %q", s)
+	}
+	return Location{Parts: []Span{{Start: start - 1, End: end}}, Strand: 1}, nil
+}
+
+func unwrap(s string, fn string) (string, bool) {
+	if strings.HasPrefix(s, fn+"(") && strings.HasSuffix(s, ")") {
+		return s[len(fn)+1 : len(s)-1], true
+	}
+	return "", false
+}
+
+// splitTopLevel splits s on commas that aren't inside parentheses.
+func splitTopLevel(s string) []string {
+	parts := []string{}
+	depth := 0
+	last := 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(parts, s[last:])
+}
+
+func (l Location) String() string {
+	if len(l.Parts) == 0 {
+		return ""
+	}
+	parts := []string{}
+	for _, p := range l.Parts {
+		switch p.End - p.Start {
+		case 0:
+			parts = append(parts, fmt.Sprintf("%d^%d", p.Start, p.Start+1))
+		case 1:
+			parts = append(parts, strconv.Itoa(p.End))
+		default:
+			parts = append(parts, fmt.Sprintf("%d..%d", p.Start+1, p.End))
+		}
+	}
+	s := parts[0]
+	if len(parts) > 1 {
+		s = "join(" + strings.Join(parts, ",") + ")"
+	}
+	if l.Strand < 0 {
+		s = "complement(" + s + ")"
+	}
+	return s
+}
+
+// Columns of the feature table.
+const (
+	featureKeyCol = 5
+	featureValCol = 21
+)
+
+// ParseGenBank reads the first entry in r. Features on other sequences are
+// skipped.
+func ParseGenBank(r io.Reader) (*GenBank, error) {
+	gb := &GenBank{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	section := ""
+	var seq strings.Builder
+	var feature *Feature
+	var locationText string
+	featureLine := 0
+	finishFeature := func() error {
+		if feature == nil {
+			return nil
+		}
+		loc, err := ParseLocation(locationText)
+		if errors.Is(err, ErrRemoteLocation) {
+			// Not (all) on this sequence, so there's nowhere to put it.
+			feature = nil
+			return nil
+		}
+		if err != nil {
+			return &ParseError{Line: featureLine, Msg: err.Error()}
+		}
+		feature.Location = loc
+		gb.Features = append(gb.Features, *feature)
+		feature = nil
+		return nil
+	}
+	// inQualifier is set while a quoted qualifier value continues onto
+	// following lines.
+	inQualifier := false
+
+	sawLocus := false
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+		if text == "//" {
+			break
+		}
+		if text == "" {
+			continue
+		}
+		if text[0] != ' ' {
+			keyword := strings.Fields(text)[0]
+			rest := strings.TrimSpace(strings.TrimPrefix(text, keyword))
+			section = keyword
+			switch keyword {
+			case "LOCUS":
+				sawLocus = true
+				fields := strings.Fields(rest)
+				if len(fields) == 0 {
+					return nil, &ParseError{Line: line, Msg: "LOCUS without a name"}
+				}
+				gb.Locus = fields[0]
+				for _, f := range fields[1:] {
+					lower := strings.ToLower(f)
+					switch {
+					case lower == "circular":
+						gb.Circular = true
+					case strings.Contains(lower, "dna") || strings.Contains(lower, "rna"):
+						gb.Molecule = f
+					}
+				}
+			case "DEFINITION":
+				gb.Definition = rest
+			}
+			continue
+		}
+		if !sawLocus {
+			return nil, &ParseError{Line: line, Msg: "expected LOCUS"}
+		}
+
+		switch section {
+		case "DEFINITION":
+			gb.Definition += " " + strings.TrimSpace(text)
+		case "FEATURES":
+			if len(text) > featureKeyCol && text[featureKeyCol] != ' ' {
+				// A new feature: key then location.
+				if err := finishFeature(); err != nil {
+					return nil, err
+				}
+				fields := strings.Fields(text)
+				if len(fields) < 2 {
+					return nil, &ParseError{Line: line, Msg: "feature without a location"}
+				}
+				feature = &Feature{Type: fields[0]}
+				locationText = fields[1]
+				featureLine = line
+				inQualifier = false
+				continue
+			}
+			if feature == nil {
+				return nil, &ParseError{Line: line, Msg: "qualifier outside a feature"}
+			}
+			value := strings.TrimSpace(text)
+			if inQualifier {
+				q := &feature.Qualifiers[len(feature.Qualifiers)-1]
+				q.Value += joinSeparator(q.Key) + unquote(strings.TrimSuffix(value, `"`))
+				inQualifier = !strings.HasSuffix(value, `"`)
+				continue
+			}
+			if !strings.HasPrefix(value, "/") {
+				// The location continues.
+				locationText += value
+				continue
+			}
+			key, val := value[1:], ""
+			if i := strings.Index(key, "="); i >= 0 {
+				key, val = key[:i], key[i+1:]
+			}
+			if strings.HasPrefix(val, `"`) {
+				val = val[1:]
+				inQualifier = !strings.HasSuffix(val, `"`)
+				val = strings.TrimSuffix(val, `"`)
+			}
+			feature.Qualifiers = append(feature.Qualifiers, Qualifier{Key: key, Value: unquote(val)})
+		case "ORIGIN":
+			seq.WriteString(text)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !sawLocus {
+		return nil, &ParseError{Line: line, Msg: "expected LOCUS"}
+	}
+	if err := finishFeature(); err != nil {
+		return nil, err
+	}
+	gb.Sequence = sequence.Normalize(seq.String())
+	return gb, nil
+}
+
+// unquote undoes the doubling of quotes inside qualifier values.
+func unquote(s string) string {
+	return strings.Replace(s, `""`, `"`, -1)
+}
+
+// joinSeparator is what goes between the lines of a wrapped qualifier value.
+// Translations and sequences are wrapped mid-word, everything else at spaces.
+func joinSeparator(key string) string {
+	if key == "translation" {
+		return ""
+	}
+	return " "
+}
+
+// WriteGenBank writes gb to w.
+func WriteGenBank(w io.Writer, gb *GenBank) error {
+	bw := bufio.NewWriter(w)
+	topology := "linear"
+	if gb.Circular {
+		topology = "circular"
+	}
+	molecule := gb.Molecule
+	if molecule == "" {
+		molecule = "DNA"
+	}
+	locus := strings.Replace(gb.Locus, " ", "_", -1)
+	fmt.Fprintf(bw, "LOCUS       %-16s %11d bp    %-6s  %-8s SYN\n", locus, len(gb.Sequence), molecule, topology)
+	definition := gb.Definition
+	if definition == "" {
+		definition = "."
+	}
+	writeWrapped(bw, "DEFINITION  ", "            ", definition, 80)
+	fmt.Fprintln(bw, "FEATURES             Location/Qualifiers")
+	for _, f := range gb.Features {
+		fmt.Fprintf(bw, "%s%-16s%s\n", strings.Repeat(" ", featureKeyCol), f.Type, f.Location)
+		indent := strings.Repeat(" ", featureValCol)
+		for _, q := range f.Qualifiers {
+			text := "/" + q.Key
+			if q.Value != "" {
+				if _, err := strconv.Atoi(q.Value); err == nil {
+					text += "=" + q.Value
+				} else {
+					text += `="` + strings.Replace(q.Value, `"`, `""`, -1) + `"`
+				}
+			}
+			writeWrapped(bw, indent, indent, text, 80)
+		}
+	}
+	fmt.Fprintln(bw, "ORIGIN")
+	lower := strings.ToLower(gb.Sequence)
+	for i := 0; i < len(lower); i += lineWidth {
+		fmt.Fprintf(bw, "%9d", i+1)
+		for j := i; j < i+lineWidth && j < len(lower); j += 10 {
+			end := j + 10
+			if end > len(lower) {
+				end = len(lower)
+			}
+			fmt.Fprintf(bw, " %s", lower[j:end])
+		}
+		fmt.Fprintln(bw)
+	}
+	fmt.Fprintln(bw, "//")
+	return bw.Flush()
+}
+
+// writeWrapped writes text with the given prefixes, breaking lines at width.
+func writeWrapped(w io.Writer, first, rest, text string, width int) {
+	prefix := first
+	for {
+		room := width - len(prefix)
+		if len(text) <= room {
+			fmt.Fprintf(w, "%s%s\n", prefix, text)
+			return
+		}
+		cut := strings.LastIndex(text[:room], " ")
+		if cut <= 0 {
+			cut = room
+		}
+		fmt.Fprintf(w, "%s%s\n", prefix, strings.TrimRight(text[:cut], " "))
+		text = strings.TrimLeft(text[cut:], " ")
+		prefix = rest
+	}
+}
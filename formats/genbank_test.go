@@ -0,0 +1,136 @@
+package formats
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testGenBank = `LOCUS       pTest                    130 bp    DNA     circular SYN 01-JAN-2023
+DEFINITION  A test plasmid with a definition long enough to wrap onto a
+            second line.
+ACCESSION   .
+FEATURES             Location/Qualifiers
+     source          1..130
+                     /organism="synthetic DNA construct"
+     CDS             complement(join(121..130,1..20))
+                     /gene="ori"
+                     /codon_start=1
+                     /note="a ""quoted"" note that is long enough to have to
+                     wrap across lines"
+                     /translation="MTTTTTTTTTTTTTTTTTTTTTTTTTTTTTTTTTTTTTTTTTTTTTTTTTTT
+                     TTTTTTTTTTTTTTTTTTTTK"
+     misc_feature    <31..>60
+                     /pseudo
+     primer_bind     65
+     misc_feature    70^71
+     misc_feature    J00194.1:100..202
+ORIGIN
+        1 cgattcaaat gacggcagca ggccgggagt ccctgagagg cttgttccgg aaatgtgcca
+       61 tctgcgtgcg aacgcagcgt aagaggaggg ctagctgcgt cgagatcggg atctcaaaac
+      121 catcgaagtc
+//
+`
+
+func TestGenBankRoundTrip(t *testing.T) {
+	gb, err := ParseGenBank(strings.NewReader(testGenBank))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gb.Locus != "pTest" || !gb.Circular || gb.Molecule != "DNA" {
+		t.Errorf("LOCUS read as %q, %q, circular %v", gb.Locus, gb.Molecule, gb.Circular)
+	}
+	if gb.Definition != "A test plasmid with a definition long enough to wrap onto a second line." {
+		t.Errorf("Definition = %q", gb.Definition)
+	}
+	if len(gb.Sequence) != 130 || !strings.HasPrefix(gb.Sequence, "CGATTCAAAT") {
+		t.Errorf("Sequence = %q", gb.Sequence)
+	}
+	// The remote feature is dropped.
+	if len(gb.Features) != 5 {
+		t.Fatalf("%d features", len(gb.Features))
+	}
+	cds := gb.Features[1]
+	wantLocation := Location{Parts: []Span{{120, 130}, {0, 20}}, Strand: -1}
+	if !reflect.DeepEqual(cds.Location, wantLocation) {
+		t.Errorf("CDS at %+v, want %+v", cds.Location, wantLocation)
+	}
+	wantQualifiers := []Qualifier{
+		{"gene", "ori"},
+		{"codon_start", "1"},
+		{"note", `a "quoted" note that is long enough to have to wrap across lines`},
+		{"translation", "M" + strings.Repeat("T", 71) + "K"},
+	}
+	if !reflect.DeepEqual(cds.Qualifiers, wantQualifiers) {
+		t.Errorf("CDS qualifiers = %+v", cds.Qualifiers)
+	}
+	if start, end := cds.Location.Bounds(len(gb.Sequence)); start != 120 || end != 150 {
+		t.Errorf("CDS bounds %d-%d", start, end)
+	}
+	wantSpans := [][]Span{{{30, 60}}, {{64, 65}}, {{70, 70}}}
+	for i, want := range wantSpans {
+		if got := gb.Features[i+2].Location.Parts; !reflect.DeepEqual(got, want) {
+			t.Errorf("feature %d at %v, want %v", i+2, got, want)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := WriteGenBank(&out, gb); err != nil {
+		t.Fatal(err)
+	}
+	again, err := ParseGenBank(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatalf("%v reading\n%s", err, out.String())
+	}
+	if !reflect.DeepEqual(again, gb) {
+		t.Errorf("read back\n%+v\nwant\n%+v\nfrom\n%s", again, gb, out.String())
+	}
+	var out2 bytes.Buffer
+	if err := WriteGenBank(&out2, again); err != nil {
+		t.Fatal(err)
+	}
+	if out2.String() != out.String() {
+		t.Errorf("second write differs:\n%s\nfirst:\n%s", out2.String(), out.String())
+	}
+}
+
+func TestFASTARoundTrip(t *testing.T) {
+	input := `; a comment
+>seq1 first sequence
+gattaca GATTACA
+nnnn
+>seq2
+` + strings.Repeat("ACGT", 40) + `
+>empty nothing here
+`
+	records, err := ParseFASTA(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Record{
+		{Name: "seq1", Description: "first sequence", Sequence: "GATTACAGATTACANNNN"},
+		{Name: "seq2", Sequence: strings.Repeat("ACGT", 40)},
+		{Name: "empty", Description: "nothing here"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Fatalf("ParseFASTA = %+v", records)
+	}
+
+	var out bytes.Buffer
+	if err := WriteFASTA(&out, records); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(out.String(), "\n") {
+		if len(line) > lineWidth && !strings.HasPrefix(line, ">") {
+			t.Errorf("line of %d bases", len(line))
+		}
+	}
+	again, err := ParseFASTA(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, records) {
+		t.Errorf("read back\n%+v\nfrom\n%s", again, out.String())
+	}
+}
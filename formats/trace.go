@@ -0,0 +1,212 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"labdb.org/labdb/sequence"
+)
+
+// Read is a sequencing read: base calls and, for traces, their Phred quality
+// scores.
+type Read struct {
+	Name     string
+	Sequence string
+	// Quality has one score per base, or is nil if the read came from a file
+	// without them (e.g. FASTA).
+	Quality []int
+}
+
+// ParseReads reads the Sanger reads in data, which may be an ABI (.ab1) or
+// SCF trace or a FASTA file of one or more reads. name is used for reads the
+// file doesn't name itself, e.g. the upload's file name.
+func ParseReads(data []byte, name string) ([]Read, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("ABIF")):
+		r, err := ParseABI(data)
+		if err != nil {
+			return nil, err
+		}
+		if r.Name == "" {
+			r.Name = name
+		}
+		return []Read{*r}, nil
+	case bytes.HasPrefix(data, []byte(".scf")):
+		r, err := ParseSCF(data)
+		if err != nil {
+			return nil, err
+		}
+		r.Name = name
+		return []Read{*r}, nil
+	case bytes.HasPrefix(bytes.TrimSpace(data), []byte(">")):
+		records, err := ParseFASTA(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		reads := []Read{}
+		for _, rec := range records {
+			reads = append(reads, Read{Name: rec.Name, Sequence: rec.Sequence})
+		}
+		return reads, nil
+	}
+	return nil, fmt.Errorf("not an AB1, SCF or FASTA file")
+}
+
+// abiEntry is an entry in the directory of an ABIF file.
+type abiEntry struct {
+	Name        [4]byte
+	Number      int32
+	ElementType int16
+	ElementSize int16
+	NumElements int32
+	DataSize    int32
+	DataOffset  int32
+	DataHandle  int32
+}
+
+// abiEntrySize is the size of an abiEntry in the file.
+const abiEntrySize = 28
+
+// ParseABI reads the base calls and qualities from an ABIF (.ab1) trace, as
+// written by Applied Biosystems sequencers.
+func ParseABI(data []byte) (*Read, error) {
+	if len(data) < 6+abiEntrySize || string(data[:4]) != "ABIF" {
+		return nil, fmt.Errorf("not an ABIF file")
+	}
+	var root abiEntry
+	binary.Read(bytes.NewReader(data[6:6+abiEntrySize]), binary.BigEndian, &root)
+	dir := int(root.DataOffset)
+	count := int(root.NumElements)
+	if dir < 0 || count < 0 || dir+count*abiEntrySize > len(data) {
+		return nil, fmt.Errorf("truncated ABIF directory")
+	}
+	entries := map[string]abiEntry{}
+	for i := 0; i < count; i++ {
+		var e abiEntry
+		off := dir + i*abiEntrySize
+		binary.Read(bytes.NewReader(data[off:off+abiEntrySize]), binary.BigEndian, &e)
+		entries[fmt.Sprintf("%s%d", e.Name[:], e.Number)] = e
+	}
+	// The data of an entry is stored in place of its offset if it fits.
+	value := func(key string) ([]byte, bool, error) {
+		e, ok := entries[key]
+		if !ok {
+			return nil, false, nil
+		}
+		size := int(e.DataSize)
+		if size <= 4 {
+			var inline [4]byte
+			binary.BigEndian.PutUint32(inline[:], uint32(e.DataOffset))
+			return inline[:size], true, nil
+		}
+		start := int(e.DataOffset)
+		if start < 0 || size < 0 || start+size > len(data) {
+			return nil, false, fmt.Errorf("truncated ABIF entry %s", key)
+		}
+		return data[start : start+size], true, nil
+	}
+
+	// PBAS2 and PCON2 are the base caller's output; PBAS1 and PCON1 may have
+	// been edited by hand.
+	bases, ok, err := value("PBAS2")
+	if err == nil && !ok {
+		bases, ok, err = value("PBAS1")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("ABIF file has no base calls")
+	}
+	quality, ok, err := value("PCON2")
+	if err == nil && !ok {
+		quality, ok, err = value("PCON1")
+	}
+	if err != nil {
+		return nil, err
+	}
+	r := &Read{Sequence: strings.ToUpper(string(bases))}
+	if ok && len(quality) == len(bases) {
+		r.Quality = make([]int, len(quality))
+		for i, q := range quality {
+			r.Quality[i] = int(q)
+		}
+	}
+	// The sample name is a Pascal string.
+	if name, ok, _ := value("SMPL1"); ok && len(name) > 0 && int(name[0]) < len(name) {
+		r.Name = string(name[1 : 1+int(name[0])])
+	}
+	return r, nil
+}
+
+// scfHeader is the header of an SCF file.
+type scfHeader struct {
+	Magic          [4]byte
+	Samples        uint32
+	SamplesOffset  uint32
+	Bases          uint32
+	BasesLeftClip  uint32
+	BasesRightClip uint32
+	BasesOffset    uint32
+	CommentsSize   uint32
+	CommentsOffset uint32
+	Version        [4]byte
+	SampleSize     uint32
+	CodeSet        uint32
+	PrivateSize    uint32
+	PrivateOffset  uint32
+	Spare          [18]uint32
+}
+
+// ParseSCF reads the base calls and qualities from an SCF trace (versions 2
+// and 3). The quality of each base is its probability in the file.
+func ParseSCF(data []byte) (*Read, error) {
+	var h scfHeader
+	if err := binary.Read(bytes.NewReader(data), binary.BigEndian, &h); err != nil || string(h.Magic[:]) != ".scf" {
+		return nil, fmt.Errorf("not an SCF file")
+	}
+	n := int(h.Bases)
+	start := int(h.BasesOffset)
+	// Each base takes 12 bytes in either layout.
+	if n < 0 || start < 0 || start+12*n > len(data) {
+		return nil, fmt.Errorf("truncated SCF file")
+	}
+	seq := make([]byte, n)
+	quality := make([]int, n)
+	for i := 0; i < n; i++ {
+		var base byte
+		var probs [4]byte // A, C, G, T
+		if h.Version[0] >= '3' {
+			// Each field for all the bases in turn: peak indexes (4 bytes),
+			// the four probabilities, then the bases.
+			for j := range probs {
+				probs[j] = data[start+4*n+j*n+i]
+			}
+			base = data[start+8*n+i]
+		} else {
+			// A record per base: peak index, probabilities, base, spare.
+			rec := data[start+12*i:]
+			copy(probs[:], rec[4:8])
+			base = rec[8]
+		}
+		if b := sequence.Normalize(string(base)); b != "" {
+			base = b[0]
+		} else {
+			base = 'N'
+		}
+		seq[i] = base
+		switch base {
+		case 'A':
+			quality[i] = int(probs[0])
+		case 'C':
+			quality[i] = int(probs[1])
+		case 'G':
+			quality[i] = int(probs[2])
+		case 'T':
+			quality[i] = int(probs[3])
+		}
+	}
+	return &Read{Sequence: string(seq), Quality: quality}, nil
+}
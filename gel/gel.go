@@ -0,0 +1,134 @@
+// Package gel draws virtual agarose gels as SVG.
+package gel
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"sort"
+)
+
+// Ladder is a DNA size marker.
+type Ladder struct {
+	Name  string
+	Sizes []int
+}
+
+// Ladders are the markers that can be drawn, by name.
+var Ladders = map[string]Ladder{
+	"1kb": {
+		Name:  "1 kb ladder",
+		Sizes: []int{10000, 8000, 6000, 5000, 4000, 3000, 2000, 1500, 1000, 500},
+	},
+	"1kb_plus": {
+		Name:  "1 kb plus ladder",
+		Sizes: []int{10000, 8000, 6000, 5000, 4000, 3000, 2000, 1500, 1200, 1000, 900, 800, 700, 600, 500, 400, 300, 200, 100},
+	},
+	"100bp": {
+		Name:  "100 bp ladder",
+		Sizes: []int{1517, 1200, 1000, 900, 800, 700, 600, 500, 400, 300, 200, 100},
+	},
+}
+
+const DefaultLadder = "1kb"
+
+// Lane is a sample run on the gel: the sizes of the DNA fragments in it.
+type Lane struct {
+	Label string
+	Sizes []int
+}
+
+// Layout, in pixels.
+const (
+	laneWidth  = 60
+	bandHeight = 3
+	labelSpace = 110
+	wellTop    = 20
+	runLength  = 400
+	margin     = 40
+)
+
+// migration works out how far fragments run on a gel that resolves the
+// sizes in the ladder. Distance is linear in log(size), which holds well in
+// the middle of the gel's range; fragments outside it bunch up at the ends.
+type migration struct {
+	logMin float64
+	logMax float64
+}
+
+func newMigration(ladder Ladder) migration {
+	min, max := math.MaxInt32, 0
+	for _, s := range ladder.Sizes {
+		if s < min {
+			min = s
+		}
+		if s > max {
+			max = s
+		}
+	}
+	if max <= min {
+		min, max = 100, 10000
+	}
+	// Leave a little room at either end.
+	return migration{logMin: math.Log(float64(min)) - 0.15, logMax: math.Log(float64(max)) + 0.15}
+}
+
+// y returns the position of a band of the given size.
+func (m migration) y(size int) float64 {
+	frac := (m.logMax - math.Log(float64(size))) / (m.logMax - m.logMin)
+	frac = math.Max(0, math.Min(1, frac))
+	return wellTop + 10 + frac*runLength
+}
+
+// Render draws ladder followed by lanes. Bands of the same size in a lane
+// are drawn more intensely, as they'd be brighter on a real gel.
+func Render(w io.Writer, ladder Ladder, lanes []Lane) error {
+	bw := bufio.NewWriter(w)
+	m := newMigration(ladder)
+	all := append([]Lane{{Label: ladder.Name, Sizes: ladder.Sizes}}, lanes...)
+	width := labelSpace + len(all)*(laneWidth+10) + margin
+	height := wellTop + runLength + 20 + labelSpace
+
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`+"\n", width, height, width, height)
+	fmt.Fprintf(bw, `<rect x="%d" y="0" width="%d" height="%d" fill="#1a1a1a"/>`+"\n", labelSpace-10, width-labelSpace+10, wellTop+runLength+20)
+
+	// Ladder sizes along the left.
+	for _, size := range ladder.Sizes {
+		fmt.Fprintf(bw, `<text x="%d" y="%.1f" text-anchor="end" dominant-baseline="middle">%s</text>`+"\n", labelSpace-15, m.y(size), formatSize(size))
+	}
+
+	for i, lane := range all {
+		x := labelSpace + i*(laneWidth+10)
+		fmt.Fprintf(bw, `<rect x="%d" y="%d" width="%d" height="4" fill="#444"/>`+"\n", x, wellTop, laneWidth)
+		counts := map[int]int{}
+		for _, size := range lane.Sizes {
+			if size > 0 {
+				counts[size]++
+			}
+		}
+		sizes := []int{}
+		for size := range counts {
+			sizes = append(sizes, size)
+		}
+		sort.Ints(sizes)
+		for _, size := range sizes {
+			opacity := math.Min(1, 0.55+0.2*float64(counts[size]-1))
+			fmt.Fprintf(bw, `<rect x="%d" y="%.1f" width="%d" height="%d" rx="1" fill="#f4f4f4" fill-opacity="%.2f"><title>%s</title></rect>`+"\n",
+				x+4, m.y(size)-bandHeight/2.0, laneWidth-8, bandHeight, opacity, formatSize(size))
+		}
+		// Labels run diagonally under the lanes.
+		lx, ly := x+laneWidth/2, wellTop+runLength+32
+		fmt.Fprintf(bw, `<text x="%d" y="%d" transform="rotate(40 %d %d)">%s</text>`+"\n", lx, ly, lx, ly, html.EscapeString(lane.Label))
+	}
+	fmt.Fprintln(bw, "</svg>")
+	return bw.Flush()
+}
+
+func formatSize(size int) string {
+	if size >= 1000 && size%100 == 0 {
+		return fmt.Sprintf("%g kb", float64(size)/1000)
+	}
+	return fmt.Sprintf("%d bp", size)
+}
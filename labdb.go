@@ -4,9 +4,12 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -20,6 +23,7 @@ import (
 	"labdb.org/labdb/models"
 	"labdb.org/labdb/routes"
 	"labdb.org/labdb/search"
+	"labdb.org/labdb/search/seqindex"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
@@ -81,110 +85,469 @@ func redirectHTTPS(c *gin.Context) {
 	c.Next()
 }
 
-func requireAuthorization(c *gin.Context) {
-	u := auth.CurrentUser(c)
-	if u.AuthWrite || (u.AuthRead && c.Request.Method == "GET") {
-		c.Next()
-		return
+// requireAuthorization checks the user's role and grants allow the request.
+// Routes for a :model are checked against access to that kind; handlers
+// check ownership of individual items with canEdit.
+func requireAuthorization(s *models.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, err := auth.CurrentPermissions(c, s)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		write := c.Request.Method != "GET"
+		allowed := false
+		if kind, ok := models.KnownKind(c.Param("model")); ok {
+			allowed = (write && p.CanWrite(kind)) || (!write && p.CanRead(kind))
+		} else {
+			allowed = (write && p.CanWriteAny()) || (!write && p.CanReadAny())
+		}
+		if allowed {
+			c.Next()
+			return
+		}
+
+		fmt.Printf("Access denied to %+v.\n", p.User)
+		c.String(403, "Forbidden")
+		c.Abort()
 	}
+}
 
-	fmt.Printf("Access denied to %+v.\n", u)
-	c.String(403, "Forbidden")
-	c.Abort()
+// canEdit checks the user may change e, writing an error response if not.
+func canEdit(c *gin.Context, s *models.Store, e models.Entity) bool {
+	p, err := auth.CurrentPermissions(c, s)
+	if err != nil {
+		c.AbortWithError(500, err)
+		return false
+	}
+	if !p.CanEdit(e) {
+		c.String(403, "You can't edit %s", models.NameOf(e))
+		return false
+	}
+	return true
+}
+
+// canEditAll checks the user may change anything at all, writing an error
+// response if not. It's for writes passed on to Rails that can't be tied to
+// an item or a kind.
+func canEditAll(c *gin.Context, s *models.Store) bool {
+	p, err := auth.CurrentPermissions(c, s)
+	if err != nil {
+		c.AbortWithError(500, err)
+		return false
+	}
+	if !p.CanEditAll() {
+		c.String(403, "Forbidden")
+		c.Abort()
+		return false
+	}
+	return true
+}
+
+// canEditInRails checks the user may change the item a request passed on to
+// Rails is for. Items of kinds we know are loaded and checked as ours are.
+func canEditInRails(c *gin.Context, s *models.Store) bool {
+	if _, ok := models.KnownKind(c.Param("model")); !ok {
+		return canEditAll(c, s)
+	}
+	m, ok := existingModel(c, s)
+	return ok && canEdit(c, s, m)
 }
 
-func startup() {
+// proxyRails passes requests we don't handle on to Rails. Writes there need
+// all access, as there's nothing finer to check them against.
+func proxyRails(s *models.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != "GET" && !canEditAll(c, s) {
+			return
+		}
+		proxy(c)
+	}
+}
+
+// canCreate checks the user may add items of kind, writing an error response
+// if not.
+func canCreate(c *gin.Context, s *models.Store, kind string) bool {
+	p, err := auth.CurrentPermissions(c, s)
+	if err != nil {
+		c.AbortWithError(500, err)
+		return false
+	}
+	if !p.CanWrite(kind) {
+		c.String(403, "You can't add %ss", kind)
+		return false
+	}
+	return true
+}
+
+// canRead checks the user may see items of kind, writing an error response
+// if not. requireAuthorization only checks the kind named by the route, so
+// this is for any others a request loads.
+func canRead(c *gin.Context, s *models.Store, kind string) bool {
+	p, err := auth.CurrentPermissions(c, s)
+	if err != nil {
+		c.AbortWithError(500, err)
+		return false
+	}
+	if !p.CanRead(kind) {
+		c.String(403, "You can't see %ss", kind)
+		return false
+	}
+	return true
+}
+
+func startup() *models.Store {
 	if env.Prod {
 		gin.SetMode(gin.ReleaseMode)
 	}
+	dbURL := env.DbURL
+	if env.Dev {
+		dbURL = "dbname=labdb sslmode=disable"
+	}
+	s, err := models.NewStore(dbURL, env.DebugDB)
+	if err != nil {
+		log.Fatalf("Couldn't connect to the database: %v\n", err)
+	}
+	s.PreserveSequenceCase = env.PreserveSequenceCase
+	return s
 }
 
-func shutdown() {
-	models.Shutdown()
+func shutdown(s *models.Store) {
+	s.Close()
 }
 
-func modelAPI(r *gin.Engine) {
+const maxPageSize = 500
+
+// searchTypes parses the optional types param, a JSON list, defaulting to
+// search.AllTypes.
+func searchTypes(c *gin.Context) ([]string, bool) {
+	var types []string
+	if c.Query("types") != "" && json.Unmarshal([]byte(c.Query("types")), &types) != nil {
+		return nil, false
+	}
+	if len(types) == 0 {
+		// A copy, so that nothing can change the defaults.
+		types = append([]string(nil), search.AllTypes...)
+	}
+	return types, true
+}
+
+// readableTypes returns the types in types that p allows reading.
+func readableTypes(p models.Permissions, types []string) []string {
+	kept := []string{}
+	for _, t := range types {
+		if p.CanRead(t) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// readable returns the items in es that p allows reading, for searches, which
+// requireAuthorization can't check by kind.
+func readable(p models.Permissions, es []models.Entity) []models.Entity {
+	kept := []models.Entity{}
+	for _, e := range es {
+		if p.CanRead(models.KindOf(e)) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// writeError responds to an error from Store.Create or Store.Save, which is
+// the request's fault if the item didn't validate.
+func writeError(c *gin.Context, err error) {
+	if verr, ok := err.(*models.ValidationError); ok {
+		c.String(400, "Invalid fields: %s", verr.Error())
+		return
+	}
+	c.AbortWithError(500, err)
+}
+
+// existingModel looks up the entity named by the :model and :id params,
+// writing an error response and returning false if there isn't one.
+func existingModel(c *gin.Context, s *models.Store) (models.Entity, bool) {
+	return modelWithID(c, s, c.Param("id"))
+}
+
+func modelWithID(c *gin.Context, s *models.Store, idStr string) (models.Entity, bool) {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.String(400, "Bad ID")
+		c.Abort()
+		return nil, false
+	}
+	m, err := s.GetByID(c.Request.Context(), c.Param("model"), id)
+	if err == models.ErrNotFound {
+		c.String(404, "Not found.")
+		c.Abort()
+		return nil, false
+	}
+	if err != nil {
+		c.AbortWithError(500, err)
+		return nil, false
+	}
+	return m, true
+}
+
+func modelAPI(r *gin.Engine, s *models.Store) {
 	apiM := r.Group("/api/v1/m")
 
-	apiM.GET("/:model/:id", func(c *gin.Context) {
+	apiM.GET("/:model", func(c *gin.Context) {
 		modelType := c.Param("model")
-		id, err := strconv.Atoi(c.Param("id"))
+		if !models.IsImplemented(modelType) {
+			proxy(c)
+			return
+		}
+		offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+		if err != nil || offset < 0 {
+			c.String(400, "Bad offset")
+			return
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil || limit <= 0 || limit > maxPageSize {
+			c.String(400, "Bad limit")
+			return
+		}
+		page, err := s.List(c.Request.Context(), modelType, offset, limit)
 		if err != nil {
-			c.String(400, "Bad ID")
-			c.Abort()
+			c.AbortWithError(500, err)
 			return
 		}
-		if models.IsImplemented(modelType) {
-			m := models.Empty(modelType)
-			models.GetByID(m, id)
-			if m.GetID() == 0 {
-				c.String(404, "Not found.")
-				c.Abort()
-				return
-			}
-			c.JSON(200, m.AsResourceDef())
-		} else {
+		total, err := s.Count(c.Request.Context(), modelType)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		items := []models.ResourceDef{}
+		for _, m := range page {
+			items = append(items, models.AsResourceDef(m))
+		}
+		c.JSON(200, gin.H{
+			"items":  items,
+			"total":  total,
+			"offset": offset,
+			"limit":  limit,
+		})
+	})
+
+	apiM.GET("/:model/:id", func(c *gin.Context) {
+		if id, format := exportFormat(c.Param("id")); format != "" {
+			exportSequence(c, s, id, format)
+			return
+		}
+		if !models.IsImplemented(c.Param("model")) {
 			proxy(c)
+			return
+		}
+		m, ok := existingModel(c, s)
+		if !ok {
+			return
 		}
+		c.JSON(200, models.AsResourceDef(m))
 	})
 
 	apiM.POST("/:model/new", func(c *gin.Context) {
 		modelType := c.Param("model")
-		if models.IsImplemented(modelType) {
-			m := models.Empty(modelType)
-			m.AutoFill(auth.CurrentUser(c).Name)
-			models.Create(m)
-			c.Status(204)
-		} else {
-			proxy(c)
+		if !models.IsImplemented(modelType) {
+			// requireAuthorization has checked new items of known kinds.
+			if _, ok := models.KnownKind(modelType); ok || canEditAll(c, s) {
+				proxy(c)
+			}
+			return
+		}
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			c.String(400, "Bad request body")
+			return
+		}
+		u, err := auth.CurrentUser(c, s)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		m := models.Empty(modelType)
+		m.AutoFill(u.Name)
+		if len(bytes.TrimSpace(body)) > 0 {
+			if err := models.ApplyFields(m, body); err != nil {
+				c.String(400, "Invalid fields: %s", err.Error())
+				return
+			}
+		}
+		if !canEdit(c, s, m) {
+			return
+		}
+		if err := s.Create(c.Request.Context(), m); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(201, models.AsResourceDef(m))
+	})
+
+	// PUT replaces an item's fields with those given, and PATCH changes only
+	// those given.
+	update := func(apply func(models.Entity, []byte) error) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			if !models.IsImplemented(c.Param("model")) {
+				if canEditInRails(c, s) {
+					proxy(c)
+				}
+				return
+			}
+			m, ok := existingModel(c, s)
+			if !ok || !canEdit(c, s, m) {
+				return
+			}
+			body, err := ioutil.ReadAll(c.Request.Body)
+			if err != nil {
+				c.String(400, "Bad request body")
+				return
+			}
+			if err := apply(m, body); err != nil {
+				c.String(400, "Invalid fields: %s", err.Error())
+				return
+			}
+			// Members can't hand their entries over to someone else.
+			if !canEdit(c, s, m) {
+				return
+			}
+			if err := s.Save(c.Request.Context(), m); err != nil {
+				writeError(c, err)
+				return
+			}
+			c.JSON(200, models.AsResourceDef(m))
+		}
+	}
+	apiM.PUT("/:model/:id", update(models.ReplaceFields))
+	apiM.PATCH("/:model/:id", update(models.ApplyFields))
+
+	apiM.DELETE("/:model/:id", func(c *gin.Context) {
+		if !models.IsImplemented(c.Param("model")) {
+			if canEditInRails(c, s) {
+				proxy(c)
+			}
+			return
+		}
+		m, ok := existingModel(c, s)
+		if !ok || !canEdit(c, s, m) {
+			return
+		}
+		if err := s.Delete(c.Request.Context(), m); err != nil {
+			c.AbortWithError(500, err)
+			return
 		}
+		c.Status(204)
 	})
 }
 
 func main() {
-	startup()
-	defer shutdown()
+	store := startup()
+	defer shutdown(store)
+	seqIndex := seqindex.New(seqindex.DefaultK)
+	seqIndex.Watch(store)
+	// Only the kinds saved here keep the index up to date; searches scan
+	// the rest.
+	indexed := []string{}
+	for _, t := range search.AllTypes {
+		if models.IsImplemented(t) {
+			indexed = append(indexed, t)
+		}
+	}
+	go func() {
+		if err := seqIndex.Load(context.Background(), store, indexed); err != nil {
+			log.Printf("Couldn't load the sequence index: %v\n", err)
+			return
+		}
+		log.Printf("Indexed %d sequences.\n", seqIndex.Len())
+	}()
+	provider, err := auth.ProviderFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
 	r := gin.Default()
-	store := sessions.NewCookieStore([]byte(env.SecretToken))
+	cookieStore := sessions.NewCookieStore([]byte(env.SecretToken))
 	r.Use(redirectHTTPS)
-	r.Use(sessions.Sessions("labdb", store))
+	r.Use(sessions.Sessions("labdb", cookieStore))
 	r.POST("/api/verify", func(c *gin.Context) {
-		email := auth.GetVerifiedIdentity(c.Query("token"))
-		if email == "" {
+		email, err := provider.Identify(c.Request)
+		if err != nil {
+			log.Printf("Rejected %s sign in: %v\n", provider.Name(), err)
 			c.String(403, "Forbidden")
-		} else {
-			session := sessions.Default(c)
-			session.Set("userID", email)
-			session.Save()
-			c.Redirect(303, "/")
+			return
+		}
+		// Unknown users still get a session, but nothing is authorized for
+		// them until they're invited.
+		u, err := store.RecordLogin(c.Request.Context(), email)
+		if err != nil && err != models.ErrNotFound {
+			c.AbortWithError(500, err)
+			return
+		}
+		if u.Deactivated {
+			c.String(403, "Forbidden")
+			return
 		}
+		session := sessions.Default(c)
+		session.Set("userID", email)
+		session.Save()
+		c.Redirect(303, "/")
 	})
 	r.GET("/", proxy)
 
-	// Below here, all routes require authorization.
-	r.Use(requireAuthorization)
+	// Below here, all routes require authorization, by session or API token.
+	r.Use(auth.APITokens(store))
+	// Except that anyone signed in can manage their own tokens, whatever
+	// else they may do.
+	tokenAPI(r, store)
+	r.Use(requireAuthorization(store))
 
 	r.GET("/search", func(c *gin.Context) {
+		p, err := auth.CurrentPermissions(c, store)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		var results []models.Entity
 		term := c.Query("term")
-		seq := c.Query("seq")
-		person := c.Query("person")
-		includeSeq := false
-		if seq == "1" {
-			includeSeq = true
+		if q := c.Query("q"); q != "" {
+			// Structured queries (see search.Parse), which carry their own
+			// options.
+			term = q
+			types, ok := searchTypes(c)
+			if !ok {
+				c.String(400, "Invalid search query")
+				return
+			}
+			var parsed *search.Query
+			parsed, err = search.Parse(q)
+			if err == nil {
+				results, err = search.Eval(store, seqIndex, parsed, readableTypes(p, types))
+			}
+		} else {
+			seq := c.Query("seq")
+			person := c.Query("person")
+			includeSeq := false
+			if seq == "1" {
+				includeSeq = true
+			}
+			types := []string{}
+			err = json.Unmarshal([]byte(c.Query("types")), &types)
+			if err != nil || term == "" {
+				c.String(400, "Invalid search query")
+				return
+			}
+			results, err = search.Search(store, term, includeSeq, person, readableTypes(p, types))
 		}
-		types := []string{}
-		err := json.Unmarshal([]byte(c.Query("types")), &types)
-		if err != nil || term == "" {
-			c.String(400, "Invalid search query")
+		if errors.Is(err, search.ErrInvalidQuery) {
+			c.String(400, "Invalid search query: %s", err.Error())
 			return
 		}
-		results, err := search.Search(term, includeSeq, person, types)
 		if err != nil {
-			c.String(400, "Invalid search query")
+			c.AbortWithError(500, err)
 			return
 		}
 		query := [][]interface{}{}
-		for _, entity := range results {
+		for _, entity := range readable(p, results) {
 			query = append(query, []interface{}{reflect.Indirect(reflect.ValueOf(entity)).Type().Name(), entity.GetID()})
 		}
 		// In addition to the searched items, we also send up the raw term to
@@ -227,9 +590,61 @@ func main() {
 		c.Writer.Write(body)
 	})
 
-	routes.InstallAll(r)
+	r.GET("/api/v1/search", func(c *gin.Context) {
+		p, err := auth.CurrentPermissions(c, store)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		q := c.Query("q")
+		types, ok := searchTypes(c)
+		if !ok {
+			c.String(400, "Bad types")
+			return
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		if err != nil || limit <= 0 || limit > maxPageSize {
+			c.String(400, "Bad limit")
+			return
+		}
+		parsed, err := search.Parse(q)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		found, err := search.Eval(store, seqIndex, parsed, readableTypes(p, types))
+		if errors.Is(err, search.ErrInvalidQuery) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		found = readable(p, found)
+		results := []search.Result{}
+		for i, e := range found {
+			if i == limit {
+				break
+			}
+			results = append(results, search.Describe(e, parsed))
+		}
+		c.JSON(200, gin.H{
+			"query":   q,
+			"total":   len(found),
+			"results": results,
+		})
+	})
+
+	modelAPI(r, store)
+	sequenceAPI(r, store)
+	cloningAPI(r, store)
+	verifyAPI(r, store)
+	designAPI(r, store)
+	adminAPI(r, store)
+	routes.InstallAll(r, store)
 
-	r.Use(proxy)
+	r.Use(proxyRails(store))
 
 	if env.Dev {
 		port := os.Getenv("PORT")
@@ -0,0 +1,177 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"labdb.org/labdb/features"
+	"labdb.org/labdb/formats"
+	"labdb.org/labdb/sequence"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Annotation is a feature on the sequence of an item, e.g. as imported from
+// a GenBank file. Start and End are 0-based, end-exclusive positions on the
+// normalized sequence; End is past the end of the sequence for features that
+// span the origin of circular ones.
+type Annotation struct {
+	Model
+	ItemKind string
+	ItemID   uint
+	Name     string
+	Type     string
+	Start    int
+	End      int
+	// Strand is 1 or -1 (or 0 if not applicable).
+	Strand int
+	// Qualifiers holds the GenBank qualifiers as JSON.
+	Qualifiers string
+}
+
+func (a *Annotation) QualifierList() []formats.Qualifier {
+	qs := []formats.Qualifier{}
+	if a.Qualifiers != "" {
+		json.Unmarshal([]byte(a.Qualifiers), &qs)
+	}
+	return qs
+}
+
+func (a *Annotation) SetQualifiers(qs []formats.Qualifier) {
+	if qs == nil {
+		qs = []formats.Qualifier{}
+	}
+	encoded, err := json.Marshal(qs)
+	if err != nil {
+		panic(err)
+	}
+	a.Qualifiers = string(encoded)
+}
+
+// AnnotationFromFeature converts a feature of a sequence n bases long.
+func AnnotationFromFeature(f formats.Feature, n int) Annotation {
+	a := Annotation{
+		Name:   f.Label(),
+		Type:   f.Type,
+		Strand: f.Location.Strand,
+	}
+	a.Start, a.End = f.Location.Bounds(n)
+	a.SetQualifiers(f.Qualifiers)
+	return a
+}
+
+// Feature converts an annotation on a sequence n bases long back to a
+// GenBank feature.
+func (a *Annotation) Feature(n int) formats.Feature {
+	loc := formats.Location{Strand: a.Strand}
+	if a.End > n && n > 0 {
+		loc.Parts = []formats.Span{{Start: a.Start, End: n}, {Start: 0, End: a.End - n}}
+	} else {
+		loc.Parts = []formats.Span{{Start: a.Start, End: a.End}}
+	}
+	qs := a.QualifierList()
+	hasLabel := false
+	for _, q := range qs {
+		hasLabel = hasLabel || q.Key == "label"
+	}
+	if !hasLabel && a.Name != "" {
+		qs = append([]formats.Qualifier{{Key: "label", Value: a.Name}}, qs...)
+	}
+	return formats.Feature{Type: a.Type, Location: loc, Qualifiers: qs}
+}
+
+// annotated is implemented by entities that carry their annotations around
+// with them.
+type annotated interface {
+	setAnnotations([]Annotation)
+}
+
+// ProposeAnnotations scans e's sequence for the features in the library,
+// returning those not already covered by one of existing.
+func ProposeAnnotations(e Entity, library []features.Def, existing []Annotation) []Annotation {
+	seq := sequence.Normalize(e.GetSequence())
+	proposed := []Annotation{}
+	for _, h := range features.Scan(library, seq, e.IsCircular()) {
+		strand := 1
+		if h.Strand == sequence.Reverse {
+			strand = -1
+		}
+		known := false
+		for _, a := range existing {
+			known = known || (a.Start == h.Start && a.End == h.End && a.Strand == strand)
+		}
+		if known {
+			continue
+		}
+		a := Annotation{
+			ItemKind: KindOf(e),
+			ItemID:   e.GetID(),
+			Name:     h.Def.Name,
+			Type:     h.Def.Type,
+			Start:    h.Start,
+			End:      h.End,
+			Strand:   strand,
+		}
+		qs := []formats.Qualifier{{Key: "label", Value: h.Def.Name}}
+		if h.Mismatches > 0 {
+			qs = append(qs, formats.Qualifier{Key: "note", Value: fmt.Sprintf("%d mismatches to the library feature", h.Mismatches)})
+		}
+		a.SetQualifiers(qs)
+		proposed = append(proposed, a)
+	}
+	return proposed
+}
+
+// Annotations returns the annotations on e's sequence, in order along it.
+func (s *Store) Annotations(ctx context.Context, e Entity) ([]Annotation, error) {
+	db, err := s.with(ctx)
+	if err != nil {
+		return nil, err
+	}
+	as := []Annotation{}
+	err = db.Where("item_kind = ? AND item_id = ?", KindOf(e), e.GetID()).Order("start, id").Find(&as).Error
+	return as, err
+}
+
+// AddAnnotations saves annotations on e, which must already have been created.
+func (s *Store) AddAnnotations(ctx context.Context, e Entity, as []Annotation) error {
+	return s.saveAnnotations(ctx, e, as, false)
+}
+
+// SetAnnotations replaces all of e's annotations with as.
+func (s *Store) SetAnnotations(ctx context.Context, e Entity, as []Annotation) error {
+	return s.saveAnnotations(ctx, e, as, true)
+}
+
+func (s *Store) saveAnnotations(ctx context.Context, e Entity, as []Annotation, replace bool) error {
+	db, err := s.with(ctx)
+	if err != nil {
+		return err
+	}
+	tx := db.Begin()
+	if replace {
+		err := tx.Where("item_kind = ? AND item_id = ?", KindOf(e), e.GetID()).Delete(&Annotation{}).Error
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := insertAnnotations(tx, e, as); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func insertAnnotations(tx *gorm.DB, e Entity, as []Annotation) error {
+	for i := range as {
+		as[i].Model = Model{}
+		as[i].ItemKind = KindOf(e)
+		as[i].ItemID = e.GetID()
+		if err := tx.Create(&as[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -7,6 +7,8 @@ type Antibody struct {
 	Comments  string
 }
 
-func (a *Antibody) OwnerFieldName() string { return "entered_by" }
-func (a *Antibody) ShortDesc() string      { return a.Alias }
-func (a *Antibody) Desc() string           { return a.Comments }
+func (a *Antibody) OwnerFieldName() string     { return "entered_by" }
+func (a *Antibody) ShortDescFieldName() string { return "alias" }
+func (a *Antibody) DescFieldName() string      { return "comments" }
+func (a *Antibody) ShortDesc() string          { return a.Alias }
+func (a *Antibody) Desc() string               { return a.Comments }
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// APITokenPrefix starts every personal API token, so they're recognizable
+// in scripts and logs.
+const APITokenPrefix = "labdb_"
+
+// ErrExpired is returned for API tokens past their expiry.
+var ErrExpired = errors.New("token has expired")
+
+// APIToken is a personal token a user can authenticate scripts with. Only a
+// hash of the token is stored.
+type APIToken struct {
+	Model
+	UserID uint
+	Name   string
+	Hash   string `json:"-"`
+	// Hint is the start of the token, to tell tokens apart.
+	Hint       string
+	ReadOnly   bool
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+}
+
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// CreateAPIToken mints a new token for u, returning the token itself, which
+// can't be recovered later.
+func (s *Store) CreateAPIToken(ctx context.Context, u User, t *APIToken) (string, error) {
+	db, err := s.with(ctx)
+	if err != nil {
+		return "", err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := APITokenPrefix + hex.EncodeToString(secret)
+	t.Model = Model{}
+	t.UserID = u.ID
+	t.Hash = hashToken(token)
+	t.Hint = token[:len(APITokenPrefix)+6]
+	t.LastUsedAt = nil
+	if err := db.Create(t).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// APITokens returns u's tokens, newest first.
+func (s *Store) APITokens(ctx context.Context, u User) ([]APIToken, error) {
+	db, err := s.with(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ts := []APIToken{}
+	err = db.Where("user_id = ?", u.ID).Order("id desc").Find(&ts).Error
+	return ts, err
+}
+
+// RevokeAPIToken deletes u's token with the given ID, returning ErrNotFound
+// if u has no such token.
+func (s *Store) RevokeAPIToken(ctx context.Context, u User, id uint) error {
+	db, err := s.with(ctx)
+	if err != nil {
+		return err
+	}
+	res := db.Where("id = ? AND user_id = ?", id, u.ID).Delete(&APIToken{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UserByAPIToken looks up the user token belongs to, returning ErrNotFound
+// if it isn't a token or ErrExpired if it's expired.
+func (s *Store) UserByAPIToken(ctx context.Context, token string) (User, APIToken, error) {
+	db, err := s.with(ctx)
+	if err != nil {
+		return User{}, APIToken{}, err
+	}
+	t := APIToken{}
+	if err := db.Where("hash = ?", hashToken(token)).First(&t).Error; err != nil {
+		return User{}, APIToken{}, notFoundOr(err)
+	}
+	now := time.Now()
+	if t.ExpiresAt != nil && !now.Before(*t.ExpiresAt) {
+		return User{}, APIToken{}, ErrExpired
+	}
+	u := User{}
+	if err := db.First(&u, t.UserID).Error; err != nil {
+		return User{}, APIToken{}, notFoundOr(err)
+	}
+	// Only worth recording to the minute.
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) > time.Minute {
+		t.LastUsedAt = &now
+		if err := db.Model(&t).UpdateColumn("last_used_at", now).Error; err != nil {
+			return User{}, APIToken{}, err
+		}
+	}
+	return u, t, nil
+}
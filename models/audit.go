@@ -0,0 +1,38 @@
+package models
+
+import (
+	"context"
+
+	"github.com/jinzhu/gorm"
+)
+
+// AuditEntry records a change an admin made to a user.
+type AuditEntry struct {
+	Model
+	// Actor is the email address of whoever made the change.
+	Actor   string
+	UserID  uint
+	Action  string
+	Details string
+}
+
+// audit records a change to u within tx.
+func audit(tx *gorm.DB, actor string, u User, action, details string) error {
+	return tx.Create(&AuditEntry{Actor: actor, UserID: u.ID, Action: action, Details: details}).Error
+}
+
+// AuditLog returns the most recent limit changes, newest first, optionally
+// only those to the user with ID userID.
+func (s *Store) AuditLog(ctx context.Context, userID uint, limit int) ([]AuditEntry, error) {
+	db, err := s.with(ctx)
+	if err != nil {
+		return nil, err
+	}
+	q := db.Order("id desc").Limit(limit)
+	if userID != 0 {
+		q = q.Where("user_id = ?", userID)
+	}
+	entries := []AuditEntry{}
+	err = q.Find(&entries).Error
+	return entries, err
+}
@@ -8,7 +8,11 @@ type Bacterium struct {
 	Sequence    string
 }
 
-func (b *Bacterium) OwnerFieldName() string { return "entered_by" }
-func (b *Bacterium) ShortDesc() string      { return b.Strainalias }
-func (b *Bacterium) Desc() string           { return b.Comments }
-func (b *Bacterium) GetSequence() string    { return b.Sequence }
+func (b *Bacterium) AutoFill(userName string)   { b.EnteredBy = userName }
+func (b *Bacterium) OwnerFieldName() string     { return "entered_by" }
+func (b *Bacterium) ShortDescFieldName() string { return "strainalias" }
+func (b *Bacterium) DescFieldName() string      { return "comments" }
+func (b *Bacterium) SequenceFieldName() string  { return "sequence" }
+func (b *Bacterium) ShortDesc() string          { return b.Strainalias }
+func (b *Bacterium) Desc() string               { return b.Comments }
+func (b *Bacterium) GetSequence() string        { return b.Sequence }
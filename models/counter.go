@@ -0,0 +1,78 @@
+package models
+
+import (
+	"context"
+	"log"
+
+	"github.com/lib/pq"
+)
+
+// ItemCounter holds the last number handed out for each kind of numbered item.
+type ItemCounter struct {
+	Kind       string `gorm:"primary_key"`
+	LastNumber int
+}
+
+// numbered is implemented by models whose items carry their own sequential
+// number (as opposed to using the database ID).
+type numbered interface {
+	SetNumber(n int)
+}
+
+// NextAvailableNumber reserves the next number for e's kind. The counter row is
+// locked for the duration of the transaction, so concurrent creators of the
+// same kind each get a distinct number. Numbers aren't returned if the item
+// is never saved, so there may be gaps.
+func (s *Store) NextAvailableNumber(ctx context.Context, e Entity) (int, error) {
+	db, err := s.with(ctx)
+	if err != nil {
+		return 0, err
+	}
+	kind := KindOf(e)
+	table := db.NewScope(e).TableName()
+	numberColumn := "id"
+	if _, isNumbered := e.(numbered); isNumbered {
+		numberColumn = "number"
+	}
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	// Seed the counter from the existing items the first time we see a kind.
+	err = tx.Exec(
+		"INSERT INTO item_counters (kind, last_number) SELECT ?, COALESCE(MAX("+numberColumn+"), 0) FROM "+table+" ON CONFLICT (kind) DO NOTHING",
+		kind,
+	).Error
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	counter := ItemCounter{}
+	err = tx.Set("gorm:query_option", "FOR UPDATE").Where("kind = ?", kind).First(&counter).Error
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	counter.LastNumber++
+	err = tx.Model(&counter).Update("last_number", counter.LastNumber).Error
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return counter.LastNumber, tx.Commit().Error
+}
+
+func isUniqueViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505"
+}
+
+// addNumberIndex makes item numbers unique within a model's table.
+func (s *Store) addNumberIndex(e Entity) {
+	table := s.db.NewScope(e).TableName()
+	err := s.db.Model(e).AddUniqueIndex("idx_"+table+"_number", "number").Error
+	if err != nil {
+		log.Printf("Couldn't add unique number index to %s: %v\n", table, err)
+	}
+}
@@ -1,17 +1,13 @@
 package models
 
 import (
-	"strconv"
+	"encoding/json"
+	"reflect"
 	"time"
 
 	"github.com/jinzhu/gorm"
-	_ "github.com/jinzhu/gorm/dialects/postgres" // Enables the postgres driver for gorm.
-
-	"labdb.org/labdb/env"
 )
 
-var db *gorm.DB
-
 type Entity interface {
 	model() Model
 	GetID() uint
@@ -22,12 +18,15 @@ type Entity interface {
 	ShortDesc() string
 	Desc() string
 	GetSequence() string
+	IsCircular() bool
 	OwnerFieldName() string
+	ShortDescFieldName() string
+	DescFieldName() string
+	SequenceFieldName() string
 	GetCoreLinks() *CoreLinks
 	GetCoreInfoSections() []InfoSection
 	GetSequenceInfo() *SequenceInfo
 	GetSupplementalFields() []FieldDef
-	AsResourceDef() string
 }
 
 type Model struct {
@@ -45,12 +44,15 @@ func (m *Model) GetName() string                    { return "" }
 func (m *Model) ShortDesc() string                  { return "" }
 func (m *Model) Desc() string                       { return "" }
 func (m *Model) GetSequence() string                { return "" }
+func (m *Model) IsCircular() bool                   { return false }
 func (m *Model) OwnerFieldName() string             { return "name" }
+func (m *Model) ShortDescFieldName() string         { return "" }
+func (m *Model) DescFieldName() string              { return "" }
+func (m *Model) SequenceFieldName() string          { return "" }
 func (m *Model) GetCoreLinks() *CoreLinks           { return nil }
 func (m *Model) GetCoreInfoSections() []InfoSection { return nil }
 func (m *Model) GetSequenceInfo() *SequenceInfo     { return nil }
 func (m *Model) GetSupplementalFields() []FieldDef  { return nil }
-func (m *Model) AsResourceDef() string              { return "" }
 
 func Empty(cls string) Entity {
 	switch cls {
@@ -85,14 +87,21 @@ type EntityQueryIterator struct {
 	bufferOffset int
 	pageOffset   int
 	cls          string
+	err          error
 }
 
 func (eqi *EntityQueryIterator) HasNext() bool {
+	if eqi.err != nil {
+		return false
+	}
 	if eqi.buffer != nil && eqi.bufferOffset < len(eqi.buffer) {
 		return true
 	}
 	test := Empty(eqi.cls)
-	eqi.query.Offset(eqi.pageOffset + eqi.bufferOffset).Limit(1).First(test)
+	err := eqi.query.Offset(eqi.pageOffset + eqi.bufferOffset).Limit(1).First(test).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		eqi.err = err
+	}
 	return test.GetID() > 0
 }
 
@@ -101,7 +110,11 @@ func (eqi *EntityQueryIterator) Next() Entity {
 	if eqi.buffer == nil || eqi.bufferOffset >= len(eqi.buffer) {
 		eqi.pageOffset += eqi.bufferOffset
 		eqi.bufferOffset = 0
-		page := RunQuery(eqi.cls, eqi.query.Offset(eqi.pageOffset).Limit(pageSize))
+		page, err := RunQuery(eqi.cls, eqi.query.Offset(eqi.pageOffset).Limit(pageSize))
+		if err != nil || len(page) == 0 {
+			eqi.err = err
+			return nil
+		}
 		eqi.buffer = page
 	}
 	result := eqi.buffer[eqi.bufferOffset]
@@ -109,6 +122,11 @@ func (eqi *EntityQueryIterator) Next() Entity {
 	return result
 }
 
+// Err returns the first database error the iterator ran into, if any.
+func (eqi *EntityQueryIterator) Err() error {
+	return eqi.err
+}
+
 func RunQueryLazy(cls string, db *gorm.DB) *EntityQueryIterator {
 	return &EntityQueryIterator{
 		query:        db,
@@ -119,149 +137,118 @@ func RunQueryLazy(cls string, db *gorm.DB) *EntityQueryIterator {
 	}
 }
 
-func RunQuery(cls string, db *gorm.DB) []Entity {
+func RunQuery(cls string, db *gorm.DB) ([]Entity, error) {
 	entityResult := []Entity{}
+	var err error
 	switch cls {
 	case "plasmid", "plasmids":
 		res := []Plasmid{}
-		db.Find(&res)
+		err = db.Find(&res).Error
 		for i, _ := range res {
 			entityResult = append(entityResult, &res[i])
 		}
 	case "oligo", "oligos":
 		res := []Oligo{}
-		db.Find(&res)
+		err = db.Find(&res).Error
 		for i, _ := range res {
 			entityResult = append(entityResult, &res[i])
 		}
 	case "line", "lines":
 		res := []Line{}
-		db.Find(&res)
+		err = db.Find(&res).Error
 		for i, _ := range res {
 			entityResult = append(entityResult, &res[i])
 		}
 	case "sample", "samples":
 		res := []Sample{}
-		db.Find(&res)
+		err = db.Find(&res).Error
 		for i, _ := range res {
 			entityResult = append(entityResult, &res[i])
 		}
 	case "bacterium", "bacteria":
 		res := []Bacterium{}
-		db.Find(&res)
+		err = db.Find(&res).Error
 		for i, _ := range res {
 			entityResult = append(entityResult, &res[i])
 		}
 	case "yeaststrain", "yeaststrains":
 		res := []Yeaststrain{}
-		db.Find(&res)
+		err = db.Find(&res).Error
 		for i, _ := range res {
 			entityResult = append(entityResult, &res[i])
 		}
 	case "user", "users":
 		res := []User{}
-		db.Find(&res)
+		err = db.Find(&res).Error
 		for i, _ := range res {
 			entityResult = append(entityResult, &res[i])
 		}
 	case "antibody", "antibodies":
 		res := []Antibody{}
-		db.Find(&res)
+		err = db.Find(&res).Error
 		for i, _ := range res {
 			entityResult = append(entityResult, &res[i])
 		}
 	case "rnaiclone", "rnaiclones", "rnai_clone", "rnai_clones":
 		res := []RNAiClone{}
-		db.Find(&res)
+		err = db.Find(&res).Error
 		for i, _ := range res {
 			entityResult = append(entityResult, &res[i])
 		}
 	case "seqlib", "seqlibs", "seq_lib", "seq_libs":
 		res := []SeqLib{}
-		db.Find(&res)
+		err = db.Find(&res).Error
 		for i, _ := range res {
 			entityResult = append(entityResult, &res[i])
 		}
 	default:
 		res := []Model{}
-		db.Find(&res)
+		err = db.Find(&res).Error
 		for i, _ := range res {
 			entityResult = append(entityResult, &res[i])
 		}
 	}
-	return entityResult
-}
-
-func NextID(cls string, currID string) string {
-	ent := Empty(cls)
-	id := Next(ent, currID).GetID()
-	if id != 0 {
-		return strconv.FormatUint(uint64(id), 10)
+	if err != nil {
+		return nil, err
 	}
-	return currID
+	return entityResult, nil
 }
 
-func PrevID(cls string, currID string) string {
-	ent := Empty(cls)
-	id := Prev(ent, currID).GetID()
-	if id != 0 {
-		return strconv.FormatUint(uint64(id), 10)
+// ApplyFields updates e from a JSON object of field values, keyed by field
+// name as in FieldDef.Lookup. Bookkeeping fields (ID, timestamps and the item
+// number) can't be changed this way.
+func ApplyFields(e Entity, fields []byte) error {
+	m := e.model()
+	number := e.GetNumber()
+	if err := json.Unmarshal(fields, e); err != nil {
+		return err
 	}
-	return currID
-}
-
-func Next(e Entity, id string) Entity {
-	db.Where("id > ?", id).First(e)
-	return e
-}
-
-func Prev(e Entity, id string) Entity {
-	db.Where("id < ?", id).Last(e)
-	return e
-}
-
-func NextAvailableNumber(e Entity) int {
-	// TODO(colin): possible race here with multiple people creating items of the
-	// same type at the same time.
-	db.Last(e)
-	return e.GetNumber() + 1
-}
-
-func GetByID(e Entity, id int) {
-	db.First(e, id)
-}
-
-func Create(e Entity) {
-	db.Create(e)
-}
-
-func init() {
-	dbURL := env.DbURL
-	if env.Dev {
-		dbURL = "dbname=labdb sslmode=disable"
+	base := reflect.Indirect(reflect.ValueOf(e))
+	if modelField := base.FieldByName("Model"); modelField.IsValid() {
+		modelField.Set(reflect.ValueOf(m))
 	}
-	pg, err := gorm.Open("postgres", dbURL)
-	if err != nil {
-		panic(err)
+	if numField := base.FieldByName("Number"); numField.IsValid() {
+		numField.SetInt(int64(number))
 	}
-	db = pg
-
-	db.AutoMigrate(&SeqLib{})
-	db.AutoMigrate(&RNAiClone{})
-	db.LogMode(env.DebugDB)
-}
-
-func Shutdown() {
-	if db != nil {
-		db.Close()
+	return nil
+}
+
+// ReplaceFields sets e's fields from a JSON object as ApplyFields does, except
+// that fields left out are cleared rather than kept.
+func ReplaceFields(e Entity, fields []byte) error {
+	m := e.model()
+	number := e.GetNumber()
+	base := reflect.Indirect(reflect.ValueOf(e))
+	base.Set(reflect.Zero(base.Type()))
+	if modelField := base.FieldByName("Model"); modelField.IsValid() {
+		modelField.Set(reflect.ValueOf(m))
 	}
-}
-
-func Db() *gorm.DB {
-	return db
+	if numField := base.FieldByName("Number"); numField.IsValid() {
+		numField.SetInt(int64(number))
+	}
+	return ApplyFields(e, fields)
 }
 
 func IsImplemented(modelType string) bool {
-	return false
-	//  return (modelType == "rnai_clone") || (modelType == "seq_lib")
+	return (modelType == "rnai_clone") || (modelType == "seq_lib")
 }
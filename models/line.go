@@ -8,7 +8,11 @@ type Line struct {
 	Sequence    string
 }
 
-func (l *Line) OwnerFieldName() string { return "entered_by" }
-func (l *Line) ShortDesc() string      { return l.LineAlias }
-func (l *Line) Desc() string           { return l.Description }
-func (l *Line) GetSequence() string    { return l.Sequence }
+func (l *Line) AutoFill(userName string)   { l.EnteredBy = userName }
+func (l *Line) OwnerFieldName() string     { return "entered_by" }
+func (l *Line) ShortDescFieldName() string { return "line_alias" }
+func (l *Line) DescFieldName() string      { return "description" }
+func (l *Line) SequenceFieldName() string  { return "sequence" }
+func (l *Line) ShortDesc() string          { return l.LineAlias }
+func (l *Line) Desc() string               { return l.Description }
+func (l *Line) GetSequence() string        { return l.Sequence }
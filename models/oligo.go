@@ -8,7 +8,11 @@ type Oligo struct {
 	Sequence   string
 }
 
-func (o *Oligo) OwnerFieldName() string { return "entered_by" }
-func (o *Oligo) ShortDesc() string      { return o.Oligoalias }
-func (o *Oligo) Desc() string           { return o.Purpose }
-func (o *Oligo) GetSequence() string    { return o.Sequence }
+func (o *Oligo) AutoFill(userName string)   { o.EnteredBy = userName }
+func (o *Oligo) OwnerFieldName() string     { return "entered_by" }
+func (o *Oligo) ShortDescFieldName() string { return "oligoalias" }
+func (o *Oligo) DescFieldName() string      { return "purpose" }
+func (o *Oligo) SequenceFieldName() string  { return "sequence" }
+func (o *Oligo) ShortDesc() string          { return o.Oligoalias }
+func (o *Oligo) Desc() string               { return o.Purpose }
+func (o *Oligo) GetSequence() string        { return o.Sequence }
@@ -1,5 +1,14 @@
 package models
 
+import (
+	"bytes"
+	"fmt"
+	"text/tabwriter"
+)
+
+// PlasmidFeature is an annotation on a plasmid's sequence.
+type PlasmidFeature = Annotation
+
 // TODO(colin): other fields
 type Plasmid struct {
 	Model
@@ -7,9 +16,55 @@ type Plasmid struct {
 	Description string
 	Sequence    string
 	Creator     string
+	// Features are loaded with the plasmid (see Store.GetByID) and saved
+	// separately with Store.AddAnnotations.
+	Features []PlasmidFeature `gorm:"-"`
 }
 
-func (p *Plasmid) OwnerFieldName() string { return "creator" }
-func (p *Plasmid) ShortDesc() string      { return p.Alias }
-func (p *Plasmid) Desc() string           { return p.Description }
-func (p *Plasmid) GetSequence() string    { return p.Sequence }
+func (p *Plasmid) AutoFill(userName string)   { p.Creator = userName }
+func (p *Plasmid) IsCircular() bool           { return true }
+func (p *Plasmid) OwnerFieldName() string     { return "creator" }
+func (p *Plasmid) ShortDescFieldName() string { return "alias" }
+func (p *Plasmid) DescFieldName() string      { return "description" }
+func (p *Plasmid) SequenceFieldName() string  { return "sequence" }
+func (p *Plasmid) ShortDesc() string          { return p.Alias }
+func (p *Plasmid) Desc() string               { return p.Description }
+func (p *Plasmid) GetSequence() string        { return p.Sequence }
+
+func (p *Plasmid) setAnnotations(as []Annotation) { p.Features = as }
+
+func (p *Plasmid) GetCoreInfoSections() []InfoSection {
+	return []InfoSection{
+		InfoSection{
+			Name:   "Description",
+			Lookup: "Description",
+			Single: true,
+		},
+		InfoSection{
+			Name:         "Features",
+			Preformatted: true,
+			Single:       true,
+			InlineValue:  p.featureTable(),
+		},
+	}
+}
+
+// featureTable lists the plasmid's features, one per line, in 1-based
+// GenBank style positions.
+func (p *Plasmid) featureTable() *string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	for _, f := range p.Features {
+		strand := ""
+		switch {
+		case f.Strand > 0:
+			strand = "+"
+		case f.Strand < 0:
+			strand = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d..%d\t%s\n", f.Name, f.Type, f.Start+1, f.End, strand)
+	}
+	w.Flush()
+	table := buf.String()
+	return &table
+}
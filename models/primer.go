@@ -0,0 +1,106 @@
+package models
+
+import "labdb.org/labdb/sequence"
+
+// PrimerReport describes how an oligo binds a template sequence.
+type PrimerReport struct {
+	OligoID     uint                   `json:"oligoId"`
+	Name        string                 `json:"name"`
+	Sequence    string                 `json:"sequence"`
+	Length      int                    `json:"length"`
+	GCContent   float64                `json:"gcContent"`
+	MeltingTemp float64                `json:"meltingTemp"`
+	Sites       []sequence.BindingSite `json:"sites"`
+}
+
+// PCRProduct is a product predicted from a pair of oligos.
+type PCRProduct struct {
+	ForwardOligo uint `json:"forwardOligo"`
+	ReverseOligo uint `json:"reverseOligo"`
+	sequence.Product
+}
+
+// AnalyzePrimer finds where o binds template, allowing up to maxMismatches
+// mismatches in the 3' end of the primer.
+func AnalyzePrimer(o *Oligo, template Entity, maxMismatches int) PrimerReport {
+	primer := sequence.Normalize(o.Sequence)
+	report := PrimerReport{
+		OligoID:     o.ID,
+		Name:        NameOf(o),
+		Sequence:    primer,
+		Length:      len(primer),
+		GCContent:   sequence.GCContent(primer),
+		MeltingTemp: sequence.MeltingTemp(primer),
+		Sites:       []sequence.BindingSite{},
+	}
+	if primer != "" {
+		target := sequence.Normalize(template.GetSequence())
+		report.Sites = sequence.BindingSites(primer, target, template.IsCircular(), maxMismatches)
+	}
+	return report
+}
+
+// PCRProducts predicts the products of a pair of primers on template, with
+// either primer acting as the forward one.
+func PCRProducts(a, b PrimerReport, template Entity) []PCRProduct {
+	n := len(sequence.Normalize(template.GetSequence()))
+	products := []PCRProduct{}
+	for _, pair := range [][2]PrimerReport{{a, b}, {b, a}} {
+		for _, p := range sequence.Products(pair[0].Sites, pair[1].Sites, n, template.IsCircular()) {
+			products = append(products, PCRProduct{
+				ForwardOligo: pair[0].OligoID,
+				ReverseOligo: pair[1].OligoID,
+				Product:      p,
+			})
+		}
+	}
+	return products
+}
+
+// OffTarget is an item other than the template a designed pair of primers
+// would prime from.
+type OffTarget struct {
+	Kind         string                 `json:"kind"`
+	ID           uint                   `json:"id"`
+	Name         string                 `json:"name"`
+	ForwardSites []sequence.BindingSite `json:"forwardSites"`
+	ReverseSites []sequence.BindingSite `json:"reverseSites"`
+	Products     []sequence.Product     `json:"products"`
+}
+
+// OffTargets finds where a pair of primers prime on items other than
+// template, and what products they'd make there.
+func OffTargets(forward, reverse string, items []Entity, template Entity, maxMismatches int) []OffTarget {
+	primingSites := func(primer, target string, circular bool) []sequence.BindingSite {
+		sites := []sequence.BindingSite{}
+		for _, site := range sequence.BindingSites(primer, target, circular, maxMismatches) {
+			if site.Primes() {
+				sites = append(sites, site)
+			}
+		}
+		return sites
+	}
+	found := []OffTarget{}
+	for _, e := range items {
+		if KindOf(e) == KindOf(template) && e.GetID() == template.GetID() {
+			continue
+		}
+		target := sequence.Normalize(e.GetSequence())
+		f := primingSites(forward, target, e.IsCircular())
+		r := primingSites(reverse, target, e.IsCircular())
+		if len(f) == 0 && len(r) == 0 {
+			continue
+		}
+		products := sequence.Products(f, r, len(target), e.IsCircular())
+		products = append(products, sequence.Products(r, f, len(target), e.IsCircular())...)
+		found = append(found, OffTarget{
+			Kind:         KindOf(e),
+			ID:           e.GetID(),
+			Name:         NameOf(e),
+			ForwardSites: f,
+			ReverseSites: r,
+			Products:     products,
+		})
+	}
+	return found
+}
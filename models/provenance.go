@@ -0,0 +1,66 @@
+package models
+
+import (
+	"context"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Parent records that an item was made from another, e.g. a plasmid
+// assembled from a backbone and inserts.
+type Parent struct {
+	Model
+	ItemKind   string
+	ItemID     uint
+	ParentKind string
+	ParentID   uint
+	// Role is how the parent was used, e.g. "backbone" or "primer".
+	Role string
+}
+
+func (Parent) TableName() string {
+	return "item_parents"
+}
+
+// ParentOf makes a link to parent for an item yet to be saved.
+func ParentOf(parent Entity, role string) Parent {
+	return Parent{ParentKind: KindOf(parent), ParentID: parent.GetID(), Role: role}
+}
+
+// Parents returns what e was made from.
+func (s *Store) Parents(ctx context.Context, e Entity) ([]Parent, error) {
+	db, err := s.with(ctx)
+	if err != nil {
+		return nil, err
+	}
+	parents := []Parent{}
+	err = db.Where("item_kind = ? AND item_id = ?", KindOf(e), e.GetID()).Order("id").Find(&parents).Error
+	return parents, err
+}
+
+// AddParents records what e, which must already have been created, was made
+// from.
+func (s *Store) AddParents(ctx context.Context, e Entity, parents []Parent) error {
+	db, err := s.with(ctx)
+	if err != nil {
+		return err
+	}
+	tx := db.Begin()
+	if err := insertParents(tx, e, parents); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func insertParents(tx *gorm.DB, e Entity, parents []Parent) error {
+	for i := range parents {
+		parents[i].Model = Model{}
+		parents[i].ItemKind = KindOf(e)
+		parents[i].ItemID = e.GetID()
+		if err := tx.Create(&parents[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
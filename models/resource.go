@@ -1,51 +1,179 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jinzhu/gorm"
+)
 
 type FieldDef struct {
-	Name   string
-	Lookup string
-	Type   string
+	Name   string `json:"name"`
+	Lookup string `json:"lookup"`
+	Type   string `json:"type"`
 }
 
 type InfoSection struct {
-	Name         string
-	Preformatted bool
-	Lookup       string
-	Single       bool
-	Fields       []FieldDef
-	InlineValue  *string
+	Name         string     `json:"name"`
+	Preformatted bool       `json:"preformatted"`
+	Lookup       string     `json:"lookup,omitempty"`
+	Single       bool       `json:"single"`
+	Fields       []FieldDef `json:"fields,omitempty"`
+	InlineValue  *string    `json:"inlineValue,omitempty"`
 }
 
 type SequenceInfo struct {
-	Sequence FieldDef
-	Verified FieldDef
+	Sequence FieldDef `json:"sequence"`
+	Verified FieldDef `json:"verified"`
 }
 
 type CoreLinks struct {
-	Lookup string
-	Name   string
-	Links  []string
+	Lookup string   `json:"lookup"`
+	Name   string   `json:"name"`
+	Links  []string `json:"links"`
 }
 
-type CoreInfoSections struct {
+type ResourceDef struct {
+	Type               string        `json:"type"`
+	ID                 int           `json:"id"`
+	Timestamp          time.Time     `json:"timestamp"`
+	FieldData          Entity        `json:"fieldData"`
+	ResourcePath       string        `json:"resourcePath"`
+	Name               string        `json:"name"`
+	ShortDesc          string        `json:"shortDesc"`
+	CoreLinks          *CoreLinks    `json:"coreLinks"`
+	CoreInfoSections   []InfoSection `json:"coreInfoSections"`
+	SequenceInfo       *SequenceInfo `json:"sequenceInfo"`
+	SupplementalFields []FieldDef    `json:"supplementalFields"`
 }
 
-// TODO(colin): json labels
-type ResourceDef struct {
-	Type               string
-	ID                 int
-	Timestamp          time.Time
-	FieldData          Entity
-	ResourcePath       string
-	Name               string
-	ShortDesc          string
-	CoreLinks          *CoreLinks    // TODO(colin): type
-	CoreInfoSections   []InfoSection // TODO(colin): type
-	SequenceInfo       *SequenceInfo // TODO(colin): type
-	SupplementalFields []FieldDef    // TODO(colin): type
+// KindOf returns the snake_case kind of an entity, preferring the model's own
+// Kind() and falling back to its struct name (e.g. SeqLib -> seq_lib).
+func KindOf(e Entity) string {
+	if k := e.Kind(); k != "" {
+		return k
+	}
+	return snakeCase(reflect.Indirect(reflect.ValueOf(e)).Type().Name())
+}
+
+func snakeCase(s string) string {
+	runes := []rune(s)
+	out := []rune{}
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// Only start a new word at a lower->upper boundary so that acronyms
+			// like RNAi stay together.
+			if i > 0 && unicode.IsLower(runes[i-1]) {
+				out = append(out, '_')
+			}
+			r = unicode.ToLower(r)
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
+
+// fieldWithRole finds the struct field tagged `labdb_role:"<role>"`, if any.
+func fieldWithRole(e Entity, role string) (string, bool) {
+	t := reflect.Indirect(reflect.ValueOf(e)).Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("labdb_role") == role {
+			return t.Field(i).Name, true
+		}
+	}
+	return "", false
+}
+
+func defaultSequenceInfo(e Entity) *SequenceInfo {
+	lookup, found := fieldWithRole(e, "Sequence")
+	if !found {
+		if _, hasField := reflect.Indirect(reflect.ValueOf(e)).Type().FieldByName("Sequence"); !hasField {
+			return nil
+		}
+		lookup = "Sequence"
+	}
+	info := &SequenceInfo{
+		Sequence: FieldDef{Name: "Sequence", Lookup: lookup, Type: "sequence"},
+	}
+	if verified, found := fieldWithRole(e, "Verified"); found {
+		info.Verified = FieldDef{Name: "Verified?", Lookup: verified, Type: "boolean"}
+	}
+	return info
+}
+
+// NameOf returns the display name of an entity (e.g. RNAiC12), falling back
+// to its kind and number for models without their own naming.
+func NameOf(e Entity) string {
+	if name := e.GetName(); name != "" {
+		return name
+	}
+	kind := strings.Replace(strings.Title(strings.Replace(KindOf(e), "_", " ", -1)), " ", "", -1)
+	return kind + strconv.Itoa(e.GetNumber())
+}
+
+// ColumnValue returns the value of the field stored in the given database
+// column, formatted as a string, or "" if e has no such column.
+func ColumnValue(e Entity, column string) string {
+	v := reflect.Indirect(reflect.ValueOf(e))
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if gorm.ToDBName(t.Field(i).Name) == column {
+			return fmt.Sprint(v.Field(i).Interface())
+		}
+	}
+	return ""
+}
+
+// SetColumn sets the string field stored in the given database column,
+// returning false if e has no such column.
+func SetColumn(e Entity, column string, value string) bool {
+	v := reflect.Indirect(reflect.ValueOf(e))
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if gorm.ToDBName(t.Field(i).Name) == column && v.Field(i).Kind() == reflect.String {
+			v.Field(i).SetString(value)
+			return true
+		}
+	}
+	return false
+}
+
+// OwnerOf returns the name of the person who owns e.
+func OwnerOf(e Entity) string {
+	return ColumnValue(e, e.OwnerFieldName())
 }
 
 func AsResourceDef(e Entity) ResourceDef {
-	return ResourceDef{}
+	kind := KindOf(e)
+	m := e.model()
+	name := NameOf(e)
+	seqInfo := e.GetSequenceInfo()
+	if seqInfo == nil {
+		seqInfo = defaultSequenceInfo(e)
+	}
+	coreInfoSections := e.GetCoreInfoSections()
+	if coreInfoSections == nil {
+		coreInfoSections = []InfoSection{}
+	}
+	supplementalFields := e.GetSupplementalFields()
+	if supplementalFields == nil {
+		supplementalFields = []FieldDef{}
+	}
+	return ResourceDef{
+		Type:               kind,
+		ID:                 int(e.GetID()),
+		Timestamp:          m.UpdatedAt,
+		FieldData:          e,
+		ResourcePath:       "/" + kind + "/" + strconv.FormatUint(uint64(e.GetID()), 10),
+		Name:               name,
+		ShortDesc:          e.ShortDesc(),
+		CoreLinks:          e.GetCoreLinks(),
+		CoreInfoSections:   coreInfoSections,
+		SequenceInfo:       seqInfo,
+		SupplementalFields: supplementalFields,
+	}
 }
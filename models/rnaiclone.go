@@ -86,7 +86,6 @@ func (r *RNAiClone) AutoFill(userName string) {
 	r.HostStrain = "HT115"
 	r.PlasmidBackbone = "L4440"
 	r.Antibiotic = "Amp"
-	r.Number = NextAvailableNumber(r)
 }
 
 func (r RNAiClone) TableName() string {
@@ -106,7 +105,9 @@ func (r *RNAiClone) GetName() string {
 	return "RNAiC" + strconv.Itoa(r.GetNumber())
 }
 
-func (r *RNAiClone) OwnerFieldName() string { return "entered_by" }
+func (r *RNAiClone) OwnerFieldName() string     { return "entered_by" }
+func (r *RNAiClone) ShortDescFieldName() string { return "alias" }
+func (r *RNAiClone) DescFieldName() string      { return "description" }
 func (r *RNAiClone) ShortDesc() string {
 	return r.Alias
 }
@@ -116,3 +117,6 @@ func (r *RNAiClone) Desc() string {
 }
 
 func (r *RNAiClone) GetCoreLinks() *CoreLinks { return nil }
+
+func (r *RNAiClone) SetNumber(n int)    { r.Number = n }
+func (r *RNAiClone) SetVerified(v bool) { r.Sequenced = v }
@@ -0,0 +1,276 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Role is what a user is allowed to do by default.
+type Role string
+
+const (
+	// RoleViewer can read everything.
+	RoleViewer Role = "viewer"
+	// RoleMember can also add entries and edit the ones they own.
+	RoleMember Role = "member"
+	// RoleManager can edit everyone's entries, but not users.
+	RoleManager Role = "manager"
+	// RoleAdmin can do anything, including managing users.
+	RoleAdmin Role = "admin"
+)
+
+var Roles = []Role{RoleViewer, RoleMember, RoleManager, RoleAdmin}
+
+// Access is a level of access to one kind of model.
+type Access string
+
+const (
+	AccessNone Access = "none"
+	AccessRead Access = "read"
+	// AccessOwn allows adding entries and editing those the user owns,
+	// according to their OwnerFieldName.
+	AccessOwn Access = "own"
+	AccessAll Access = "all"
+)
+
+var accessLevels = []Access{AccessNone, AccessRead, AccessOwn, AccessAll}
+
+func (a Access) level() int {
+	for i, l := range accessLevels {
+		if a == l {
+			return i
+		}
+	}
+	return 0
+}
+
+// Valid reports whether a is a known level of access.
+func (a Access) Valid() bool {
+	for _, l := range accessLevels {
+		if a == l {
+			return true
+		}
+	}
+	return false
+}
+
+// AtLeast reports whether a allows everything b does.
+func (a Access) AtLeast(b Access) bool { return a.level() >= b.level() }
+
+// Access returns the access r gives to kind when there's no grant for it.
+func (r Role) Access(kind string) Access {
+	switch r {
+	case RoleViewer:
+		return AccessRead
+	case RoleMember:
+		if kind == "user" {
+			return AccessRead
+		}
+		return AccessOwn
+	case RoleManager:
+		if kind == "user" {
+			return AccessRead
+		}
+		return AccessAll
+	case RoleAdmin:
+		return AccessAll
+	}
+	return AccessNone
+}
+
+// Valid reports whether r is one of Roles.
+func (r Role) Valid() bool {
+	for _, known := range Roles {
+		if r == known {
+			return true
+		}
+	}
+	return false
+}
+
+// EffectiveRole returns u's role. Users from before roles existed get the
+// closest one to their old permissions.
+func (u *User) EffectiveRole() Role {
+	switch {
+	case u.Role != "":
+		return Role(u.Role)
+	case u.AuthAdmin:
+		return RoleAdmin
+	case u.AuthWrite:
+		return RoleManager
+	case u.AuthRead:
+		return RoleViewer
+	}
+	return ""
+}
+
+// Grant overrides the access a user's role gives them to one kind of model.
+type Grant struct {
+	Model
+	UserID   uint
+	ItemKind string
+	Access   Access
+}
+
+// KnownKind reports whether kind names a model, in any of the forms Empty
+// accepts, returning its canonical kind.
+func KnownKind(kind string) (string, bool) {
+	k := KindOf(Empty(kind))
+	return k, k != "model"
+}
+
+// Permissions are what a user may do, combining their role and grants.
+type Permissions struct {
+	User   User
+	Role   Role
+	Grants map[string]Access
+	// ReadOnly limits everything to reading, e.g. for read-only API tokens.
+	ReadOnly bool
+}
+
+// Permissions looks up u's role and grants. Deactivated users get no access.
+func (s *Store) Permissions(ctx context.Context, u User) (Permissions, error) {
+	p := Permissions{User: u, Role: u.EffectiveRole(), Grants: map[string]Access{}}
+	if u.ID == 0 || u.Deactivated {
+		p.Role = ""
+		return p, nil
+	}
+	gs, err := s.Grants(ctx, u)
+	if err != nil {
+		return Permissions{}, err
+	}
+	for _, g := range gs {
+		p.Grants[g.ItemKind] = g.Access
+	}
+	return p, nil
+}
+
+// Access returns the access p gives to kind.
+func (p Permissions) Access(kind string) Access {
+	if k, ok := KnownKind(kind); ok {
+		kind = k
+	}
+	a, ok := p.Grants[kind]
+	if !ok {
+		a = p.Role.Access(kind)
+	}
+	if p.ReadOnly && a.AtLeast(AccessRead) {
+		return AccessRead
+	}
+	return a
+}
+
+// CanRead reports whether p allows reading items of kind.
+func (p Permissions) CanRead(kind string) bool { return p.Access(kind).AtLeast(AccessRead) }
+
+// CanWrite reports whether p allows adding items of kind, and so editing at
+// least some of them.
+func (p Permissions) CanWrite(kind string) bool { return p.Access(kind).AtLeast(AccessOwn) }
+
+// CanEdit reports whether p allows changing e.
+func (p Permissions) CanEdit(e Entity) bool {
+	switch p.Access(KindOf(e)) {
+	case AccessAll:
+		return true
+	case AccessOwn:
+		return p.Owns(e)
+	}
+	return false
+}
+
+// Owns reports whether p's user is e's owner.
+func (p Permissions) Owns(e Entity) bool {
+	return p.User.Name != "" && ColumnValue(e, e.OwnerFieldName()) == p.User.Name
+}
+
+// most returns the most access p gives to any kind.
+func (p Permissions) most() Access {
+	most := p.Role.Access("")
+	if users := p.Role.Access("user"); users.AtLeast(most) {
+		most = users
+	}
+	for _, a := range p.Grants {
+		if a.AtLeast(most) {
+			most = a
+		}
+	}
+	if p.ReadOnly && most.AtLeast(AccessRead) {
+		return AccessRead
+	}
+	return most
+}
+
+// CanReadAny reports whether p allows reading anything at all.
+func (p Permissions) CanReadAny() bool { return p.most().AtLeast(AccessRead) }
+
+// CanWriteAny reports whether p allows changing anything at all.
+func (p Permissions) CanWriteAny() bool { return p.most().AtLeast(AccessOwn) }
+
+// CanEditAll reports whether p allows changing every item of every kind.
+func (p Permissions) CanEditAll() bool {
+	if p.Access("") != AccessAll {
+		return false
+	}
+	for kind := range p.Grants {
+		if p.Access(kind) != AccessAll {
+			return false
+		}
+	}
+	return true
+}
+
+// IsAdmin reports whether p allows managing users.
+// TODO(colin): drop AuthAdmin once everyone has a role.
+func (p Permissions) IsAdmin() bool {
+	return !p.ReadOnly && !p.User.Deactivated && (p.Role == RoleAdmin || p.User.AuthAdmin)
+}
+
+// Grants returns u's grants.
+func (s *Store) Grants(ctx context.Context, u User) ([]Grant, error) {
+	db, err := s.with(ctx)
+	if err != nil {
+		return nil, err
+	}
+	gs := []Grant{}
+	err = db.Where("user_id = ?", u.ID).Order("item_kind").Find(&gs).Error
+	return gs, err
+}
+
+// SetGrant gives u access a to kind, or removes their grant for it if a is
+// "", so that their role applies. actor is recorded as having made the
+// change.
+func (s *Store) SetGrant(ctx context.Context, actor string, u User, kind string, a Access) error {
+	k, ok := KnownKind(kind)
+	if !ok {
+		return fmt.Errorf("unknown kind %q", kind)
+	}
+	if a != "" && !a.Valid() {
+		return fmt.Errorf("unknown access %q", a)
+	}
+	if a.AtLeast(AccessOwn) && strings.TrimSpace(u.Name) == "" {
+		return ErrNeedsName
+	}
+	db, err := s.with(ctx)
+	if err != nil {
+		return err
+	}
+	tx := db.Begin()
+	if err := tx.Where("user_id = ? AND item_kind = ?", u.ID, k).Delete(&Grant{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	details := k + " -> role default"
+	if a != "" {
+		if err := tx.Create(&Grant{UserID: u.ID, ItemKind: k, Access: a}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		details = k + " -> " + string(a)
+	}
+	if err := audit(tx, actor, u, "grant", details); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
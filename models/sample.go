@@ -7,6 +7,8 @@ type Sample struct {
 	SampleAlias string
 }
 
-func (s *Sample) OwnerFieldName() string { return "entered_by" }
-func (s *Sample) ShortDesc() string      { return s.SampleAlias }
-func (s *Sample) Desc() string           { return s.Description }
+func (s *Sample) OwnerFieldName() string     { return "entered_by" }
+func (s *Sample) ShortDescFieldName() string { return "sample_alias" }
+func (s *Sample) DescFieldName() string      { return "description" }
+func (s *Sample) ShortDesc() string          { return s.SampleAlias }
+func (s *Sample) Desc() string               { return s.Description }
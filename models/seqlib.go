@@ -1,5 +1,7 @@
 package models
 
+import "labdb.org/labdb/sequence"
+
 type SeqLib struct {
 	Model
 	Genome           string
@@ -20,14 +22,98 @@ type SeqLib struct {
 
 func (r *SeqLib) AutoFill(userName string) {
 	r.EnteredBy = userName
-	r.Number = NextAvailableNumber(r)
 }
 
 func (r *SeqLib) GetNumber() int {
 	return r.Number
 }
 
-func (r *SeqLib) OwnerFieldName() string { return "entered_by" }
-func (r *SeqLib) ShortDesc() string      { return r.Alias }
-func (r *SeqLib) Desc() string           { return r.Description }
-func (r *SeqLib) Sequence() string       { return r.IndexSeq }
+func (r *SeqLib) SetNumber(n int) { r.Number = n }
+
+func (r *SeqLib) OwnerFieldName() string     { return "entered_by" }
+func (r *SeqLib) ShortDescFieldName() string { return "alias" }
+func (r *SeqLib) DescFieldName() string      { return "description" }
+func (r *SeqLib) SequenceFieldName() string  { return "index_seq" }
+func (r *SeqLib) ShortDesc() string          { return r.Alias }
+func (r *SeqLib) Desc() string               { return r.Description }
+func (r *SeqLib) GetSequence() string        { return r.IndexSeq }
+func (r *SeqLib) Kind() string               { return "seq_lib" }
+func (r *SeqLib) GetCoreLinks() *CoreLinks   { return nil }
+
+// SequenceAlphabet allows only ACGT, as index sequences are read exactly.
+func (r *SeqLib) SequenceAlphabet() sequence.Alphabet { return sequence.ACGT }
+
+func (r *SeqLib) GetCoreInfoSections() []InfoSection {
+	return []InfoSection{
+		InfoSection{
+			Name:   "Description",
+			Lookup: "Description",
+			Single: true,
+		},
+		InfoSection{
+			Name: "Library Information",
+			Fields: []FieldDef{
+				FieldDef{
+					Name:   "Genome",
+					Lookup: "Genome",
+					Type:   "value",
+				},
+				FieldDef{
+					Name:   "Method",
+					Lookup: "Method",
+					Type:   "value",
+				},
+				FieldDef{
+					Name:   "Project",
+					Lookup: "Project",
+					Type:   "value",
+				},
+				FieldDef{
+					Name:   "Concentration",
+					Lookup: "Concentration",
+					Type:   "value",
+				},
+				FieldDef{
+					Name:   "Size distribution",
+					Lookup: "SizeDistribution",
+					Type:   "value",
+				},
+				FieldDef{
+					Name:   "Index ID",
+					Lookup: "IndexID",
+					Type:   "value",
+				},
+				FieldDef{
+					Name:   "Storage location",
+					Lookup: "StorageLocation",
+					Type:   "value",
+				},
+			},
+		},
+		InfoSection{
+			Name:   "Linked items",
+			Lookup: "LinkedItems",
+			Single: true,
+		},
+	}
+}
+
+func (r *SeqLib) GetSupplementalFields() []FieldDef {
+	return []FieldDef{
+		FieldDef{
+			Name:   "Entered by",
+			Lookup: "EnteredBy",
+			Type:   "value",
+		},
+		FieldDef{
+			Name:   "Date entered",
+			Lookup: "CreatedAt",
+			Type:   "value",
+		},
+		FieldDef{
+			Name:   "Notebook",
+			Lookup: "Notebook",
+			Type:   "value",
+		},
+	}
+}
@@ -0,0 +1,369 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres" // Enables the postgres driver for gorm.
+)
+
+// ErrNotFound is returned when the requested item doesn't exist, as opposed to
+// the database failing to answer.
+var ErrNotFound = errors.New("not found")
+
+// Store is the entry point for all database access.
+type Store struct {
+	db          *gorm.DB
+	saveHooks   []func(Entity)
+	deleteHooks []func(Entity)
+	// PreserveSequenceCase stops sequences being upper cased when they're
+	// saved.
+	PreserveSequenceCase bool
+}
+
+// OnSave registers f to be called after each successful Create or Save. Hooks
+// should be registered before the store is used.
+func (s *Store) OnSave(f func(Entity)) {
+	s.saveHooks = append(s.saveHooks, f)
+}
+
+// OnDelete registers f to be called after each successful Delete.
+func (s *Store) OnDelete(f func(Entity)) {
+	s.deleteHooks = append(s.deleteHooks, f)
+}
+
+func runHooks(hooks []func(Entity), e Entity) {
+	for _, f := range hooks {
+		f(e)
+	}
+}
+
+// NewStore connects to the database at dbURL and brings the natively managed
+// tables up to date.
+func NewStore(dbURL string, debug bool) (*Store, error) {
+	pg, err := gorm.Open("postgres", dbURL)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{db: pg}
+	if err := s.migrate(); err != nil {
+		pg.Close()
+		return nil, err
+	}
+	pg.LogMode(debug)
+	return s, nil
+}
+
+func (s *Store) migrate() error {
+	err := s.db.AutoMigrate(&ItemCounter{}, &SeqLib{}, &RNAiClone{}, &Annotation{}, &Parent{}, &Verification{}, &APIToken{}, &Grant{}, &AuditEntry{}).Error
+	if err != nil {
+		return err
+	}
+	// The users table predates the store; this only adds the columns for
+	// roles and user management.
+	err = s.db.AutoMigrate(&User{}).Error
+	if err != nil {
+		return err
+	}
+	err = s.db.Model(&Annotation{}).AddIndex("idx_annotations_item", "item_kind", "item_id").Error
+	if err != nil {
+		return err
+	}
+	err = s.db.Model(&Parent{}).AddIndex("idx_item_parents_item", "item_kind", "item_id").Error
+	if err != nil {
+		return err
+	}
+	err = s.db.Model(&Verification{}).AddIndex("idx_verifications_item", "item_kind", "item_id").Error
+	if err != nil {
+		return err
+	}
+	err = s.db.Model(&APIToken{}).AddUniqueIndex("idx_api_tokens_hash", "hash").Error
+	if err != nil {
+		return err
+	}
+	err = s.db.Model(&APIToken{}).AddIndex("idx_api_tokens_user", "user_id").Error
+	if err != nil {
+		return err
+	}
+	err = s.db.Model(&Grant{}).AddUniqueIndex("idx_grants_user_kind", "user_id", "item_kind").Error
+	if err != nil {
+		return err
+	}
+	err = s.db.Model(&AuditEntry{}).AddIndex("idx_audit_entries_user", "user_id").Error
+	if err != nil {
+		return err
+	}
+	s.addNumberIndex(&SeqLib{})
+	s.addNumberIndex(&RNAiClone{})
+	return nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Db gives direct access to the underlying connection for building queries to
+// pass to RunQuery.
+func (s *Store) Db() *gorm.DB {
+	return s.db
+}
+
+// with returns a handle for running a query on behalf of ctx, or ctx's error
+// if it's already been cancelled.
+func (s *Store) with(ctx context.Context) (*gorm.DB, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.db, nil
+}
+
+func notFoundOr(err error) error {
+	if err == gorm.ErrRecordNotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *Store) GetByID(ctx context.Context, cls string, id int) (Entity, error) {
+	db, err := s.with(ctx)
+	if err != nil {
+		return nil, err
+	}
+	e := Empty(cls)
+	if err := db.First(e, id).Error; err != nil {
+		return nil, notFoundOr(err)
+	}
+	if a, ok := e.(annotated); ok {
+		as, err := s.Annotations(ctx, e)
+		if err != nil {
+			return nil, err
+		}
+		a.setAnnotations(as)
+	}
+	return e, nil
+}
+
+// Next returns the item of the given type following the one with ID id.
+func (s *Store) Next(ctx context.Context, cls string, id string) (Entity, error) {
+	db, err := s.with(ctx)
+	if err != nil {
+		return nil, err
+	}
+	e := Empty(cls)
+	if err := db.Where("id > ?", id).First(e).Error; err != nil {
+		return nil, notFoundOr(err)
+	}
+	return e, nil
+}
+
+// Prev returns the item of the given type preceding the one with ID id.
+func (s *Store) Prev(ctx context.Context, cls string, id string) (Entity, error) {
+	db, err := s.with(ctx)
+	if err != nil {
+		return nil, err
+	}
+	e := Empty(cls)
+	if err := db.Where("id < ?", id).Last(e).Error; err != nil {
+		return nil, notFoundOr(err)
+	}
+	return e, nil
+}
+
+// NextID returns the ID following currID, or currID itself if it's the last.
+func (s *Store) NextID(ctx context.Context, cls string, currID string) (string, error) {
+	e, err := s.Next(ctx, cls, currID)
+	return idOrCurrent(e, err, currID)
+}
+
+// PrevID returns the ID preceding currID, or currID itself if it's the first.
+func (s *Store) PrevID(ctx context.Context, cls string, currID string) (string, error) {
+	e, err := s.Prev(ctx, cls, currID)
+	return idOrCurrent(e, err, currID)
+}
+
+func idOrCurrent(e Entity, err error, currID string) (string, error) {
+	if err == ErrNotFound {
+		return currID, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(uint64(e.GetID()), 10), nil
+}
+
+const maxCreateAttempts = 3
+
+// Create inserts e, allocating it the next item number first if it's a
+// numbered model that doesn't have one yet. Invalid items are rejected with a
+// ValidationError.
+func (s *Store) Create(ctx context.Context, e Entity) error {
+	return s.CreateWith(ctx, e, nil, nil)
+}
+
+// CreateWith creates e as Create does, along with its annotations and what it
+// was made from, all in one transaction.
+func (s *Store) CreateWith(ctx context.Context, e Entity, as []Annotation, parents []Parent) error {
+	db, err := s.with(ctx)
+	if err != nil {
+		return err
+	}
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := s.create(ctx, tx, e, as, parents); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	runHooks(s.saveHooks, e)
+	return nil
+}
+
+// CreateAll creates each of es as Create does, all made from parents, in one
+// transaction.
+func (s *Store) CreateAll(ctx context.Context, es []Entity, parents []Parent) error {
+	db, err := s.with(ctx)
+	if err != nil {
+		return err
+	}
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	for _, e := range es {
+		if err := s.create(ctx, tx, e, nil, parents); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	for _, e := range es {
+		runHooks(s.saveHooks, e)
+	}
+	return nil
+}
+
+// create inserts e and its annotations and parents in tx. Each attempt is
+// made under a savepoint, so that losing a race for a number doesn't abort
+// the rest of the transaction.
+func (s *Store) create(ctx context.Context, tx *gorm.DB, e Entity, as []Annotation, parents []Parent) error {
+	if err := NormalizeSequence(e, !s.PreserveSequenceCase); err != nil {
+		return err
+	}
+	n, isNumbered := e.(numbered)
+	if isNumbered && e.GetNumber() == 0 {
+		number, err := s.NextAvailableNumber(ctx, e)
+		if err != nil {
+			return err
+		}
+		n.SetNumber(number)
+	}
+	for attempt := 1; ; attempt++ {
+		if err := tx.Exec("SAVEPOINT creating").Error; err != nil {
+			return err
+		}
+		err := tx.Create(e).Error
+		if err == nil {
+			if err := insertAnnotations(tx, e, as); err != nil {
+				return err
+			}
+			if err := insertParents(tx, e, parents); err != nil {
+				return err
+			}
+			return tx.Exec("RELEASE SAVEPOINT creating").Error
+		}
+		if err := tx.Exec("ROLLBACK TO SAVEPOINT creating").Error; err != nil {
+			return err
+		}
+		if !isUniqueViolation(err) || !isNumbered || attempt == maxCreateAttempts {
+			return err
+		}
+		// Someone else got this number (e.g. an item created before the counter
+		// table existed, or a number set by hand), so take the next one.
+		number, err := s.NextAvailableNumber(ctx, e)
+		if err != nil {
+			return err
+		}
+		n.SetNumber(number)
+	}
+}
+
+// Save updates e, rejecting invalid items as Create does.
+func (s *Store) Save(ctx context.Context, e Entity) error {
+	db, err := s.with(ctx)
+	if err != nil {
+		return err
+	}
+	if err := NormalizeSequence(e, !s.PreserveSequenceCase); err != nil {
+		return err
+	}
+	if err := db.Save(e).Error; err != nil {
+		return err
+	}
+	runHooks(s.saveHooks, e)
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, e Entity) error {
+	db, err := s.with(ctx)
+	if err != nil {
+		return err
+	}
+	if err := db.Delete(e).Error; err != nil {
+		return err
+	}
+	runHooks(s.deleteHooks, e)
+	return nil
+}
+
+// List returns a page of entities of the given type, newest first.
+func (s *Store) List(ctx context.Context, cls string, offset int, limit int) ([]Entity, error) {
+	db, err := s.with(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return RunQuery(cls, db.Order("id desc").Offset(offset).Limit(limit))
+}
+
+func (s *Store) Count(ctx context.Context, cls string) (int, error) {
+	db, err := s.with(ctx)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	err = db.Model(Empty(cls)).Count(&count).Error
+	return count, err
+}
+
+// UserByEmail looks up a user by email address, ignoring case.
+func (s *Store) UserByEmail(ctx context.Context, email string) (User, error) {
+	db, err := s.with(ctx)
+	if err != nil {
+		return User{}, err
+	}
+	u := User{}
+	if err := db.Where("lower(email) = lower(?)", email).First(&u).Error; err != nil {
+		return User{}, notFoundOr(err)
+	}
+	return u, nil
+}
+
+// WithSequences returns every item of the given type that has a sequence.
+func (s *Store) WithSequences(ctx context.Context, cls string) ([]Entity, error) {
+	db, err := s.with(ctx)
+	if err != nil {
+		return nil, err
+	}
+	column := Empty(cls).SequenceFieldName()
+	if column == "" {
+		return []Entity{}, nil
+	}
+	return RunQuery(cls, db.Where(column+" <> ''").Order("id desc"))
+}
@@ -1,5 +1,13 @@
 package models
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
 type User struct {
 	Model
 	Email     string
@@ -8,14 +16,163 @@ type User struct {
 	AuthAdmin bool
 	Name      string
 	Notes     string
+	// Role is one of Roles, or "" for users from before roles existed (see
+	// EffectiveRole).
+	Role        string `gorm:"not null;default:''"`
+	Deactivated bool   `gorm:"not null;default:false"`
+	// LastLoginAt is nil for users invited who haven't signed in yet.
+	LastLoginAt *time.Time
+}
+
+func (u *User) OwnerFieldName() string     { return "name" }
+func (u *User) ShortDescFieldName() string { return "email" }
+func (u *User) DescFieldName() string      { return "notes" }
+func (u *User) ShortDesc() string          { return u.Email }
+func (u *User) Desc() string               { return u.Notes }
+
+// ErrExists is returned when inviting someone who already has a user.
+var ErrExists = errors.New("already exists")
+
+// ErrNeedsName is returned when giving someone without a name access to add
+// entries, which record their owner by name.
+var ErrNeedsName = errors.New("users who can add entries need a name")
+
+// needsName reports whether u can own entries but has no name to own them by.
+// Grants are checked separately, by SetGrant.
+func (u *User) needsName() bool {
+	r := u.EffectiveRole()
+	return !u.Deactivated && r != "" && r != RoleViewer && strings.TrimSpace(u.Name) == ""
+}
+
+// Users returns every user, ordered by email address.
+func (s *Store) Users(ctx context.Context) ([]User, error) {
+	db, err := s.with(ctx)
+	if err != nil {
+		return nil, err
+	}
+	us := []User{}
+	err = db.Order("email").Find(&us).Error
+	return us, err
 }
 
-func UserByEmail(email string) User {
+func (s *Store) UserByID(ctx context.Context, id uint) (User, error) {
+	db, err := s.with(ctx)
+	if err != nil {
+		return User{}, err
+	}
 	u := User{}
-	db.Where(&User{Email: email}).First(&u)
-	return u
+	if err := db.First(&u, id).Error; err != nil {
+		return User{}, notFoundOr(err)
+	}
+	return u, nil
 }
 
-func (u *User) OwnerFieldName() string { return "name" }
-func (u *User) ShortDesc() string      { return u.Email }
-func (u *User) Desc() string           { return u.Notes }
+// InviteUser adds u before they first sign in, so they have access as soon
+// as they do. Email addresses are stored lower cased.
+func (s *Store) InviteUser(ctx context.Context, actor string, u *User) error {
+	db, err := s.with(ctx)
+	if err != nil {
+		return err
+	}
+	if u.Role != "" && !Role(u.Role).Valid() {
+		return fmt.Errorf("unknown role %q", u.Role)
+	}
+	if u.needsName() {
+		return ErrNeedsName
+	}
+	u.Email = strings.ToLower(strings.TrimSpace(u.Email))
+	count := 0
+	if err := db.Model(&User{}).Where("lower(email) = lower(?)", u.Email).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrExists
+	}
+	u.Model = Model{}
+	u.LastLoginAt = nil
+	tx := db.Begin()
+	if err := tx.Create(u).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := audit(tx, actor, *u, "invite", strings.Join(userChanges(User{}, *u), ", ")); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// UpdateUser saves the access and name of u, recording what changed since
+// before.
+func (s *Store) UpdateUser(ctx context.Context, actor string, before User, u *User) error {
+	if u.Role != "" && !Role(u.Role).Valid() {
+		return fmt.Errorf("unknown role %q", u.Role)
+	}
+	// Users from before names were needed can still be changed in other ways.
+	if u.needsName() && !before.needsName() {
+		return ErrNeedsName
+	}
+	changes := userChanges(before, *u)
+	if len(changes) == 0 {
+		return nil
+	}
+	db, err := s.with(ctx)
+	if err != nil {
+		return err
+	}
+	tx := db.Begin()
+	err = tx.Model(u).Updates(map[string]interface{}{
+		"name":        u.Name,
+		"auth_read":   u.AuthRead,
+		"auth_write":  u.AuthWrite,
+		"auth_admin":  u.AuthAdmin,
+		"role":        u.Role,
+		"deactivated": u.Deactivated,
+	}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := audit(tx, actor, *u, "update", strings.Join(changes, ", ")); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// userChanges describes the differences in what UpdateUser saves.
+func userChanges(before, after User) []string {
+	changes := []string{}
+	note := func(field string, from, to interface{}) {
+		if from != to {
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v", field, from, to))
+		}
+	}
+	note("email", before.Email, after.Email)
+	note("name", before.Name, after.Name)
+	note("read", before.AuthRead, after.AuthRead)
+	note("write", before.AuthWrite, after.AuthWrite)
+	note("admin", before.AuthAdmin, after.AuthAdmin)
+	note("role", before.Role, after.Role)
+	note("deactivated", before.Deactivated, after.Deactivated)
+	return changes
+}
+
+// RecordLogin notes that the user with the given email address just signed
+// in, returning ErrNotFound if there isn't one.
+func (s *Store) RecordLogin(ctx context.Context, email string) (User, error) {
+	u, err := s.UserByEmail(ctx, strings.ToLower(email))
+	if err != nil {
+		return User{}, err
+	}
+	db, err := s.with(ctx)
+	if err != nil {
+		return User{}, err
+	}
+	now := time.Now()
+	if err := db.Model(&u).UpdateColumn("last_login_at", now).Error; err != nil {
+		return User{}, err
+	}
+	u.LastLoginAt = &now
+	return u, nil
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"reflect"
+
+	"labdb.org/labdb/sequence"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ValidationError is returned by Create and Save for items with a field that
+// can't be stored as it is.
+type ValidationError struct {
+	Field string
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+// sequenceAlphabet is implemented by models whose sequences are restricted
+// to something narrower than IUPAC DNA.
+type sequenceAlphabet interface {
+	SequenceAlphabet() sequence.Alphabet
+}
+
+// fieldForColumn returns the name of the field stored in column.
+func fieldForColumn(e Entity, column string) string {
+	t := reflect.Indirect(reflect.ValueOf(e)).Type()
+	for i := 0; i < t.NumField(); i++ {
+		if gorm.ToDBName(t.Field(i).Name) == column {
+			return t.Field(i).Name
+		}
+	}
+	return column
+}
+
+// NormalizeSequence cleans up e's sequence (see sequence.Clean) and checks it
+// only has characters allowed for e.
+func NormalizeSequence(e Entity, fold bool) error {
+	column := e.SequenceFieldName()
+	if column == "" {
+		return nil
+	}
+	seq := sequence.Clean(ColumnValue(e, column), fold)
+	alphabet := sequence.IUPAC
+	if a, ok := e.(sequenceAlphabet); ok {
+		alphabet = a.SequenceAlphabet()
+	}
+	if err := alphabet.Validate(seq); err != nil {
+		return &ValidationError{Field: fieldForColumn(e, column), Err: err}
+	}
+	SetColumn(e, column, seq)
+	return nil
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"context"
+)
+
+// Verification records that an item's sequence was checked against
+// sequencing reads and found to match.
+type Verification struct {
+	Model
+	ItemKind string
+	ItemID   uint
+	// Reads names the reads used, comma separated.
+	Reads      string
+	Identity   float64
+	Coverage   float64
+	VerifiedBy string
+}
+
+// verifiable is implemented by models with their own field recording that
+// they've been sequenced.
+type verifiable interface {
+	SetVerified(bool)
+}
+
+// Verifications returns the checks recorded for e, newest first.
+func (s *Store) Verifications(ctx context.Context, e Entity) ([]Verification, error) {
+	db, err := s.with(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vs := []Verification{}
+	err = db.Where("item_kind = ? AND item_id = ?", KindOf(e), e.GetID()).Order("id desc").Find(&vs).Error
+	return vs, err
+}
+
+// MarkVerified records v against e, and sets e's own flag if it has one.
+func (s *Store) MarkVerified(ctx context.Context, e Entity, v *Verification) error {
+	db, err := s.with(ctx)
+	if err != nil {
+		return err
+	}
+	v.Model = Model{}
+	v.ItemKind = KindOf(e)
+	v.ItemID = e.GetID()
+	tx := db.Begin()
+	if err := tx.Create(v).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if ve, ok := e.(verifiable); ok {
+		ve.SetVerified(true)
+		if err := tx.Save(e).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	runHooks(s.saveHooks, e)
+	return nil
+}
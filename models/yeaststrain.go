@@ -8,7 +8,11 @@ type Yeaststrain struct {
 	Sequence    string
 }
 
-func (y *Yeaststrain) OwnerFieldName() string { return "entered_by" }
-func (y *Yeaststrain) ShortDesc() string      { return y.Strainalias }
-func (y *Yeaststrain) Desc() string           { return y.Comments }
-func (y *Yeaststrain) GetSequence() string    { return y.Sequence }
+func (y *Yeaststrain) AutoFill(userName string)   { y.EnteredBy = userName }
+func (y *Yeaststrain) OwnerFieldName() string     { return "entered_by" }
+func (y *Yeaststrain) ShortDescFieldName() string { return "strainalias" }
+func (y *Yeaststrain) DescFieldName() string      { return "comments" }
+func (y *Yeaststrain) SequenceFieldName() string  { return "sequence" }
+func (y *Yeaststrain) ShortDesc() string          { return y.Strainalias }
+func (y *Yeaststrain) Desc() string               { return y.Comments }
+func (y *Yeaststrain) GetSequence() string        { return y.Sequence }
@@ -0,0 +1,79 @@
+package primers
+
+import (
+	"labdb.org/labdb/sequence"
+)
+
+// minHairpinLoop is the fewest unpaired bases a hairpin loop can have.
+const minHairpinLoop = 3
+
+// Complementarity returns the longest run of consecutive base pairs a and b
+// (both 5'->3') can form annealed to each other, and the longest such run
+// that includes the 3' end of a, which is what polymerase can extend.
+func Complementarity(a, b string) (any int, threePrime int) {
+	rc := sequence.ReverseComplement(b)
+	// run[j] is the length of the run ending at a[i-1], rc[j-1].
+	run := make([]int, len(rc)+1)
+	for i := 1; i <= len(a); i++ {
+		for j := len(rc); j >= 1; j-- {
+			if a[i-1] == rc[j-1] {
+				run[j] = run[j-1] + 1
+			} else {
+				run[j] = 0
+			}
+			if run[j] > any {
+				any = run[j]
+			}
+			if i == len(a) && run[j] > threePrime {
+				threePrime = run[j]
+			}
+		}
+	}
+	return any, threePrime
+}
+
+// HairpinStem returns the length of the longest stem s can fold back on
+// itself to form, leaving a loop of at least minHairpinLoop bases.
+func HairpinStem(s string) int {
+	best := 0
+	for i := 0; i < len(s); i++ {
+		for j := len(s) - 1; j > i; j-- {
+			// Pair outwards-in from s[i] with s[j].
+			k := 0
+			for i+k < j-k-minHairpinLoop && complement(s[i+k]) == s[j-k] {
+				k++
+			}
+			if k > best {
+				best = k
+			}
+		}
+	}
+	return best
+}
+
+func complement(b byte) byte {
+	return sequence.ReverseComplement(string(b))[0]
+}
+
+// HasGCClamp reports whether p ends in a G or C, without more than three of
+// them in its last five bases.
+func HasGCClamp(p string) bool {
+	if p == "" {
+		return false
+	}
+	last := p[len(p)-1]
+	if last != 'G' && last != 'C' {
+		return false
+	}
+	tail := p
+	if len(tail) > 5 {
+		tail = tail[len(tail)-5:]
+	}
+	gc := 0
+	for i := 0; i < len(tail); i++ {
+		if tail[i] == 'G' || tail[i] == 'C' {
+			gc++
+		}
+	}
+	return gc <= 3
+}
@@ -0,0 +1,294 @@
+// Package primers designs PCR primer pairs to amplify a region of a
+// sequence.
+package primers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"labdb.org/labdb/sequence"
+)
+
+// Constraints are what designed primers and their product have to satisfy.
+// Complementarity and hairpins are measured in consecutive base pairs.
+type Constraints struct {
+	MinLength int     `json:"minLength"`
+	OptLength int     `json:"optLength"`
+	MaxLength int     `json:"maxLength"`
+	MinTm     float64 `json:"minTm"`
+	OptTm     float64 `json:"optTm"`
+	MaxTm     float64 `json:"maxTm"`
+	// MaxTmDiff is the most a pair's melting temperatures may differ by.
+	MaxTmDiff float64 `json:"maxTmDiff"`
+	MinGC     float64 `json:"minGC"`
+	MaxGC     float64 `json:"maxGC"`
+	GCClamp   bool    `json:"gcClamp"`
+	// MaxComplementarity limits pairing within a primer or between the two
+	// primers of a pair, and MaxThreePrime pairing that involves a 3' end.
+	MaxComplementarity int `json:"maxComplementarity"`
+	MaxThreePrime      int `json:"maxThreePrime"`
+	MaxHairpin         int `json:"maxHairpin"`
+	MinProductSize     int `json:"minProductSize"`
+	MaxProductSize     int `json:"maxProductSize"`
+}
+
+var DefaultConstraints = Constraints{
+	MinLength:          18,
+	OptLength:          20,
+	MaxLength:          27,
+	MinTm:              57,
+	OptTm:              60,
+	MaxTm:              63,
+	MaxTmDiff:          3,
+	MinGC:              0.4,
+	MaxGC:              0.6,
+	GCClamp:            true,
+	MaxComplementarity: 8,
+	MaxThreePrime:      3,
+	MaxHairpin:         4,
+	MinProductSize:     100,
+	MaxProductSize:     1000,
+}
+
+// Limits on the constraints. Longer primers or products aren't practical,
+// and would make Design search for a long time.
+const (
+	maxPrimerLength = 60
+	maxProductSize  = 50000
+)
+
+// Validate checks that the constraints can be met at all.
+func (c Constraints) Validate() error {
+	switch {
+	case c.MinLength < sequence.MinPrimingLength || c.MaxLength < c.MinLength:
+		return fmt.Errorf("primers have to be between %d and %d bases, with at least %d", c.MinLength, c.MaxLength, sequence.MinPrimingLength)
+	case c.MaxLength > maxPrimerLength:
+		return fmt.Errorf("primers can be at most %d bases", maxPrimerLength)
+	case c.MaxProductSize > maxProductSize:
+		return fmt.Errorf("products can be at most %d bp", maxProductSize)
+	case c.MaxTm < c.MinTm:
+		return fmt.Errorf("the Tm range is empty")
+	case c.MaxGC < c.MinGC:
+		return fmt.Errorf("the GC range is empty")
+	case c.MaxProductSize < c.MinProductSize:
+		return fmt.Errorf("the product size range is empty")
+	}
+	return nil
+}
+
+// Primer is a designed primer. Start and End are the part of the template it
+// covers on the forward strand, with End past the end of a circular template
+// for primers across the origin.
+type Primer struct {
+	Sequence    string          `json:"sequence"`
+	Start       int             `json:"start"`
+	End         int             `json:"end"`
+	Strand      sequence.Strand `json:"strand"`
+	Length      int             `json:"length"`
+	MeltingTemp float64         `json:"meltingTemp"`
+	GCContent   float64         `json:"gcContent"`
+	// SelfComplementarity and ThreePrimeComplementarity are the longest runs
+	// of base pairs in a self dimer, and Hairpin the longest hairpin stem.
+	SelfComplementarity       int     `json:"selfComplementarity"`
+	ThreePrimeComplementarity int     `json:"threePrimeComplementarity"`
+	Hairpin                   int     `json:"hairpin"`
+	Penalty                   float64 `json:"penalty"`
+}
+
+// Pair is a designed pair of primers and the product they make.
+type Pair struct {
+	Forward Primer `json:"forward"`
+	Reverse Primer `json:"reverse"`
+	// ProductStart and ProductEnd are the product's extent on the template.
+	ProductStart int `json:"productStart"`
+	ProductEnd   int `json:"productEnd"`
+	ProductSize  int `json:"productSize"`
+	// Complementarity and ThreePrimeComplementarity measure primer dimers.
+	Complementarity           int     `json:"complementarity"`
+	ThreePrimeComplementarity int     `json:"threePrimeComplementarity"`
+	Penalty                   float64 `json:"penalty"`
+}
+
+// candidatesPerSide is how many of the best primers on each side are tried
+// in pairs.
+const candidatesPerSide = 100
+
+// Design finds up to count primer pairs whose product contains the region
+// start:end of template, best first. The template should be normalized; on
+// circular templates end may be past its end.
+func Design(template string, circular bool, start, end int, c Constraints, count int) ([]Pair, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	n := len(template)
+	if start < 0 || start >= n || end <= start || end-start > n || (!circular && end > n) {
+		return nil, fmt.Errorf("bad target region")
+	}
+	maxSize := c.MaxProductSize
+	if maxSize > n {
+		maxSize = n
+	}
+	if end-start > maxSize {
+		return nil, fmt.Errorf("the target is longer than the largest product allowed")
+	}
+	at := func(i int) (byte, bool) {
+		if circular {
+			return template[((i%n)+n)%n], true
+		}
+		if i < 0 || i >= n {
+			return 0, false
+		}
+		return template[i], true
+	}
+	region := func(from, to int) (string, bool) {
+		b := make([]byte, 0, to-from)
+		for i := from; i < to; i++ {
+			c, ok := at(i)
+			if !ok {
+				return "", false
+			}
+			b = append(b, c)
+		}
+		return string(b), true
+	}
+
+	forward := []Primer{}
+	reverse := []Primer{}
+	// Forward primers end before the target, reverse ones start after it,
+	// both close enough for the product to be small enough.
+	for length := c.MinLength; length <= c.MaxLength; length++ {
+		for from := end - maxSize; from+length <= start; from++ {
+			if seq, ok := region(from, from+length); ok {
+				if p, ok := candidate(seq, c); ok {
+					p.Start, p.End, p.Strand = from, from+length, sequence.Forward
+					forward = append(forward, p)
+				}
+			}
+		}
+		for from := end; from+length <= start+maxSize; from++ {
+			if seq, ok := region(from, from+length); ok {
+				if p, ok := candidate(sequence.ReverseComplement(seq), c); ok {
+					p.Start, p.End, p.Strand = from, from+length, sequence.Reverse
+					reverse = append(reverse, p)
+				}
+			}
+		}
+	}
+	forward = best(forward, template, circular)
+	reverse = best(reverse, template, circular)
+
+	pairs := []Pair{}
+	for _, f := range forward {
+		for _, r := range reverse {
+			size := r.End - f.Start
+			if size < c.MinProductSize || size > maxSize {
+				continue
+			}
+			tmDiff := math.Abs(f.MeltingTemp - r.MeltingTemp)
+			if tmDiff > c.MaxTmDiff {
+				continue
+			}
+			any, threePrime := Complementarity(f.Sequence, r.Sequence)
+			if _, rThreePrime := Complementarity(r.Sequence, f.Sequence); rThreePrime > threePrime {
+				threePrime = rThreePrime
+			}
+			if any > c.MaxComplementarity || threePrime > c.MaxThreePrime {
+				continue
+			}
+			pair := Pair{
+				Forward:                   f,
+				Reverse:                   r,
+				ProductStart:              f.Start,
+				ProductEnd:                r.End,
+				ProductSize:               size,
+				Complementarity:           any,
+				ThreePrimeComplementarity: threePrime,
+				Penalty:                   f.Penalty + r.Penalty + tmDiff,
+			}
+			pairs = append(pairs, normalizePositions(pair, n))
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].Penalty < pairs[j].Penalty })
+	if len(pairs) > count {
+		pairs = pairs[:count]
+	}
+	return pairs, nil
+}
+
+// candidate checks a primer sequence against the constraints that don't
+// depend on where it is.
+func candidate(seq string, c Constraints) (Primer, bool) {
+	for i := 0; i < len(seq); i++ {
+		if b := seq[i]; b != 'A' && b != 'C' && b != 'G' && b != 'T' {
+			return Primer{}, false
+		}
+	}
+	p := Primer{
+		Sequence:    seq,
+		Length:      len(seq),
+		MeltingTemp: sequence.MeltingTemp(seq),
+		GCContent:   sequence.GCContent(seq),
+	}
+	if p.MeltingTemp < c.MinTm || p.MeltingTemp > c.MaxTm || p.GCContent < c.MinGC || p.GCContent > c.MaxGC {
+		return p, false
+	}
+	if c.GCClamp && !HasGCClamp(seq) {
+		return p, false
+	}
+	p.SelfComplementarity, p.ThreePrimeComplementarity = Complementarity(seq, seq)
+	if p.SelfComplementarity > c.MaxComplementarity || p.ThreePrimeComplementarity > c.MaxThreePrime {
+		return p, false
+	}
+	p.Hairpin = HairpinStem(seq)
+	if p.Hairpin > c.MaxHairpin {
+		return p, false
+	}
+	p.Penalty = math.Abs(p.MeltingTemp-c.OptTm) + 0.5*math.Abs(float64(p.Length-c.OptLength))
+	return p, true
+}
+
+// best returns the primers with the lowest penalties that prime only once on
+// the template.
+func best(primers []Primer, template string, circular bool) []Primer {
+	sort.SliceStable(primers, func(i, j int) bool { return primers[i].Penalty < primers[j].Penalty })
+	kept := []Primer{}
+	for _, p := range primers {
+		if len(kept) == candidatesPerSide {
+			break
+		}
+		priming := 0
+		for _, site := range sequence.BindingSites(p.Sequence, template, circular, 0) {
+			if site.Primes() {
+				priming++
+			}
+		}
+		if priming == 1 {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// normalizePositions moves a pair designed around the origin of a circular
+// template so that positions start within the template.
+func normalizePositions(p Pair, n int) Pair {
+	shift := 0
+	for p.ProductStart+shift < 0 {
+		shift += n
+	}
+	for p.ProductStart+shift >= n {
+		shift -= n
+	}
+	p.ProductStart += shift
+	p.ProductEnd += shift
+	for _, primer := range []*Primer{&p.Forward, &p.Reverse} {
+		primer.Start += shift
+		primer.End += shift
+		if primer.Start >= n {
+			primer.Start -= n
+			primer.End -= n
+		}
+	}
+	return p
+}
@@ -0,0 +1,160 @@
+package restriction
+
+import (
+	"sort"
+
+	"labdb.org/labdb/sequence"
+)
+
+// Cut is a place an enzyme cuts a sequence. Position is where the top
+// (forward) strand is cut, as an offset into the sequence; the bottom strand
+// is cut at Position+Overhang, which for circular sequences may be past
+// either end.
+type Cut struct {
+	Enzyme   *Enzyme         `json:"-"`
+	Name     string          `json:"enzyme"`
+	Position int             `json:"position"`
+	Overhang int             `json:"overhang"`
+	Strand   sequence.Strand `json:"strand"`
+}
+
+// Cuts finds where enzyme cuts seq, which should be normalized, ordered by
+// position. On linear sequences, sites too close to the ends for both strands
+// to be cut are skipped.
+func Cuts(enzyme *Enzyme, seq string, circular bool) []Cut {
+	cuts := []Cut{}
+	n := len(seq)
+	palindromic := enzyme.Palindromic()
+	seen := map[int]bool{}
+	for _, m := range sequence.Find(enzyme.Site, seq, 0, circular) {
+		if m.Strand == sequence.Reverse && palindromic {
+			continue
+		}
+		// Find lets an N in seq match anything, but a run of Ns isn't a site.
+		if !definite(seq, m.Start, m.End) {
+			continue
+		}
+		top, bottom := m.Start+enzyme.TopCut, m.Start+enzyme.BottomCut
+		if m.Strand == sequence.Reverse {
+			top, bottom = m.End-enzyme.BottomCut, m.End-enzyme.TopCut
+		}
+		if !circular {
+			lo, hi := top, bottom
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if top <= 0 || top >= n || lo < 0 || hi > n {
+				continue
+			}
+		}
+		top = ((top % n) + n) % n
+		if seen[top] {
+			continue
+		}
+		seen[top] = true
+		cuts = append(cuts, Cut{
+			Enzyme:   enzyme,
+			Name:     enzyme.Name,
+			Position: top,
+			Overhang: enzyme.Overhang(),
+			Strand:   m.Strand,
+		})
+	}
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i].Position < cuts[j].Position })
+	return cuts
+}
+
+// definite reports whether seq[start:end], which may wrap past the end of a
+// circular sequence, is made up only of definite bases.
+func definite(seq string, start, end int) bool {
+	for i := start; i < end; i++ {
+		switch seq[i%len(seq)] {
+		case 'A', 'C', 'G', 'T', 'U':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Fragment is a piece of a digested sequence. As elsewhere, End is past the
+// end of the sequence for fragments spanning the origin of a circular one.
+// Left and Right are the cuts at each end, if any.
+type Fragment struct {
+	Start int  `json:"start"`
+	End   int  `json:"end"`
+	Size  int  `json:"size"`
+	Left  *Cut `json:"left"`
+	Right *Cut `json:"right"`
+}
+
+// Digest predicts the fragments from cutting seq with all of the given
+// enzymes, in order along the sequence. An uncut circular sequence gives a
+// single fragment with no ends.
+func Digest(enzymes []*Enzyme, seq string, circular bool) []Fragment {
+	cuts := []Cut{}
+	for _, e := range enzymes {
+		cuts = append(cuts, Cuts(e, seq, circular)...)
+	}
+	sort.SliceStable(cuts, func(i, j int) bool { return cuts[i].Position < cuts[j].Position })
+	// Two enzymes cutting in the same place only make one cut.
+	distinct := cuts[:0]
+	for i, c := range cuts {
+		if i == 0 || c.Position != cuts[i-1].Position {
+			distinct = append(distinct, c)
+		}
+	}
+	cuts = distinct
+	n := len(seq)
+	fragments := []Fragment{}
+	if circular {
+		if len(cuts) == 0 {
+			return append(fragments, Fragment{Start: 0, End: n, Size: n})
+		}
+		for i := range cuts {
+			next := (i + 1) % len(cuts)
+			end := cuts[next].Position
+			if end <= cuts[i].Position {
+				end += n
+			}
+			fragments = append(fragments, Fragment{
+				Start: cuts[i].Position,
+				End:   end,
+				Size:  end - cuts[i].Position,
+				Left:  &cuts[i],
+				Right: &cuts[next],
+			})
+		}
+		return fragments
+	}
+	start := 0
+	var left *Cut
+	for i := range cuts {
+		fragments = append(fragments, Fragment{Start: start, End: cuts[i].Position, Size: cuts[i].Position - start, Left: left, Right: &cuts[i]})
+		start = cuts[i].Position
+		left = &cuts[i]
+	}
+	return append(fragments, Fragment{Start: start, End: n, Size: n - start, Left: left})
+}
+
+// Sizes returns the sizes of fragments, largest first, as they'd run on a
+// gel.
+func Sizes(fragments []Fragment) []int {
+	sizes := []int{}
+	for _, f := range fragments {
+		sizes = append(sizes, f.Size)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+	return sizes
+}
+
+// UniqueCutters returns the enzymes that cut seq exactly once.
+func UniqueCutters(enzymes []Enzyme, seq string, circular bool) []*Enzyme {
+	unique := []*Enzyme{}
+	for i := range enzymes {
+		if len(Cuts(&enzymes[i], seq, circular)) == 1 {
+			unique = append(unique, &enzymes[i])
+		}
+	}
+	return unique
+}
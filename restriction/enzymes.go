@@ -0,0 +1,120 @@
+// Package restriction finds restriction enzyme sites and predicts the
+// fragments of digests.
+package restriction
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"labdb.org/labdb/sequence"
+)
+
+// Enzyme is a restriction enzyme. Cuts are counted in bases from the start
+// of the recognition site (on the strand it reads) to where each strand is
+// cut, so for G^AATTC TopCut is 1 and BottomCut 5, and for GGTCTC(1/5)
+// they're 7 and 11.
+type Enzyme struct {
+	Name      string `json:"name"`
+	Site      string `json:"site"`
+	TopCut    int    `json:"topCut"`
+	BottomCut int    `json:"bottomCut"`
+}
+
+// Overhang is the length of the single stranded end the enzyme leaves:
+// positive for 5' overhangs, negative for 3' ones and zero for blunt ends.
+func (e *Enzyme) Overhang() int {
+	return e.BottomCut - e.TopCut
+}
+
+// Palindromic reports whether the site reads the same on both strands.
+func (e *Enzyme) Palindromic() bool {
+	return e.Site == sequence.ReverseComplement(e.Site)
+}
+
+// ParseEnzyme parses an enzyme in REBASE notation: a name and a site, with
+// either a ^ at the cut in palindromic sites (EcoRI G^AATTC) or the cuts
+// after the site in brackets (BsaI GGTCTC(1/5)).
+func ParseEnzyme(line string) (Enzyme, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 {
+		return Enzyme{}, fmt.Errorf("expected a name and a site in %q", line)
+	}
+	e := Enzyme{Name: fields[0]}
+	site := strings.ToUpper(fields[1])
+	if i := strings.IndexByte(site, '('); i >= 0 {
+		if !strings.HasSuffix(site, ")") {
+			return Enzyme{}, fmt.Errorf("bad site %q", fields[1])
+		}
+		cuts := strings.Split(site[i+1:len(site)-1], "/")
+		if len(cuts) != 2 {
+			return Enzyme{}, fmt.Errorf("bad cuts in %q", fields[1])
+		}
+		top, err1 := strconv.Atoi(cuts[0])
+		bottom, err2 := strconv.Atoi(cuts[1])
+		if err1 != nil || err2 != nil {
+			return Enzyme{}, fmt.Errorf("bad cuts in %q", fields[1])
+		}
+		e.Site = site[:i]
+		e.TopCut = len(e.Site) + top
+		e.BottomCut = len(e.Site) + bottom
+	} else {
+		cut := strings.IndexByte(site, '^')
+		if cut < 0 {
+			return Enzyme{}, fmt.Errorf("no cut in %q", fields[1])
+		}
+		e.Site = site[:cut] + site[cut+1:]
+		e.TopCut = cut
+		e.BottomCut = len(e.Site) - cut
+	}
+	if e.Site == "" || !sequence.IsDNA(e.Site) {
+		return Enzyme{}, fmt.Errorf("bad site %q", fields[1])
+	}
+	return e, nil
+}
+
+// ParseEnzymes reads a list of enzymes, one per line. Blank lines and lines
+// starting with # are skipped.
+func ParseEnzymes(r io.Reader) ([]Enzyme, error) {
+	enzymes := []Enzyme{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		e, err := ParseEnzyme(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		enzymes = append(enzymes, e)
+	}
+	return enzymes, scanner.Err()
+}
+
+// Enzymes are the bundled enzymes, sorted by name.
+var Enzymes []Enzyme
+
+func init() {
+	var err error
+	Enzymes, err = ParseEnzymes(strings.NewReader(rebase))
+	if err != nil {
+		panic(err)
+	}
+	sort.Slice(Enzymes, func(i, j int) bool { return Enzymes[i].Name < Enzymes[j].Name })
+}
+
+// Lookup finds a bundled enzyme by name, ignoring case.
+func Lookup(name string) (*Enzyme, bool) {
+	for i := range Enzymes {
+		if strings.EqualFold(Enzymes[i].Name, name) {
+			return &Enzymes[i], true
+		}
+	}
+	return nil, false
+}
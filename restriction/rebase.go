@@ -0,0 +1,62 @@
+package restriction
+
+// rebase lists the bundled enzymes in REBASE notation (see ParseEnzyme):
+// commercially available enzymes in common use for cloning.
+const rebase = `
+AatII GACGT^C
+AccI GT^MKAC
+AflII C^TTAAG
+AgeI A^CCGGT
+ApaI GGGCC^C
+ApaLI G^TGCAC
+AscI GG^CGCGCC
+AseI AT^TAAT
+AvaI C^YCGRG
+AvrII C^CTAGG
+BamHI G^GATCC
+BbsI GAAGAC(2/6)
+BclI T^GATCA
+BglII A^GATCT
+BsaI GGTCTC(1/5)
+BsmBI CGTCTC(1/5)
+BspEI T^CCGGA
+BsrGI T^GTACA
+BstBI TT^CGAA
+BstEII G^GTNACC
+ClaI AT^CGAT
+DraI TTT^AAA
+EagI C^GGCCG
+EcoRI G^AATTC
+EcoRV GAT^ATC
+FseI GGCCGG^CC
+HindIII A^AGCTT
+HpaI GTT^AAC
+KpnI GGTAC^C
+MfeI C^AATTG
+MluI A^CGCGT
+NcoI C^CATGG
+NdeI CA^TATG
+NheI G^CTAGC
+NotI GC^GGCCGC
+NruI TCG^CGA
+NsiI ATGCA^T
+PacI TTAAT^TAA
+PmeI GTTT^AAAC
+PstI CTGCA^G
+PvuI CGAT^CG
+PvuII CAG^CTG
+SacI GAGCT^C
+SacII CCGC^GG
+SalI G^TCGAC
+SapI GCTCTTC(1/4)
+ScaI AGT^ACT
+SmaI CCC^GGG
+SpeI A^CTAGT
+SphI GCATG^C
+SrfI GCCC^GGGC
+StuI AGG^CCT
+SwaI ATTT^AAAT
+XbaI T^CTAGA
+XhoI C^TCGAG
+XmaI C^CCGGG
+`
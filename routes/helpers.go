@@ -8,19 +8,27 @@ import (
 )
 
 // /:model/:id/next
-func nextRoute(cls string) gin.HandlerFunc {
+func nextRoute(s *models.Store, cls string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
-		redirectID := models.NextID(cls, id)
+		redirectID, err := s.NextID(c.Request.Context(), cls, id)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
 		c.Redirect(307, fmt.Sprintf("/%s/%s", cls, redirectID))
 	}
 }
 
 // /:model/:id/previous
-func previousRoute(cls string) gin.HandlerFunc {
+func previousRoute(s *models.Store, cls string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
-		redirectID := models.PrevID(cls, id)
+		redirectID, err := s.PrevID(c.Request.Context(), cls, id)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
 		c.Redirect(307, fmt.Sprintf("/%s/%s", cls, redirectID))
 	}
 }
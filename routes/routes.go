@@ -1,107 +1,110 @@
 package routes
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/gin-gonic/gin"
+	"labdb.org/labdb/models"
+)
 
-func InstallAll(r *gin.Engine) {
-	installplasmid(r)
-	installplasmids(r)
-	installoligo(r)
-	installoligos(r)
-	installline(r)
-	installlines(r)
-	installsample(r)
-	installsamples(r)
-	installbacterium(r)
-	installbacteria(r)
-	installyeaststrain(r)
-	installyeaststrains(r)
-	installuser(r)
-	installusers(r)
-	installantibody(r)
-	installantibodies(r)
-	installrnai_clone(r)
-	installrnai_clones(r)
-	installseq_lib(r)
-	installseq_libs(r)
+func InstallAll(r *gin.Engine, s *models.Store) {
+	installplasmid(r, s)
+	installplasmids(r, s)
+	installoligo(r, s)
+	installoligos(r, s)
+	installline(r, s)
+	installlines(r, s)
+	installsample(r, s)
+	installsamples(r, s)
+	installbacterium(r, s)
+	installbacteria(r, s)
+	installyeaststrain(r, s)
+	installyeaststrains(r, s)
+	installuser(r, s)
+	installusers(r, s)
+	installantibody(r, s)
+	installantibodies(r, s)
+	installrnai_clone(r, s)
+	installrnai_clones(r, s)
+	installseq_lib(r, s)
+	installseq_libs(r, s)
 
 }
-func installplasmid(r *gin.Engine) {
-	r.GET("/plasmid/:id/next", nextRoute("plasmid"))
-	r.GET("/plasmid/:id/previous", previousRoute("plasmid"))
+func installplasmid(r *gin.Engine, s *models.Store) {
+	r.GET("/plasmid/:id/next", nextRoute(s, "plasmid"))
+	r.GET("/plasmid/:id/previous", previousRoute(s, "plasmid"))
 }
-func installplasmids(r *gin.Engine) {
-	r.GET("/plasmids/:id/next", nextRoute("plasmids"))
-	r.GET("/plasmids/:id/previous", previousRoute("plasmids"))
+func installplasmids(r *gin.Engine, s *models.Store) {
+	r.GET("/plasmids/:id/next", nextRoute(s, "plasmids"))
+	r.GET("/plasmids/:id/previous", previousRoute(s, "plasmids"))
 }
-func installoligo(r *gin.Engine) {
-	r.GET("/oligo/:id/next", nextRoute("oligo"))
-	r.GET("/oligo/:id/previous", previousRoute("oligo"))
+func installoligo(r *gin.Engine, s *models.Store) {
+	r.GET("/oligo/:id/next", nextRoute(s, "oligo"))
+	r.GET("/oligo/:id/previous", previousRoute(s, "oligo"))
 }
-func installoligos(r *gin.Engine) {
-	r.GET("/oligos/:id/next", nextRoute("oligos"))
-	r.GET("/oligos/:id/previous", previousRoute("oligos"))
+func installoligos(r *gin.Engine, s *models.Store) {
+	r.GET("/oligos/:id/next", nextRoute(s, "oligos"))
+	r.GET("/oligos/:id/previous", previousRoute(s, "oligos"))
 }
-func installline(r *gin.Engine) {
-	r.GET("/line/:id/next", nextRoute("line"))
-	r.GET("/line/:id/previous", previousRoute("line"))
+func installline(r *gin.Engine, s *models.Store) {
+	r.GET("/line/:id/next", nextRoute(s, "line"))
+	r.GET("/line/:id/previous", previousRoute(s, "line"))
 }
-func installlines(r *gin.Engine) {
-	r.GET("/lines/:id/next", nextRoute("lines"))
-	r.GET("/lines/:id/previous", previousRoute("lines"))
+func installlines(r *gin.Engine, s *models.Store) {
+	r.GET("/lines/:id/next", nextRoute(s, "lines"))
+	r.GET("/lines/:id/previous", previousRoute(s, "lines"))
 }
-func installsample(r *gin.Engine) {
-	r.GET("/sample/:id/next", nextRoute("sample"))
-	r.GET("/sample/:id/previous", previousRoute("sample"))
+func installsample(r *gin.Engine, s *models.Store) {
+	r.GET("/sample/:id/next", nextRoute(s, "sample"))
+	r.GET("/sample/:id/previous", previousRoute(s, "sample"))
 }
-func installsamples(r *gin.Engine) {
-	r.GET("/samples/:id/next", nextRoute("samples"))
-	r.GET("/samples/:id/previous", previousRoute("samples"))
+func installsamples(r *gin.Engine, s *models.Store) {
+	r.GET("/samples/:id/next", nextRoute(s, "samples"))
+	r.GET("/samples/:id/previous", previousRoute(s, "samples"))
 }
-func installbacterium(r *gin.Engine) {
-	r.GET("/bacterium/:id/next", nextRoute("bacterium"))
-	r.GET("/bacterium/:id/previous", previousRoute("bacterium"))
+func installbacterium(r *gin.Engine, s *models.Store) {
+	r.GET("/bacterium/:id/next", nextRoute(s, "bacterium"))
+	r.GET("/bacterium/:id/previous", previousRoute(s, "bacterium"))
 }
-func installbacteria(r *gin.Engine) {
-	r.GET("/bacteria/:id/next", nextRoute("bacteria"))
-	r.GET("/bacteria/:id/previous", previousRoute("bacteria"))
+func installbacteria(r *gin.Engine, s *models.Store) {
+	r.GET("/bacteria/:id/next", nextRoute(s, "bacteria"))
+	r.GET("/bacteria/:id/previous", previousRoute(s, "bacteria"))
 }
-func installyeaststrain(r *gin.Engine) {
-	r.GET("/yeaststrain/:id/next", nextRoute("yeaststrain"))
-	r.GET("/yeaststrain/:id/previous", previousRoute("yeaststrain"))
+func installyeaststrain(r *gin.Engine, s *models.Store) {
+	r.GET("/yeaststrain/:id/next", nextRoute(s, "yeaststrain"))
+	r.GET("/yeaststrain/:id/previous", previousRoute(s, "yeaststrain"))
 }
-func installyeaststrains(r *gin.Engine) {
-	r.GET("/yeaststrains/:id/next", nextRoute("yeaststrains"))
-	r.GET("/yeaststrains/:id/previous", previousRoute("yeaststrains"))
+func installyeaststrains(r *gin.Engine, s *models.Store) {
+	r.GET("/yeaststrains/:id/next", nextRoute(s, "yeaststrains"))
+	r.GET("/yeaststrains/:id/previous", previousRoute(s, "yeaststrains"))
 }
-func installuser(r *gin.Engine) {
-	r.GET("/user/:id/next", nextRoute("user"))
-	r.GET("/user/:id/previous", previousRoute("user"))
+func installuser(r *gin.Engine, s *models.Store) {
+	r.GET("/user/:id/next", nextRoute(s, "user"))
+	r.GET("/user/:id/previous", previousRoute(s, "user"))
 }
-func installusers(r *gin.Engine) {
-	r.GET("/users/:id/next", nextRoute("users"))
-	r.GET("/users/:id/previous", previousRoute("users"))
+func installusers(r *gin.Engine, s *models.Store) {
+	r.GET("/users/:id/next", nextRoute(s, "users"))
+	r.GET("/users/:id/previous", previousRoute(s, "users"))
 }
-func installantibody(r *gin.Engine) {
-	r.GET("/antibody/:id/next", nextRoute("antibody"))
-	r.GET("/antibody/:id/previous", previousRoute("antibody"))
+func installantibody(r *gin.Engine, s *models.Store) {
+	r.GET("/antibody/:id/next", nextRoute(s, "antibody"))
+	r.GET("/antibody/:id/previous", previousRoute(s, "antibody"))
 }
-func installantibodies(r *gin.Engine) {
-	r.GET("/antibodies/:id/next", nextRoute("antibodies"))
-	r.GET("/antibodies/:id/previous", previousRoute("antibodies"))
+func installantibodies(r *gin.Engine, s *models.Store) {
+	r.GET("/antibodies/:id/next", nextRoute(s, "antibodies"))
+	r.GET("/antibodies/:id/previous", previousRoute(s, "antibodies"))
 }
-func installrnai_clone(r *gin.Engine) {
-	r.GET("/rnai_clone/:id/next", nextRoute("rnai_clone"))
-	r.GET("/rnai_clone/:id/previous", previousRoute("rnai_clone"))
+func installrnai_clone(r *gin.Engine, s *models.Store) {
+	r.GET("/rnai_clone/:id/next", nextRoute(s, "rnai_clone"))
+	r.GET("/rnai_clone/:id/previous", previousRoute(s, "rnai_clone"))
 }
-func installrnai_clones(r *gin.Engine) {
-	r.GET("/rnai_clones/:id/next", nextRoute("rnai_clones"))
-	r.GET("/rnai_clones/:id/previous", previousRoute("rnai_clones"))
+func installrnai_clones(r *gin.Engine, s *models.Store) {
+	r.GET("/rnai_clones/:id/next", nextRoute(s, "rnai_clones"))
+	r.GET("/rnai_clones/:id/previous", previousRoute(s, "rnai_clones"))
 }
-func installseq_lib(r *gin.Engine) {
-	r.GET("/seq_lib/:id/next", nextRoute("seq_lib"))
-	r.GET("/seq_lib/:id/previous", previousRoute("seq_lib"))
+func installseq_lib(r *gin.Engine, s *models.Store) {
+	r.GET("/seq_lib/:id/next", nextRoute(s, "seq_lib"))
+	r.GET("/seq_lib/:id/previous", previousRoute(s, "seq_lib"))
 }
-func installseq_libs(r *gin.Engine) {
-	r.GET("/seq_libs/:id/next", nextRoute("seq_libs"))
-	r.GET("/seq_libs/:id/previous", previousRoute("seq_libs"))
+func installseq_libs(r *gin.Engine, s *models.Store) {
+	r.GET("/seq_libs/:id/next", nextRoute(s, "seq_libs"))
+	r.GET("/seq_libs/:id/previous", previousRoute(s, "seq_libs"))
 }
@@ -0,0 +1,283 @@
+// Package sanger checks Sanger sequencing reads against the sequence they're
+// expected to match.
+package sanger
+
+import (
+	"labdb.org/labdb/align"
+	"labdb.org/labdb/formats"
+	"labdb.org/labdb/sequence"
+)
+
+// MinQuality is the Phred score the ends of reads are trimmed back to.
+const MinQuality = 20
+
+// trimWindow is how many bases are averaged when trimming.
+const trimWindow = 10
+
+// minAlignedScore is the lowest alignment score that counts as a read
+// matching the reference, about 30 bases' worth.
+const minAlignedScore = 60
+
+// Difference is a place a read disagrees with the reference.
+type Difference struct {
+	Read string `json:"read"`
+	// Position is where on the reference the difference is. Insertions come
+	// before this base.
+	Position int `json:"position"`
+	// Type is "mismatch", "insertion" or "deletion".
+	Type string `json:"type"`
+	Ref  string `json:"ref"`
+	Base string `json:"base"`
+	// Quality is the lowest quality of the read bases involved (for deletions,
+	// those either side), or nil for reads without qualities.
+	Quality *int `json:"quality"`
+}
+
+// ReadResult is how a single read aligned. Reads on the reverse strand are
+// reported as reverse complemented, so their bases read along the reference.
+type ReadResult struct {
+	Name string `json:"name"`
+	// TrimStart and TrimEnd are the part of the read left after trimming low
+	// quality ends.
+	TrimStart int  `json:"trimStart"`
+	TrimEnd   int  `json:"trimEnd"`
+	Aligned   bool `json:"aligned"`
+	// Start and End are the part of the reference the read covers. As
+	// elsewhere, End may be past the end of a circular reference.
+	Start       int             `json:"start"`
+	End         int             `json:"end"`
+	Strand      sequence.Strand `json:"strand"`
+	Identity    float64         `json:"identity"`
+	Differences []Difference    `json:"differences"`
+
+	matches int
+	columns int
+}
+
+// Thresholds are what reads have to achieve for a sequence to be verified.
+type Thresholds struct {
+	MinIdentity float64 `json:"minIdentity"`
+	MinCoverage float64 `json:"minCoverage"`
+}
+
+var DefaultThresholds = Thresholds{MinIdentity: 0.99, MinCoverage: 1}
+
+// Report is the result of checking reads against a reference.
+type Report struct {
+	// Start and End are the region of the reference that was checked.
+	Start int          `json:"start"`
+	End   int          `json:"end"`
+	Reads []ReadResult `json:"reads"`
+	// Coverage is the fraction of the region covered by at least one read,
+	// and Identity the fraction of aligned positions in it that agree.
+	Coverage   float64    `json:"coverage"`
+	Identity   float64    `json:"identity"`
+	Thresholds Thresholds `json:"thresholds"`
+	Passed     bool       `json:"passed"`
+}
+
+// Verify aligns reads to the region start:end of reference, which should be
+// normalized, and reports where they differ from it. On circular references
+// end may be past the end of the sequence.
+func Verify(reference string, circular bool, start, end int, reads []formats.Read, t Thresholds) *Report {
+	report := &Report{Start: start, End: end, Reads: []ReadResult{}, Thresholds: t}
+	covered := make([]bool, end-start)
+	matches, columns := 0, 0
+	for _, r := range reads {
+		result := check(reference, circular, start, end, r, covered)
+		matches += result.matches
+		columns += result.columns
+		report.Reads = append(report.Reads, result)
+	}
+	n := 0
+	for _, c := range covered {
+		if c {
+			n++
+		}
+	}
+	if len(covered) > 0 {
+		report.Coverage = float64(n) / float64(len(covered))
+	}
+	if columns > 0 {
+		report.Identity = float64(matches) / float64(columns)
+	}
+	report.Passed = columns > 0 && report.Coverage >= t.MinCoverage && report.Identity >= t.MinIdentity
+	return report
+}
+
+// trim finds the part of a read to keep, cutting each end back to where the
+// average quality reaches MinQuality.
+func trim(quality []int, n int) (int, int) {
+	if quality == nil {
+		return 0, n
+	}
+	window := trimWindow
+	if n < window {
+		window = n
+	}
+	good := func(i int) bool {
+		sum := 0
+		for _, q := range quality[i : i+window] {
+			sum += q
+		}
+		return sum >= MinQuality*window
+	}
+	start := 0
+	for start+window <= n && !good(start) {
+		start++
+	}
+	if start+window > n {
+		return 0, 0
+	}
+	end := n
+	for !good(end - window) {
+		end--
+	}
+	return start, end
+}
+
+// check aligns a single read, marking the positions in the region it covers.
+func check(reference string, circular bool, start, end int, r formats.Read, covered []bool) ReadResult {
+	result := ReadResult{Name: r.Name, Differences: []Difference{}}
+	result.TrimStart, result.TrimEnd = trim(r.Quality, len(r.Sequence))
+	read := sequence.Normalize(r.Sequence[result.TrimStart:result.TrimEnd])
+	var quality []int
+	if r.Quality != nil {
+		quality = r.Quality[result.TrimStart:result.TrimEnd]
+	}
+	if read == "" {
+		return result
+	}
+
+	n := len(reference)
+	target := reference
+	if circular {
+		// Let reads run across the origin.
+		extra := len(read)
+		if extra > n {
+			extra = n
+		}
+		target += reference[:extra]
+	}
+	aln := align.Local(target, read, align.DefaultScoring)
+	result.Strand = sequence.Forward
+	rc := sequence.ReverseComplement(read)
+	if revAln := align.Local(target, rc, align.DefaultScoring); revAln.Score > aln.Score {
+		aln = revAln
+		result.Strand = sequence.Reverse
+		if quality != nil {
+			reversed := make([]int, len(quality))
+			for i, q := range quality {
+				reversed[len(quality)-1-i] = q
+			}
+			quality = reversed
+		}
+	}
+	if aln.Score < minAlignedScore {
+		return result
+	}
+	result.Aligned = true
+	result.Start, result.End = aln.AStart, aln.AEnd
+	if result.Start >= n {
+		result.Start -= n
+		result.End -= n
+	}
+
+	// regionIndex returns the index of a reference position in the region,
+	// or -1 if it's outside.
+	regionIndex := func(pos int) int {
+		i := pos - start
+		if circular {
+			i = ((pos % n) - start + n) % n
+		}
+		if i < 0 || i >= len(covered) {
+			return -1
+		}
+		return i
+	}
+	qualityOf := func(from, to int) *int {
+		if quality == nil {
+			return nil
+		}
+		if from < 0 {
+			from = 0
+		}
+		if to > len(quality) {
+			to = len(quality)
+		}
+		if from >= to {
+			return nil
+		}
+		min := quality[from]
+		for _, q := range quality[from:to] {
+			if q < min {
+				min = q
+			}
+		}
+		return &min
+	}
+
+	refPos, readPos := aln.AStart, aln.BStart
+	var last *Difference
+	for i := 0; i < len(aln.A); i++ {
+		a, b := aln.A[i], aln.B[i]
+		idx := regionIndex(refPos)
+		if a != '-' && idx >= 0 {
+			covered[idx] = true
+		}
+		kind := ""
+		switch {
+		case a == '-':
+			kind = "insertion"
+		case b == '-':
+			kind = "deletion"
+		case !sequence.Compatible(a, b):
+			kind = "mismatch"
+		case a == b:
+			if idx >= 0 {
+				result.matches++
+				result.columns++
+			}
+		}
+		if kind != "" && idx >= 0 {
+			result.columns++
+			pos := refPos % n
+			// Runs of gaps are reported as one difference.
+			if kind != "mismatch" && last != nil && last.Type == kind && ((kind == "insertion" && last.Position == pos) || (kind == "deletion" && (last.Position+len(last.Ref))%n == pos)) {
+				if kind == "insertion" {
+					last.Base += string(b)
+					last.Quality = qualityOf(readPos-len(last.Base)+1, readPos+1)
+				} else {
+					last.Ref += string(a)
+				}
+			} else {
+				d := Difference{Read: r.Name, Position: pos, Type: kind}
+				switch kind {
+				case "mismatch":
+					d.Ref, d.Base = string(a), string(b)
+					d.Quality = qualityOf(readPos, readPos+1)
+				case "insertion":
+					d.Base = string(b)
+					d.Quality = qualityOf(readPos, readPos+1)
+				case "deletion":
+					d.Ref = string(a)
+					d.Quality = qualityOf(readPos-1, readPos+1)
+				}
+				result.Differences = append(result.Differences, d)
+				last = &result.Differences[len(result.Differences)-1]
+			}
+		} else if kind == "" {
+			last = nil
+		}
+		if a != '-' {
+			refPos++
+		}
+		if b != '-' {
+			readPos++
+		}
+	}
+	if result.columns > 0 {
+		result.Identity = float64(result.matches) / float64(result.columns)
+	}
+	return result
+}
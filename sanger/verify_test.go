@@ -0,0 +1,169 @@
+package sanger
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"labdb.org/labdb/formats"
+	"labdb.org/labdb/sequence"
+)
+
+func TestTrim(t *testing.T) {
+	qualities := func(parts ...int) []int {
+		q := []int{}
+		for i := 0; i < len(parts); i += 2 {
+			for j := 0; j < parts[i]; j++ {
+				q = append(q, parts[i+1])
+			}
+		}
+		return q
+	}
+	tests := []struct {
+		name       string
+		quality    []int
+		n          int
+		start, end int
+	}{
+		{"no qualities", nil, 50, 0, 50},
+		{"all good", qualities(50, 40), 50, 0, 50},
+		// Windows are kept once at most 5 of their 10 bases are poor.
+		{"poor ends", qualities(15, 5, 70, 40, 15, 5), 100, 10, 90},
+		{"all poor", qualities(50, 5), 50, 0, 0},
+		{"short", qualities(4, 30), 4, 0, 4},
+		{"short and poor", qualities(4, 10), 4, 0, 0},
+	}
+	for _, test := range tests {
+		if start, end := trim(test.quality, test.n); start != test.start || end != test.end {
+			t.Errorf("%s: trimmed to %d-%d, want %d-%d", test.name, start, end, test.start, test.end)
+		}
+	}
+}
+
+func randomSeq(r *rand.Rand, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = "ACGT"[r.Intn(4)]
+	}
+	return string(b)
+}
+
+// changed returns seq with the base at i replaced by a different one.
+func changed(seq string, i int) string {
+	b := []byte(seq)
+	b[i] = map[byte]byte{'A': 'C', 'C': 'G', 'G': 'T', 'T': 'A'}[b[i]]
+	return string(b)
+}
+
+func quality(n, q int) []int {
+	qs := make([]int, n)
+	for i := range qs {
+		qs[i] = q
+	}
+	return qs
+}
+
+func TestCheck(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	// The patch at 197 leaves only one place for the insertion and deletion.
+	ref := randomSeq(r, 197) + "AACCGG" + randomSeq(r, 100)
+	n := len(ref)
+	q := func(v int) *int { return &v }
+
+	reverseRead := sequence.ReverseComplement(changed(ref[40:120], 60))
+	reverseQuality := quality(80, 40)
+	// The base read at 100, counting from the other end.
+	reverseQuality[19] = 25
+
+	tests := []struct {
+		name        string
+		read        formats.Read
+		start, end  int
+		strand      sequence.Strand
+		differences []Difference
+	}{
+		{
+			name:  "mismatch",
+			read:  formats.Read{Sequence: changed(ref[20:140], 60), Quality: quality(120, 40)},
+			start: 20, end: 140, strand: sequence.Forward,
+			differences: []Difference{{Position: 80, Type: "mismatch", Ref: ref[80:81], Base: changed(ref, 80)[80:81], Quality: q(40)}},
+		},
+		{
+			name:  "insertion",
+			read:  formats.Read{Sequence: ref[140:201] + "TT" + ref[201:260]},
+			start: 140, end: 260, strand: sequence.Forward,
+			differences: []Difference{{Position: 201, Type: "insertion", Base: "TT"}},
+		},
+		{
+			name:  "deletion",
+			read:  formats.Read{Sequence: ref[140:199] + ref[201:260], Quality: quality(118, 40)},
+			start: 140, end: 260, strand: sequence.Forward,
+			differences: []Difference{{Position: 199, Type: "deletion", Ref: "CC", Quality: q(40)}},
+		},
+		{
+			name:  "reverse strand",
+			read:  formats.Read{Sequence: reverseRead, Quality: reverseQuality},
+			start: 40, end: 120, strand: sequence.Reverse,
+			differences: []Difference{{Position: 100, Type: "mismatch", Ref: ref[100:101], Base: changed(ref, 100)[100:101], Quality: q(25)}},
+		},
+		{
+			name:  "across the origin",
+			read:  formats.Read{Sequence: ref[n-40:] + changed(ref[:50], 10)},
+			start: n - 40, end: n + 50, strand: sequence.Forward,
+			differences: []Difference{{Position: 10, Type: "mismatch", Ref: ref[10:11], Base: changed(ref, 10)[10:11]}},
+		},
+	}
+	for _, test := range tests {
+		covered := make([]bool, n)
+		result := check(ref, true, 0, n, test.read, covered)
+		if !result.Aligned {
+			t.Errorf("%s: not aligned", test.name)
+			continue
+		}
+		if result.Start != test.start || result.End != test.end || result.Strand != test.strand {
+			t.Errorf("%s: aligned to %d-%d on %v, want %d-%d on %v", test.name, result.Start, result.End, result.Strand, test.start, test.end, test.strand)
+		}
+		if !reflect.DeepEqual(result.Differences, test.differences) {
+			t.Errorf("%s: differences %+v, want %+v", test.name, result.Differences, test.differences)
+		}
+		for i := test.start; i < test.end; i++ {
+			if !covered[i%n] {
+				t.Errorf("%s: %d not covered", test.name, i%n)
+				break
+			}
+		}
+	}
+
+	// Linear references don't wrap.
+	read := formats.Read{Sequence: ref[n-40:] + ref[:50]}
+	if result := check(ref, false, 0, n, read, make([]bool, n)); result.Aligned && result.End > n {
+		t.Errorf("aligned past the end of a linear reference, to %d-%d", result.Start, result.End)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	ref := randomSeq(r, 300)
+	reads := []formats.Read{
+		{Name: "a", Sequence: ref[250:] + ref[:60]},
+		{Name: "b", Sequence: sequence.ReverseComplement(ref[40:160])},
+		{Name: "junk", Sequence: randomSeq(r, 100)},
+	}
+	// The region across the origin, from 240 to 150.
+	report := Verify(ref, true, 240, 450, reads, DefaultThresholds)
+	if report.Reads[2].Aligned {
+		t.Error("junk aligned")
+	}
+	// 250 to 160 is covered, so 10 of 210 bases aren't.
+	if want := 200.0 / 210; report.Coverage != want {
+		t.Errorf("Coverage = %v, want %v", report.Coverage, want)
+	}
+	if report.Identity != 1 || report.Passed {
+		t.Errorf("Identity %v, passed %v", report.Identity, report.Passed)
+	}
+
+	reads[0].Sequence = ref[230:] + ref[:60]
+	if report := Verify(ref, true, 240, 450, reads, DefaultThresholds); !report.Passed {
+		t.Errorf("failed with coverage %v and identity %v", report.Coverage, report.Identity)
+	}
+}
@@ -0,0 +1,262 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"labdb.org/labdb/models"
+	"labdb.org/labdb/search/seqindex"
+	"labdb.org/labdb/sequence"
+)
+
+// AllTypes are the types a query searches when it doesn't name any.
+var AllTypes = []string{
+	"plasmid",
+	"oligo",
+	"line",
+	"sample",
+	"bacterium",
+	"yeaststrain",
+	"antibody",
+	"rnai_clone",
+	"seq_lib",
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// valueMatcher translates a query value to a Matcher. Words and phrases match
+// the whole field if anchored is set, and anywhere within it otherwise.
+func valueMatcher(v Value, anchored bool) (Matcher, error) {
+	var pattern string
+	switch v.Kind {
+	case Regex:
+		if _, err := regexp.Compile(v.Text); err != nil {
+			return Matcher{}, &ParseError{Pos: v.Pos, Msg: err.Error()}
+		}
+		if v.CaseInsensitive {
+			return Matcher{op: "~*", arg: v.Text}, nil
+		}
+		return Matcher{op: "~", arg: v.Text}, nil
+	case Phrase:
+		pattern = likeEscaper.Replace(v.Text)
+	default:
+		pattern = globToLike(v.Text)
+	}
+	if !anchored {
+		pattern = "%" + pattern + "%"
+	}
+	return Matcher{op: "ILIKE", arg: pattern}, nil
+}
+
+// condition is a compiled term: a SQL fragment and its arguments.
+type condition struct {
+	sql  string
+	args []interface{}
+}
+
+// compileTerm compiles t against the columns of obj. ok is false if obj
+// doesn't have the columns t refers to.
+func compileTerm(t Term, obj models.Entity) (cond condition, ok bool, err error) {
+	if isDateField(t.Field) {
+		column := "created_at"
+		if t.Field == FieldUpdated {
+			column = "updated_at"
+		}
+		next := t.Date.AddDate(0, 0, 1)
+		switch t.Op {
+		case ">":
+			return condition{column + " >= ?", []interface{}{next}}, true, nil
+		case ">=":
+			return condition{column + " >= ?", []interface{}{t.Date}}, true, nil
+		case "<":
+			return condition{column + " < ?", []interface{}{t.Date}}, true, nil
+		case "<=":
+			return condition{column + " < ?", []interface{}{next}}, true, nil
+		default:
+			return condition{column + " >= ? AND " + column + " < ?", []interface{}{t.Date, next}}, true, nil
+		}
+	}
+
+	anchored := false
+	columns := []string{}
+	switch t.Field {
+	case FieldOwner:
+		anchored = true
+		columns = append(columns, obj.OwnerFieldName())
+	case FieldSeq:
+		columns = append(columns, obj.SequenceFieldName())
+	case FieldName:
+		columns = append(columns, obj.ShortDescFieldName())
+	case FieldDesc:
+		columns = append(columns, obj.DescFieldName())
+	default:
+		columns = append(columns, obj.ShortDescFieldName(), obj.DescFieldName())
+	}
+
+	m, err := valueMatcher(t.Value, anchored)
+	if err != nil {
+		return condition{}, false, err
+	}
+	parts := []string{}
+	for _, col := range columns {
+		if col == "" {
+			continue
+		}
+		sql, arg := m.Condition(col)
+		parts = append(parts, sql)
+		cond.args = append(cond.args, arg)
+	}
+	if len(parts) == 0 {
+		return condition{}, false, nil
+	}
+	cond.sql = strings.Join(parts, " OR ")
+	return cond, true, nil
+}
+
+// queryTypes works out which types q searches, defaulting to defaultTypes.
+func queryTypes(q *Query, defaultTypes []string) ([]string, error) {
+	included := []string{}
+	excluded := map[string]bool{}
+	for _, t := range q.Terms {
+		if t.Field != FieldType {
+			continue
+		}
+		if t.Value.Kind == Regex {
+			return nil, &ParseError{Pos: t.Value.Pos, Msg: "type: doesn't take a regular expression"}
+		}
+		name := strings.ToLower(t.Value.Text)
+		if _, unknown := models.Empty(name).(*models.Model); unknown {
+			return nil, &ParseError{Pos: t.Value.Pos, Msg: "unknown type " + t.Value.Text}
+		}
+		kind := models.KindOf(models.Empty(name))
+		if t.Negated {
+			excluded[kind] = true
+		} else {
+			included = append(included, kind)
+		}
+	}
+	if len(included) == 0 {
+		for _, t := range defaultTypes {
+			e := models.Empty(strings.ToLower(t))
+			if _, unknown := e.(*models.Model); unknown {
+				return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidQuery, t)
+			}
+			included = append(included, models.KindOf(e))
+		}
+	}
+	types := []string{}
+	for _, t := range included {
+		if !excluded[t] {
+			types = append(types, t)
+		}
+	}
+	return types, nil
+}
+
+// Eval runs q against the database, returning matching items of each type
+// newest first. If q doesn't restrict the types searched, defaultTypes are
+// used. idx, if given and loaded, narrows down the items of implemented kinds
+// that sequence terms need to check.
+func Eval(s *models.Store, idx *seqindex.Index, q *Query, defaultTypes []string) ([]models.Entity, error) {
+	if idx != nil && !idx.Ready() {
+		idx = nil
+	}
+	types, err := queryTypes(q, defaultTypes)
+	if err != nil {
+		return []models.Entity{}, err
+	}
+
+	results := []models.Entity{}
+TypeLoop:
+	for _, t := range types {
+		obj := models.Empty(t)
+		query := s.Db()
+		seqTerms := []Term{}
+		for _, term := range q.Terms {
+			if term.Field == FieldType {
+				continue
+			}
+			if isSequenceTerm(term) {
+				// These are matched in Go once the other conditions have
+				// narrowed things down.
+				if obj.SequenceFieldName() == "" {
+					if term.Negated {
+						continue
+					}
+					continue TypeLoop
+				}
+				if !term.Negated {
+					query = query.Where(obj.SequenceFieldName() + " <> ''")
+					// Rails saves the other kinds without updating idx, so
+					// it can only be trusted for kinds the Go store writes.
+					if idx != nil && models.IsImplemented(t) {
+						ids := indexedIDs(idx, term, t)
+						if len(ids) == 0 {
+							continue TypeLoop
+						}
+						query = query.Where("id IN (?)", ids)
+					}
+				}
+				seqTerms = append(seqTerms, term)
+				continue
+			}
+			cond, ok, err := compileTerm(term, obj)
+			if err != nil {
+				return []models.Entity{}, err
+			}
+			if !ok {
+				if term.Negated {
+					// Nothing to exclude.
+					continue
+				}
+				// This type can't match at all.
+				continue TypeLoop
+			}
+			if term.Negated {
+				query = query.Where("NOT COALESCE(("+cond.sql+"), false)", cond.args...)
+			} else {
+				query = query.Where(cond.sql, cond.args...)
+			}
+		}
+		found, err := models.RunQuery(t, query.Order("id desc"))
+		if err != nil {
+			return []models.Entity{}, err
+		}
+		for _, e := range found {
+			if matchesSequenceTerms(e, seqTerms) {
+				results = append(results, e)
+			}
+		}
+	}
+	return results, nil
+}
+
+// indexedIDs returns the IDs of items of the given kind that match the
+// sequence term t according to idx.
+func indexedIDs(idx *seqindex.Index, t Term, kind string) []uint {
+	ids := []uint{}
+	for _, hit := range idx.Search(t.Value.Text, t.Value.Mismatches) {
+		if hit.Key.Kind == kind {
+			ids = append(ids, hit.Key.ID)
+		}
+	}
+	return ids
+}
+
+// SequenceMatches finds where the DNA sequence in t matches e's sequence, in
+// positions along the normalized sequence (see sequence.Normalize).
+func SequenceMatches(e models.Entity, t Term) []sequence.Match {
+	target := sequence.Normalize(e.GetSequence())
+	return sequence.Find(t.Value.Text, target, t.Value.Mismatches, e.IsCircular())
+}
+
+func matchesSequenceTerms(e models.Entity, terms []Term) bool {
+	for _, t := range terms {
+		found := len(SequenceMatches(e, t)) > 0
+		if found == t.Negated {
+			return false
+		}
+	}
+	return true
+}
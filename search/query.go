@@ -0,0 +1,288 @@
+package search
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"labdb.org/labdb/sequence"
+)
+
+// The query language is a list of whitespace separated terms, all of which
+// must match:
+//
+//	owner:colin type:plasmid seq:/GATC.*/ created:>2023-01-01 "exact phrase" -excluded
+//
+// A term is an optional "-" (negation), an optional "field:" prefix and a
+// value. Values are bare words (where * matches anything), "quoted phrases" or
+// /regular expressions/ (with an optional trailing i for case insensitivity).
+// Date fields also take a comparison (>, >=, <, <=, =) before the value.
+//
+// seq: terms other than regular expressions are DNA sequences, which may use
+// IUPAC ambiguity codes, are matched against both strands, and may end in ~k
+// to allow up to k mismatches (e.g. seq:GATCNNRY~1).
+
+type ValueKind int
+
+const (
+	Word ValueKind = iota
+	Phrase
+	Regex
+)
+
+type Value struct {
+	Pos             int
+	Kind            ValueKind
+	Text            string
+	CaseInsensitive bool
+	// Mismatches is the number of mismatches allowed for sequence terms.
+	Mismatches int
+}
+
+type Term struct {
+	Pos     int
+	Negated bool
+	// Field is empty for free text terms.
+	Field string
+	// Op is the comparison for date fields, and empty otherwise.
+	Op    string
+	Value Value
+	// Date is the parsed value of date fields.
+	Date time.Time
+}
+
+type Query struct {
+	Terms []Term
+}
+
+// ParseError describes where in the query parsing failed. Pos is a byte
+// offset into the query.
+type ParseError struct {
+	Pos int
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("at position %d: %s", e.Pos, e.Msg)
+}
+
+func (e *ParseError) Unwrap() error {
+	return ErrInvalidQuery
+}
+
+const (
+	FieldOwner   = "owner"
+	FieldType    = "type"
+	FieldSeq     = "seq"
+	FieldName    = "name"
+	FieldDesc    = "desc"
+	FieldCreated = "created"
+	FieldUpdated = "updated"
+)
+
+var knownFields = map[string]bool{
+	FieldOwner:   true,
+	FieldType:    true,
+	FieldSeq:     true,
+	FieldName:    true,
+	FieldDesc:    true,
+	FieldCreated: true,
+	FieldUpdated: true,
+}
+
+func isDateField(field string) bool {
+	return field == FieldCreated || field == FieldUpdated
+}
+
+const dateFormat = "2006-01-02"
+
+type parser struct {
+	input string
+	pos   int
+}
+
+// Parse parses a query in the search language.
+func Parse(input string) (*Query, error) {
+	p := &parser{input: input}
+	q := &Query{}
+	for {
+		p.skipSpace()
+		if p.done() {
+			break
+		}
+		t, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		q.Terms = append(q.Terms, t)
+	}
+	if len(q.Terms) == 0 {
+		return nil, &ParseError{Pos: 0, Msg: "empty query"}
+	}
+	return q, nil
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *parser) peek() byte {
+	return p.input[p.pos]
+}
+
+func (p *parser) atSpace() bool {
+	return p.done() || unicode.IsSpace(rune(p.peek()))
+}
+
+func (p *parser) skipSpace() {
+	for !p.done() && unicode.IsSpace(rune(p.peek())) {
+		p.pos++
+	}
+}
+
+func (p *parser) errorf(pos int, format string, args ...interface{}) error {
+	return &ParseError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) term() (Term, error) {
+	t := Term{Pos: p.pos}
+	if p.peek() == '-' && p.pos+1 < len(p.input) && !unicode.IsSpace(rune(p.input[p.pos+1])) {
+		t.Negated = true
+		p.pos++
+	}
+	if field, ok := p.fieldPrefix(); ok {
+		if !knownFields[field] {
+			return t, p.errorf(p.pos, "unknown field %q", field)
+		}
+		t.Field = field
+		p.pos += len(field) + 1
+		if isDateField(field) {
+			t.Op = p.comparison()
+		}
+		if p.atSpace() {
+			return t, p.errorf(p.pos, "missing value for %s:", field)
+		}
+	}
+	v, err := p.value()
+	if err != nil {
+		return t, err
+	}
+	t.Value = v
+	if isDateField(t.Field) {
+		if v.Kind != Word {
+			return t, p.errorf(v.Pos, "%s: takes a date like 2023-01-31", t.Field)
+		}
+		d, err := time.Parse(dateFormat, v.Text)
+		if err != nil {
+			return t, p.errorf(v.Pos, "bad date %q, expected a date like 2023-01-31", v.Text)
+		}
+		t.Date = d
+	}
+	if isSequenceTerm(t) {
+		if err := p.sequenceValue(&t.Value); err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+// isSequenceTerm reports whether t is searched for as a DNA sequence rather
+// than as text.
+func isSequenceTerm(t Term) bool {
+	return t.Field == FieldSeq && t.Value.Kind != Regex
+}
+
+// sequenceValue normalizes the DNA sequence in v, splitting off a ~k
+// mismatch count if there is one.
+func (p *parser) sequenceValue(v *Value) error {
+	text := v.Text
+	if i := strings.LastIndexByte(text, '~'); i >= 0 && v.Kind == Word {
+		k, err := strconv.Atoi(text[i+1:])
+		if err != nil || k < 0 {
+			return p.errorf(v.Pos+i, "expected a number of mismatches after ~")
+		}
+		v.Mismatches = k
+		text = text[:i]
+	}
+	if strings.IndexFunc(text, unicode.IsDigit) >= 0 {
+		return p.errorf(v.Pos, "seq: takes a DNA sequence or a /regular expression/")
+	}
+	text = sequence.Normalize(text)
+	if !sequence.IsDNA(text) {
+		return p.errorf(v.Pos, "seq: takes a DNA sequence or a /regular expression/")
+	}
+	if v.Mismatches >= len(text) {
+		return p.errorf(v.Pos, "too many mismatches for a %d base sequence", len(text))
+	}
+	v.Text = text
+	return nil
+}
+
+// fieldPrefix reports whether the input continues with "name:", returning
+// name if so.
+func (p *parser) fieldPrefix() (string, bool) {
+	end := p.pos
+	for end < len(p.input) && (unicode.IsLetter(rune(p.input[end])) || p.input[end] == '_') {
+		end++
+	}
+	if end == p.pos || end >= len(p.input) || p.input[end] != ':' {
+		return "", false
+	}
+	return strings.ToLower(p.input[p.pos:end]), true
+}
+
+func (p *parser) comparison() string {
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(p.input[p.pos:], op) {
+			p.pos += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+func (p *parser) value() (Value, error) {
+	start := p.pos
+	switch p.peek() {
+	case '"':
+		end := strings.IndexByte(p.input[start+1:], '"')
+		if end < 0 {
+			return Value{}, p.errorf(start, "unterminated quoted phrase")
+		}
+		p.pos = start + 1 + end + 1
+		if !p.atSpace() {
+			return Value{}, p.errorf(p.pos, "expected a space after quoted phrase")
+		}
+		return Value{Pos: start, Kind: Phrase, Text: p.input[start+1 : start+1+end]}, nil
+	case '/':
+		i := start + 1
+		for ; i < len(p.input) && p.input[i] != '/'; i++ {
+			if p.input[i] == '\\' {
+				i++
+			}
+		}
+		if i >= len(p.input) {
+			return Value{}, p.errorf(start, "unterminated regular expression")
+		}
+		v := Value{Pos: start, Kind: Regex, Text: p.input[start+1 : i]}
+		p.pos = i + 1
+		if !p.done() && p.peek() == 'i' {
+			v.CaseInsensitive = true
+			p.pos++
+		}
+		if !p.atSpace() {
+			return Value{}, p.errorf(p.pos, "unexpected %q after regular expression", p.peek())
+		}
+		if v.Text == "" {
+			return Value{}, p.errorf(start, "empty regular expression")
+		}
+		return v, nil
+	default:
+		for !p.atSpace() {
+			p.pos++
+		}
+		return Value{Pos: start, Kind: Word, Text: p.input[start:p.pos]}, nil
+	}
+}
@@ -0,0 +1,101 @@
+package search
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []Term
+	}{
+		{"gfp", []Term{{Pos: 0, Value: Value{Pos: 0, Kind: Word, Text: "gfp"}}}},
+		{"  gfp*  amp ", []Term{
+			{Pos: 2, Value: Value{Pos: 2, Kind: Word, Text: "gfp*"}},
+			{Pos: 8, Value: Value{Pos: 8, Kind: Word, Text: "amp"}},
+		}},
+		{`"green fluorescent" -"red one"`, []Term{
+			{Pos: 0, Value: Value{Pos: 0, Kind: Phrase, Text: "green fluorescent"}},
+			{Pos: 20, Negated: true, Value: Value{Pos: 21, Kind: Phrase, Text: "red one"}},
+		}},
+		// Negation applies to the whole term, field and all.
+		{"-type:plasmid Owner:colin", []Term{
+			{Pos: 0, Negated: true, Field: FieldType, Value: Value{Pos: 6, Kind: Word, Text: "plasmid"}},
+			{Pos: 14, Field: FieldOwner, Value: Value{Pos: 20, Kind: Word, Text: "colin"}},
+		}},
+		// A lone - is a word.
+		{"- x", []Term{
+			{Pos: 0, Value: Value{Pos: 0, Kind: Word, Text: "-"}},
+			{Pos: 2, Value: Value{Pos: 2, Kind: Word, Text: "x"}},
+		}},
+		// Colons inside values don't start a field.
+		{`name:"a:b" 12:30`, []Term{
+			{Pos: 0, Field: FieldName, Value: Value{Pos: 5, Kind: Phrase, Text: "a:b"}},
+			{Pos: 11, Value: Value{Pos: 11, Kind: Word, Text: "12:30"}},
+		}},
+		{`desc:/ge?ne\/x/i seq:/GA.C/`, []Term{
+			{Pos: 0, Field: FieldDesc, Value: Value{Pos: 5, Kind: Regex, Text: `ge?ne\/x`, CaseInsensitive: true}},
+			{Pos: 17, Field: FieldSeq, Value: Value{Pos: 21, Kind: Regex, Text: "GA.C"}},
+		}},
+		{"seq:gatc-nnry~1", []Term{
+			{Pos: 0, Field: FieldSeq, Value: Value{Pos: 4, Kind: Word, Text: "GATCNNRY", Mismatches: 1}},
+		}},
+		{"created:>=2023-01-31 updated:2024-02-01", []Term{
+			{Pos: 0, Field: FieldCreated, Op: ">=", Value: Value{Pos: 10, Kind: Word, Text: "2023-01-31"}, Date: time.Date(2023, 1, 31, 0, 0, 0, 0, time.UTC)},
+			{Pos: 21, Field: FieldUpdated, Value: Value{Pos: 29, Kind: Word, Text: "2024-02-01"}, Date: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		}},
+	}
+	for _, test := range tests {
+		q, err := Parse(test.input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(q.Terms, test.want) {
+			t.Errorf("Parse(%q) =\n%+v\nwant\n%+v", test.input, q.Terms, test.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+	}{
+		{"", 0},
+		{"   ", 0},
+		{"colour:red", 0},
+		{"gfp -colour:red", 5},
+		{"owner: colin", 6},
+		{"owner:", 6},
+		{`x "unterminated`, 2},
+		{`"phrase"x`, 8},
+		{"/unterminated", 0},
+		{`seq:/GA\/`, 4},
+		{"/re/x", 4},
+		{"name://", 5},
+		{"created:>yesterday", 9},
+		{`created:"2023-01-31"`, 8},
+		{"seq:GATTACA~x", 11},
+		{"seq:GAT~3", 4},
+		{"seq:GATC12", 4},
+		{"seq:hello", 4},
+	}
+	for _, test := range tests {
+		_, err := Parse(test.input)
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("Parse(%q) = %v, want a ParseError", test.input, err)
+			continue
+		}
+		if perr.Pos != test.pos {
+			t.Errorf("Parse(%q) failed at %d (%s), want %d", test.input, perr.Pos, perr.Msg, test.pos)
+		}
+		if !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("Parse(%q) error isn't ErrInvalidQuery", test.input)
+		}
+	}
+}
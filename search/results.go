@@ -0,0 +1,186 @@
+package search
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"labdb.org/labdb/models"
+	"labdb.org/labdb/sequence"
+)
+
+// Span is a highlighted range of a snippet, in characters.
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Result is the JSON representation of a search hit.
+type Result struct {
+	Type       string `json:"type"`
+	ID         uint   `json:"id"`
+	Name       string `json:"name"`
+	ShortDesc  string `json:"shortDesc"`
+	Owner      string `json:"owner"`
+	MatchField string `json:"matchField,omitempty"`
+	Snippet    string `json:"snippet,omitempty"`
+	Highlights []Span `json:"highlights,omitempty"`
+	// SequenceMatches are the hits of seq: terms on either strand, in
+	// positions along the normalized sequence.
+	SequenceMatches []sequence.Match `json:"sequenceMatches,omitempty"`
+}
+
+// snippetContext is roughly how many characters of context are kept either
+// side of the first match when a field is too long to return whole.
+const snippetContext = 60
+
+// highlighter finds the text matched by one term of a query.
+type highlighter struct {
+	fields []string
+	re     *regexp.Regexp
+}
+
+func valueRegexp(v Value) (*regexp.Regexp, error) {
+	switch v.Kind {
+	case Regex:
+		if v.CaseInsensitive {
+			return regexp.Compile("(?i)" + v.Text)
+		}
+		return regexp.Compile(v.Text)
+	case Phrase:
+		return regexp.Compile("(?i)" + regexp.QuoteMeta(v.Text))
+	default:
+		parts := strings.Split(v.Text, "*")
+		for i, p := range parts {
+			parts[i] = regexp.QuoteMeta(p)
+		}
+		return regexp.Compile("(?i)" + strings.Join(parts, ".*?"))
+	}
+}
+
+func highlighters(q *Query) []highlighter {
+	hs := []highlighter{}
+	for _, t := range q.Terms {
+		if t.Negated || isSequenceTerm(t) {
+			continue
+		}
+		var fields []string
+		switch t.Field {
+		case "":
+			fields = []string{FieldName, FieldDesc}
+		case FieldName, FieldDesc, FieldSeq:
+			fields = []string{t.Field}
+		default:
+			continue
+		}
+		re, err := valueRegexp(t.Value)
+		// Patterns that match the empty string (like a bare *) don't highlight
+		// anything useful.
+		if err != nil || re.MatchString("") {
+			continue
+		}
+		hs = append(hs, highlighter{fields: fields, re: re})
+	}
+	return hs
+}
+
+func fieldText(e models.Entity, field string) string {
+	switch field {
+	case FieldName:
+		return e.ShortDesc()
+	case FieldDesc:
+		return e.Desc()
+	case FieldSeq:
+		return e.GetSequence()
+	}
+	return ""
+}
+
+// Describe builds the search result for e, finding where q matched it.
+func Describe(e models.Entity, q *Query) Result {
+	r := Result{
+		Type:      models.KindOf(e),
+		ID:        e.GetID(),
+		Name:      models.NameOf(e),
+		ShortDesc: e.ShortDesc(),
+		Owner:     models.OwnerOf(e),
+	}
+	hs := highlighters(q)
+	for _, field := range []string{FieldName, FieldDesc, FieldSeq} {
+		text := fieldText(e, field)
+		matches := [][]int{}
+		for _, h := range hs {
+			for _, f := range h.fields {
+				if f == field {
+					matches = append(matches, h.re.FindAllStringIndex(text, -1)...)
+				}
+			}
+		}
+		if len(matches) == 0 {
+			continue
+		}
+		r.MatchField = field
+		r.Snippet, r.Highlights = snippet(text, matches)
+		break
+	}
+
+	for _, t := range q.Terms {
+		if isSequenceTerm(t) && !t.Negated {
+			r.SequenceMatches = append(r.SequenceMatches, SequenceMatches(e, t)...)
+		}
+	}
+	if r.MatchField == "" && len(r.SequenceMatches) > 0 {
+		text := sequence.Normalize(e.GetSequence())
+		matches := [][]int{}
+		for _, m := range r.SequenceMatches {
+			end := m.End
+			if end > len(text) {
+				// Wraps around the origin; just highlight up to the end.
+				end = len(text)
+			}
+			matches = append(matches, []int{m.Start, end})
+		}
+		r.MatchField = FieldSeq
+		r.Snippet, r.Highlights = snippet(text, matches)
+	}
+	return r
+}
+
+// snippet cuts text down to the region around the earliest of matches (byte
+// ranges into text), returning it with the matches that fall inside it.
+func snippet(text string, matches [][]int) (string, []Span) {
+	first := matches[0]
+	for _, m := range matches {
+		if m[0] < first[0] {
+			first = m
+		}
+	}
+	start := 0
+	end := len(text)
+	if first[0] > snippetContext {
+		start = first[0] - snippetContext
+	}
+	if end-first[1] > snippetContext {
+		end = first[1] + snippetContext
+	}
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+
+	spans := []Span{}
+	for _, m := range matches {
+		if m[0] < start || m[1] > end || m[0] == m[1] {
+			continue
+		}
+		spans = append(spans, Span{
+			Start: utf8.RuneCountInString(text[start:m[0]]),
+			End:   utf8.RuneCountInString(text[start:m[1]]),
+		})
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+	return text[start:end], spans
+}
@@ -2,70 +2,110 @@ package search
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
 	"strings"
 
 	"labdb.org/labdb/models"
 )
 
-func Matches(re *regexp.Regexp, target string, caseInsensitive bool) bool {
-	normTarget := target
-	if caseInsensitive {
-		normTarget = strings.ToLower(normTarget)
+// ErrInvalidQuery is returned (possibly wrapped) for searches that can't be
+// run as written, as opposed to failures talking to the database.
+var ErrInvalidQuery = errors.New("invalid search query")
+
+// Matcher is a search term translated to a SQL condition on a single column.
+type Matcher struct {
+	op  string
+	arg string
+}
+
+// NewMatcher translates a search term. Terms of the form /re/ or /re/i become
+// (case insensitive) Postgres regular expression matches; anything else is a
+// case insensitive match of the whole field where * matches any run of
+// characters.
+func NewMatcher(term string) (Matcher, error) {
+	if term == "" {
+		return Matcher{}, fmt.Errorf("%w: empty term", ErrInvalidQuery)
 	}
-	return re.MatchString(normTarget)
+	if term[0] != '/' {
+		return Matcher{op: "ILIKE", arg: globToLike(term)}, nil
+	}
+	op := "~"
+	pattern := ""
+	if strings.HasSuffix(term, "/i") && len(term) > 2 {
+		op = "~*"
+		pattern = term[1 : len(term)-2]
+	} else if strings.HasSuffix(term, "/") && len(term) > 1 {
+		pattern = term[1 : len(term)-1]
+	} else {
+		return Matcher{}, fmt.Errorf("%w: malformed regular expression", ErrInvalidQuery)
+	}
+	// Postgres regexes are close enough to RE2 that this catches syntax errors
+	// before they turn into database errors.
+	if _, err := regexp.Compile(pattern); err != nil {
+		return Matcher{}, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+	return Matcher{op: op, arg: pattern}, nil
+}
+
+func globToLike(term string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `*`, `%`)
+	return escaper.Replace(term)
 }
 
-func Search(term string, includeSequence bool, person string, types []string) ([]models.Entity, error) {
-	normTerm := term
-	caseInsensitive := false
-	if normTerm[0] == '/' {
-		if strings.HasSuffix(normTerm, "/i") {
-			caseInsensitive = true
-			normTerm = strings.Replace(normTerm, "/i", "", -1)
-			normTerm = strings.Replace(normTerm, "/", "", -1)
-			normTerm = strings.ToLower(normTerm)
-		} else if strings.HasSuffix(normTerm, "/") {
-			normTerm = strings.Replace(normTerm, "/", "", -1)
-		} else {
-			return []models.Entity{}, errors.New("malformed regular expression query")
+// Condition returns the SQL condition and its argument for column. column
+// must come from code, not user input, since it's inserted directly.
+func (m Matcher) Condition(column string) (string, interface{}) {
+	return column + " " + m.op + " ?", m.arg
+}
+
+// searchColumns returns the columns of e that a search applies to.
+func searchColumns(e models.Entity, includeSequence bool) []string {
+	columns := []string{}
+	for _, col := range []string{e.ShortDescFieldName(), e.DescFieldName()} {
+		if col != "" {
+			columns = append(columns, col)
 		}
-	} else {
-		normTerm = "^" + strings.Replace(normTerm, "*", ".*", -1) + "$"
 	}
-	regex, err := regexp.Compile(normTerm)
+	if includeSequence && e.SequenceFieldName() != "" {
+		columns = append(columns, e.SequenceFieldName())
+	}
+	return columns
+}
+
+func Search(s *models.Store, term string, includeSequence bool, person string, types []string) ([]models.Entity, error) {
+	matcher, err := NewMatcher(term)
 	if err != nil {
 		return []models.Entity{}, err
 	}
 
 	results := []models.Entity{}
 	for _, t := range types {
-		query := models.Db()
 		normType := strings.ToLower(t)
 		obj := models.Empty(normType)
+		if _, unknown := obj.(*models.Model); unknown {
+			return []models.Entity{}, fmt.Errorf("%w: unknown type %q", ErrInvalidQuery, t)
+		}
+		query := s.Db()
 		if person != "" {
 			query = query.Where(obj.OwnerFieldName()+" = ?", person)
 		}
-		query.Order("id desc")
-		queryResultsIter := models.RunQueryLazy(normType, query)
-		var result models.Entity
-		for ; queryResultsIter.HasNext(); result = queryResultsIter.Next() {
-			if result == nil {
-				continue
-			}
-			if Matches(regex, result.ShortDesc(), caseInsensitive) {
-				results = append(results, result)
-				continue
-			}
-			if Matches(regex, result.Desc(), caseInsensitive) {
-				results = append(results, result)
-				continue
-			}
-			if includeSequence && Matches(regex, result.GetSequence(), caseInsensitive) {
-				results = append(results, result)
-				continue
-			}
+		conditions := []string{}
+		args := []interface{}{}
+		for _, col := range searchColumns(obj, includeSequence) {
+			cond, arg := matcher.Condition(col)
+			conditions = append(conditions, cond)
+			args = append(args, arg)
+		}
+		if len(conditions) == 0 {
+			continue
+		}
+		query = query.Where(strings.Join(conditions, " OR "), args...).Order("id desc")
+		found, err := models.RunQuery(normType, query)
+		if err != nil {
+			return []models.Entity{}, err
 		}
+		results = append(results, found...)
 	}
 	return results, nil
 }
@@ -0,0 +1,374 @@
+// Package seqindex keeps an in-memory k-mer index over the sequences of all
+// sequence-bearing items, so that sequence searches only need to scan the
+// few items that could possibly match.
+package seqindex
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"labdb.org/labdb/models"
+	"labdb.org/labdb/sequence"
+)
+
+// DefaultK is long enough to be selective over the lab's sequences, while
+// short enough that both halves of a typical primer can use the index when
+// allowing a mismatch.
+const DefaultK = 10
+
+// MaxK bounds the memory used by the postings table, which has 4^k entries.
+const MaxK = 12
+
+const loadPageSize = 1000
+
+// minCompact is how many removed docs there have to be before the index is
+// compacted. Past that, it's compacted once they outnumber the live ones.
+const minCompact = 1024
+
+// Key identifies an indexed item.
+type Key struct {
+	Kind string
+	ID   uint
+}
+
+func KeyOf(e models.Entity) Key {
+	return Key{Kind: models.KindOf(e), ID: e.GetID()}
+}
+
+// Hit is an item whose sequence matched a search.
+type Hit struct {
+	Key     Key
+	Matches []sequence.Match
+}
+
+type doc struct {
+	key      Key
+	seq      string
+	circular bool
+	live     bool
+}
+
+type Index struct {
+	mu    sync.RWMutex
+	k     int
+	docs  []doc
+	byKey map[Key]int32
+	// dead counts the removed docs still taking up space in docs and
+	// postings.
+	dead int
+	// ambiguous holds the live docs with ambiguity codes. Their k-mers
+	// spanning those codes aren't in postings, and a pattern could match
+	// there, so they're always scanned.
+	ambiguous map[int32]bool
+	// postings lists the docs containing each k-mer, in ascending order.
+	postings [][]int32
+	ready    bool
+}
+
+func New(k int) *Index {
+	if k <= 0 || k > MaxK {
+		panic("seqindex: k out of range")
+	}
+	return &Index{
+		k:         k,
+		byKey:     map[Key]int32{},
+		ambiguous: map[int32]bool{},
+		postings:  make([][]int32, 1<<(2*uint(k))),
+	}
+}
+
+var baseCodes = [256]int8{}
+
+func init() {
+	for i := range baseCodes {
+		baseCodes[i] = -1
+	}
+	baseCodes['A'] = 0
+	baseCodes['C'] = 1
+	baseCodes['G'] = 2
+	baseCodes['T'] = 3
+}
+
+// eachKmer calls f with the 2-bit encoding of every k-mer of s that only
+// contains unambiguous bases.
+func eachKmer(s string, k int, f func(kmer uint64)) {
+	mask := uint64(1)<<(2*uint(k)) - 1
+	kmer := uint64(0)
+	valid := 0
+	for i := 0; i < len(s); i++ {
+		code := baseCodes[s[i]]
+		if code < 0 {
+			valid = 0
+			continue
+		}
+		kmer = (kmer<<2 | uint64(code)) & mask
+		valid++
+		if valid >= k {
+			f(kmer)
+		}
+	}
+}
+
+// Ready reports whether the initial Load has finished. Until then searches
+// miss items that haven't been loaded yet.
+func (ix *Index) Ready() bool {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.ready
+}
+
+// Add indexes seq under key, replacing whatever was indexed there before.
+func (ix *Index) Add(key Key, seq string, circular bool) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.addLocked(key, seq, circular)
+}
+
+func (ix *Index) addLocked(key Key, seq string, circular bool) {
+	ix.removeLocked(key)
+	norm := sequence.Normalize(seq)
+	if norm == "" {
+		return
+	}
+	id := int32(len(ix.docs))
+	ix.docs = append(ix.docs, doc{key: key, seq: norm, circular: circular, live: true})
+	ix.byKey[key] = id
+	ix.post(id)
+}
+
+// post adds doc id to the postings of its k-mers. ix.mu must be held.
+func (ix *Index) post(id int32) {
+	d := ix.docs[id]
+	indexed := d.seq
+	if d.circular && len(d.seq) >= ix.k {
+		indexed += d.seq[:ix.k-1]
+	}
+	for i := 0; i < len(d.seq); i++ {
+		if baseCodes[d.seq[i]] < 0 {
+			ix.ambiguous[id] = true
+			break
+		}
+	}
+	kmers := make([]uint64, 0, len(indexed))
+	eachKmer(indexed, ix.k, func(kmer uint64) {
+		kmers = append(kmers, kmer)
+	})
+	sort.Slice(kmers, func(i, j int) bool { return kmers[i] < kmers[j] })
+	for i, kmer := range kmers {
+		if i == 0 || kmer != kmers[i-1] {
+			ix.postings[kmer] = append(ix.postings[kmer], id)
+		}
+	}
+}
+
+// Remove drops key from the index. Its postings are left in place and skipped
+// at search time until there are enough removed docs to compact the index.
+func (ix *Index) Remove(key Key) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.removeLocked(key)
+}
+
+func (ix *Index) removeLocked(key Key) {
+	id, found := ix.byKey[key]
+	if !found {
+		return
+	}
+	ix.docs[id].live = false
+	ix.docs[id].seq = ""
+	delete(ix.byKey, key)
+	delete(ix.ambiguous, id)
+	ix.dead++
+	if ix.dead >= minCompact && ix.dead > len(ix.byKey) {
+		ix.compact()
+	}
+}
+
+// compact drops removed docs, renumbering the rest and rebuilding the
+// postings. ix.mu must be held.
+func (ix *Index) compact() {
+	live := make([]doc, 0, len(ix.byKey))
+	for _, d := range ix.docs {
+		if d.live {
+			live = append(live, d)
+		}
+	}
+	ix.docs = live
+	ix.dead = 0
+	ix.byKey = map[Key]int32{}
+	ix.ambiguous = map[int32]bool{}
+	for i := range ix.postings {
+		ix.postings[i] = nil
+	}
+	for id, d := range ix.docs {
+		ix.byKey[d.key] = int32(id)
+		ix.post(int32(id))
+	}
+}
+
+// Len returns the number of indexed items.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.byKey)
+}
+
+// Watch keeps the index up to date as items are saved to and deleted from s.
+func (ix *Index) Watch(s *models.Store) {
+	s.OnSave(func(e models.Entity) {
+		if e.SequenceFieldName() != "" {
+			ix.Add(KeyOf(e), e.GetSequence(), e.IsCircular())
+		}
+	})
+	s.OnDelete(func(e models.Entity) {
+		ix.Remove(KeyOf(e))
+	})
+}
+
+// Load indexes every item of the given types. Items already in the index
+// (i.e. saved since loading started) are left alone, since they're newer.
+func (ix *Index) Load(ctx context.Context, s *models.Store, types []string) error {
+	for _, t := range types {
+		obj := models.Empty(t)
+		column := obj.SequenceFieldName()
+		if column == "" {
+			continue
+		}
+		lastID := uint(0)
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			page, err := models.RunQuery(t, s.Db().Where(column+" <> ''").Where("id > ?", lastID).Order("id").Limit(loadPageSize))
+			if err != nil {
+				return err
+			}
+			ix.mu.Lock()
+			for _, e := range page {
+				if _, found := ix.byKey[KeyOf(e)]; !found {
+					ix.addLocked(KeyOf(e), e.GetSequence(), e.IsCircular())
+				}
+				lastID = e.GetID()
+			}
+			ix.mu.Unlock()
+			if len(page) < loadPageSize {
+				break
+			}
+		}
+	}
+	ix.mu.Lock()
+	ix.ready = true
+	ix.mu.Unlock()
+	return nil
+}
+
+// intersect returns the ids in both a and b, which must be sorted.
+func intersect(a, b []int32) []int32 {
+	out := []int32{}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// exactCandidates returns the docs containing every unambiguous k-mer of
+// pattern. ok is false if pattern has no such k-mers to narrow things down.
+func (ix *Index) exactCandidates(pattern string) (ids []int32, ok bool) {
+	eachKmer(pattern, ix.k, func(kmer uint64) {
+		if !ok {
+			ids = ix.postings[kmer]
+			ok = true
+		} else if len(ids) > 0 {
+			ids = intersect(ids, ix.postings[kmer])
+		}
+	})
+	return ids, ok
+}
+
+// candidates returns the docs that might match pattern on its forward strand
+// with up to maxMismatches mismatches. If the pattern is split into
+// maxMismatches+1 pieces, at least one of them has to match exactly.
+func (ix *Index) candidates(pattern string, maxMismatches int) (map[int32]bool, bool) {
+	pieces := maxMismatches + 1
+	pieceLen := len(pattern) / pieces
+	if pieceLen < ix.k {
+		return nil, false
+	}
+	ids := map[int32]bool{}
+	for i := 0; i < pieces; i++ {
+		end := (i + 1) * pieceLen
+		if i == pieces-1 {
+			end = len(pattern)
+		}
+		found, ok := ix.exactCandidates(pattern[i*pieceLen : end])
+		if !ok {
+			return nil, false
+		}
+		for _, id := range found {
+			ids[id] = true
+		}
+	}
+	return ids, true
+}
+
+// Search finds the items whose sequences match pattern (which may contain
+// IUPAC codes) on either strand with at most maxMismatches mismatches, with
+// the same results as sequence.Find. Short or highly ambiguous patterns can't
+// use the index and fall back to scanning every indexed sequence, as do
+// sequences with ambiguity codes of their own.
+func (ix *Index) Search(pattern string, maxMismatches int) []Hit {
+	pattern = sequence.Normalize(pattern)
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	ids := map[int32]bool{}
+	useIndex := true
+	for _, p := range []string{pattern, sequence.ReverseComplement(pattern)} {
+		found, ok := ix.candidates(p, maxMismatches)
+		if !ok {
+			useIndex = false
+			break
+		}
+		for id := range found {
+			ids[id] = true
+		}
+	}
+	if useIndex {
+		for id := range ix.ambiguous {
+			ids[id] = true
+		}
+	} else {
+		for _, id := range ix.byKey {
+			ids[id] = true
+		}
+	}
+
+	sorted := []int32{}
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	hits := []Hit{}
+	for _, id := range sorted {
+		d := ix.docs[id]
+		if !d.live {
+			continue
+		}
+		matches := sequence.Find(pattern, d.seq, maxMismatches, d.circular)
+		if len(matches) > 0 {
+			hits = append(hits, Hit{Key: d.key, Matches: matches})
+		}
+	}
+	return hits
+}
@@ -0,0 +1,152 @@
+package seqindex
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"labdb.org/labdb/sequence"
+)
+
+type testDoc struct {
+	key      Key
+	seq      string
+	circular bool
+}
+
+func randomSeq(r *rand.Rand, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = "ACGT"[r.Intn(4)]
+	}
+	return string(b)
+}
+
+// corpus makes n random sequences, every fifth circular and every seventh
+// with a run of Ns.
+func corpus(n, length int) []testDoc {
+	r := rand.New(rand.NewSource(1))
+	docs := []testDoc{}
+	for i := 0; i < n; i++ {
+		seq := randomSeq(r, length)
+		if i%7 == 0 {
+			at := r.Intn(length - 20)
+			seq = seq[:at] + strings.Repeat("N", 20) + seq[at+20:]
+		}
+		docs = append(docs, testDoc{key: Key{Kind: "plasmid", ID: uint(i + 1)}, seq: seq, circular: i%5 == 0})
+	}
+	return docs
+}
+
+func indexOf(docs []testDoc) *Index {
+	ix := New(DefaultK)
+	for _, d := range docs {
+		ix.Add(d.key, d.seq, d.circular)
+	}
+	return ix
+}
+
+// patterns takes count substrings of the corpus, some with a mismatch, some
+// reverse complemented, some across the origin of circular sequences and
+// some across N runs.
+func patterns(docs []testDoc, count, length int) []string {
+	r := rand.New(rand.NewSource(2))
+	ps := []string{}
+	for len(ps) < count {
+		d := docs[r.Intn(len(docs))]
+		seq := d.seq
+		if d.circular {
+			seq += seq
+		}
+		start := r.Intn(len(d.seq))
+		if start+length > len(seq) {
+			continue
+		}
+		p := []byte(seq[start : start+length])
+		if r.Intn(2) == 0 {
+			p[r.Intn(length)] = "ACGT"[r.Intn(4)]
+		}
+		s := string(p)
+		if r.Intn(2) == 0 {
+			s = sequence.ReverseComplement(s)
+		}
+		ps = append(ps, s)
+	}
+	ps = append(ps, randomSeq(r, length))
+	if i := strings.Index(docs[0].seq, "N"); i >= 5 {
+		// Matches only because the Ns match anything.
+		ps = append(ps, docs[0].seq[i-5:i]+randomSeq(r, length-5))
+	}
+	return ps
+}
+
+func linearFind(docs []testDoc, pattern string, maxMismatches int) []Hit {
+	hits := []Hit{}
+	for _, d := range docs {
+		if matches := sequence.Find(pattern, d.seq, maxMismatches, d.circular); len(matches) > 0 {
+			hits = append(hits, Hit{Key: d.key, Matches: matches})
+		}
+	}
+	return hits
+}
+
+func TestSearchMatchesFind(t *testing.T) {
+	docs := corpus(200, 2000)
+	ix := indexOf(docs)
+	for _, p := range patterns(docs, 100, 24) {
+		for mm := 0; mm <= 2; mm++ {
+			want := linearFind(docs, p, mm)
+			got := ix.Search(p, mm)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Search(%s, %d) found %d items, Find %d", p, mm, len(got), len(want))
+			}
+		}
+	}
+}
+
+func TestSearchAfterEdits(t *testing.T) {
+	docs := corpus(50, 500)
+	ix := indexOf(docs)
+	// Enough saves to compact the index a few times over.
+	r := rand.New(rand.NewSource(3))
+	for i := 0; i < 5*minCompact; i++ {
+		j := r.Intn(len(docs))
+		docs[j].seq = randomSeq(r, 500)
+		ix.Add(docs[j].key, docs[j].seq, docs[j].circular)
+	}
+	ix.Remove(docs[0].key)
+	docs = docs[1:]
+
+	if len(ix.docs) > len(docs)+minCompact {
+		t.Errorf("index holds %d docs for %d items", len(ix.docs), len(docs))
+	}
+	if ix.Len() != len(docs) {
+		t.Errorf("Len() = %d, want %d", ix.Len(), len(docs))
+	}
+	for _, p := range patterns(docs, 50, 24) {
+		want := linearFind(docs, p, 1)
+		if got := ix.Search(p, 1); !reflect.DeepEqual(got, want) {
+			t.Errorf("Search(%s, 1) found %d items, Find %d", p, len(got), len(want))
+		}
+	}
+}
+
+func BenchmarkIndexFind(b *testing.B) {
+	docs := corpus(2000, 5000)
+	ix := indexOf(docs)
+	ps := patterns(docs, 100, 20)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ix.Search(ps[i%len(ps)], 1)
+	}
+}
+
+func BenchmarkLinearFind(b *testing.B) {
+	docs := corpus(2000, 5000)
+	ps := patterns(docs, 100, 20)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		linearFind(docs, ps[i%len(ps)], 1)
+	}
+}
@@ -0,0 +1,612 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"labdb.org/labdb/auth"
+	"labdb.org/labdb/features"
+	"labdb.org/labdb/formats"
+	"labdb.org/labdb/gel"
+	"labdb.org/labdb/models"
+	"labdb.org/labdb/restriction"
+	"labdb.org/labdb/seqmap"
+	"labdb.org/labdb/sequence"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultMaxMismatches = 2
+
+// sequenceModel looks up the entity named by the :model and :id params like
+// existingModel, additionally requiring that it has a sequence.
+func sequenceModel(c *gin.Context, s *models.Store) (models.Entity, bool) {
+	m, ok := existingModel(c, s)
+	if !ok {
+		return nil, false
+	}
+	if sequence.Normalize(m.GetSequence()) == "" {
+		c.String(400, "No sequence")
+		c.Abort()
+		return nil, false
+	}
+	return m, true
+}
+
+// intQuery parses an optional non-negative integer query parameter.
+func intQuery(c *gin.Context, name string, def int) (int, bool) {
+	v, err := strconv.Atoi(c.DefaultQuery(name, strconv.Itoa(def)))
+	if err != nil || v < 0 {
+		c.String(400, "Bad %s", name)
+		c.Abort()
+		return 0, false
+	}
+	return v, true
+}
+
+func templateInfo(m models.Entity) gin.H {
+	return gin.H{
+		"type":     models.KindOf(m),
+		"id":       m.GetID(),
+		"name":     models.NameOf(m),
+		"length":   len(sequence.Normalize(m.GetSequence())),
+		"circular": m.IsCircular(),
+	}
+}
+
+// exportFormat splits a file extension naming an export format off id.
+func exportFormat(id string) (string, string) {
+	for _, ext := range []string{".gb", ".fasta"} {
+		if strings.HasSuffix(id, ext) {
+			return strings.TrimSuffix(id, ext), ext[1:]
+		}
+	}
+	return id, ""
+}
+
+// exportSequence writes the sequence of the item with the given ID as a
+// GenBank or FASTA file.
+func exportSequence(c *gin.Context, s *models.Store, id string, format string) {
+	m, ok := modelWithID(c, s, id)
+	if !ok {
+		return
+	}
+	if m.SequenceFieldName() == "" {
+		c.String(400, "No sequence")
+		return
+	}
+	seq := sequence.Normalize(m.GetSequence())
+	name := strings.Replace(models.NameOf(m), " ", "_", -1)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+format))
+	if format == "fasta" {
+		c.Header("Content-Type", "text/x-fasta; charset=utf-8")
+		formats.WriteFASTA(c.Writer, []formats.Record{{Name: name, Description: m.ShortDesc(), Sequence: seq}})
+		return
+	}
+	annotations, err := s.Annotations(c.Request.Context(), m)
+	if err != nil {
+		c.AbortWithError(500, err)
+		return
+	}
+	gb := &formats.GenBank{
+		Locus:      name,
+		Circular:   m.IsCircular(),
+		Definition: m.ShortDesc(),
+		Sequence:   seq,
+	}
+	for _, a := range annotations {
+		gb.Features = append(gb.Features, a.Feature(len(seq)))
+	}
+	c.Header("Content-Type", "text/x-genbank; charset=utf-8")
+	formats.WriteGenBank(c.Writer, gb)
+}
+
+// importedFile reads an uploaded sequence file, sent either as the "file"
+// field of a multipart form or as the request body.
+func importedFile(c *gin.Context) ([]byte, error) {
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		f, _, err := c.Request.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return ioutil.ReadAll(f)
+	}
+	return ioutil.ReadAll(c.Request.Body)
+}
+
+// parseSequenceFile parses a GenBank or FASTA file holding a single sequence.
+func parseSequenceFile(r io.Reader, sniff []byte) (*formats.GenBank, error) {
+	if bytes.HasPrefix(sniff, []byte(">")) {
+		records, err := formats.ParseFASTA(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) > 1 {
+			return nil, fmt.Errorf("expected a single sequence, got %d", len(records))
+		}
+		rec := records[0]
+		return &formats.GenBank{Locus: rec.Name, Definition: rec.Description, Sequence: rec.Sequence}, nil
+	}
+	if bytes.HasPrefix(sniff, []byte("LOCUS")) {
+		return formats.ParseGenBank(r)
+	}
+	return nil, fmt.Errorf("not a GenBank or FASTA file")
+}
+
+// queryEnzymes looks up the enzymes named by the enzyme query params.
+func queryEnzymes(c *gin.Context) ([]*restriction.Enzyme, bool) {
+	enzymes := []*restriction.Enzyme{}
+	for _, name := range c.QueryArray("enzyme") {
+		e, ok := restriction.Lookup(name)
+		if !ok {
+			c.String(400, "Unknown enzyme %s", name)
+			c.Abort()
+			return nil, false
+		}
+		enzymes = append(enzymes, e)
+	}
+	return enzymes, true
+}
+
+// gelLane works out the bands in a lane of a virtual gel described by spec,
+// which is one of
+//
+//	model:id                      the whole sequence, uncut
+//	model:id:digest:EcoRI,BamHI   a digest
+//	model:id:pcr:12,13            the PCR products of oligos 12 and 13
+//
+// It writes an error response and returns false if spec is bad.
+func gelLane(c *gin.Context, s *models.Store, spec string) (gel.Lane, bool) {
+	parts := strings.Split(spec, ":")
+	if len(parts) != 2 && len(parts) != 4 {
+		c.String(400, "Bad lane %q", spec)
+		return gel.Lane{}, false
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		c.String(400, "Bad lane %q", spec)
+		return gel.Lane{}, false
+	}
+	m, err := s.GetByID(c.Request.Context(), parts[0], id)
+	if err == models.ErrNotFound {
+		c.String(404, "No %s %d", parts[0], id)
+		return gel.Lane{}, false
+	}
+	if err != nil {
+		c.AbortWithError(500, err)
+		return gel.Lane{}, false
+	}
+	if !canRead(c, s, models.KindOf(m)) {
+		return gel.Lane{}, false
+	}
+	seq := sequence.Normalize(m.GetSequence())
+	if seq == "" {
+		c.String(400, "%s has no sequence", models.NameOf(m))
+		return gel.Lane{}, false
+	}
+	lane := gel.Lane{Label: models.NameOf(m)}
+	if len(parts) == 2 {
+		lane.Sizes = []int{len(seq)}
+		return lane, true
+	}
+	args := strings.Split(parts[3], ",")
+	lane.Label += " " + strings.Join(args, "+")
+	switch parts[2] {
+	case "digest":
+		enzymes := []*restriction.Enzyme{}
+		for _, name := range args {
+			e, ok := restriction.Lookup(name)
+			if !ok {
+				c.String(400, "Unknown enzyme %s", name)
+				return gel.Lane{}, false
+			}
+			enzymes = append(enzymes, e)
+		}
+		lane.Sizes = restriction.Sizes(restriction.Digest(enzymes, seq, m.IsCircular()))
+	case "pcr":
+		if len(args) != 2 {
+			c.String(400, "PCR lanes need two oligos")
+			return gel.Lane{}, false
+		}
+		if !canRead(c, s, "oligo") {
+			return gel.Lane{}, false
+		}
+		reports := []models.PrimerReport{}
+		for _, arg := range args {
+			oligoID, err := strconv.Atoi(arg)
+			if err != nil {
+				c.String(400, "Bad oligo ID")
+				return gel.Lane{}, false
+			}
+			o, err := s.GetByID(c.Request.Context(), "oligo", oligoID)
+			if err == models.ErrNotFound {
+				c.String(404, "No oligo %d", oligoID)
+				return gel.Lane{}, false
+			}
+			if err != nil {
+				c.AbortWithError(500, err)
+				return gel.Lane{}, false
+			}
+			reports = append(reports, models.AnalyzePrimer(o.(*models.Oligo), m, defaultMaxMismatches))
+		}
+		for _, p := range models.PCRProducts(reports[0], reports[1], m) {
+			lane.Sizes = append(lane.Sizes, p.Size)
+		}
+	default:
+		c.String(400, "Bad lane %q", spec)
+		return gel.Lane{}, false
+	}
+	return lane, true
+}
+
+// defaultMinORFLength is the shortest ORF reported by default, in amino
+// acids.
+const defaultMinORFLength = 75
+
+// codonTable looks up the genetic code named by the table query param.
+func codonTable(c *gin.Context) (*sequence.CodonTable, bool) {
+	id, ok := intQuery(c, "table", sequence.StandardCode.ID)
+	if !ok {
+		return nil, false
+	}
+	table, ok := sequence.CodonTables[id]
+	if !ok {
+		c.String(400, "Unknown codon table %d", id)
+		c.Abort()
+		return nil, false
+	}
+	return table, true
+}
+
+func sequenceAPI(r *gin.Engine, s *models.Store) {
+	apiM := r.Group("/api/v1/m")
+
+	// Creates a new item from a GenBank or FASTA file, keeping any features as
+	// annotations.
+	apiM.POST("/:model/import", func(c *gin.Context) {
+		m := models.Empty(c.Param("model"))
+		if m.SequenceFieldName() == "" {
+			c.String(400, "Can't import sequences as %s", c.Param("model"))
+			return
+		}
+		data, err := importedFile(c)
+		if err != nil {
+			c.String(400, "Bad upload: %s", err.Error())
+			return
+		}
+		data = bytes.TrimSpace(data)
+		gb, err := parseSequenceFile(bytes.NewReader(data), data)
+		if err != nil {
+			c.String(400, "Couldn't read sequence file: %s", err.Error())
+			return
+		}
+		if gb.Sequence == "" {
+			c.String(400, "No sequence")
+			return
+		}
+		u, err := auth.CurrentUser(c, s)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		m.AutoFill(u.Name)
+		models.SetColumn(m, m.SequenceFieldName(), gb.Sequence)
+		models.SetColumn(m, m.ShortDescFieldName(), gb.Locus)
+		models.SetColumn(m, m.DescFieldName(), gb.Definition)
+		annotations := []models.Annotation{}
+		for _, f := range gb.Features {
+			if f.Type == "source" {
+				continue
+			}
+			annotations = append(annotations, models.AnnotationFromFeature(f, len(gb.Sequence)))
+		}
+		if err := s.CreateWith(c.Request.Context(), m, annotations, nil); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(201, models.AsResourceDef(m))
+	})
+
+	// Binding sites of oligos on an item's sequence, and the PCR products of
+	// each pair of them. With no oligo params, every oligo is checked and only
+	// those that bind are reported.
+	apiM.GET("/:model/:id/primers", func(c *gin.Context) {
+		template, ok := sequenceModel(c, s)
+		if !ok {
+			return
+		}
+		mismatches, ok := intQuery(c, "mismatches", defaultMaxMismatches)
+		if !ok || !canRead(c, s, "oligo") {
+			return
+		}
+		oligos := []models.Entity{}
+		for _, idStr := range c.QueryArray("oligo") {
+			id, err := strconv.Atoi(idStr)
+			if err != nil {
+				c.String(400, "Bad oligo ID")
+				return
+			}
+			o, err := s.GetByID(c.Request.Context(), "oligo", id)
+			if err == models.ErrNotFound {
+				c.String(404, "No oligo %d", id)
+				return
+			}
+			if err != nil {
+				c.AbortWithError(500, err)
+				return
+			}
+			oligos = append(oligos, o)
+		}
+		checkAll := len(oligos) == 0
+		if checkAll {
+			var err error
+			oligos, err = s.WithSequences(c.Request.Context(), "oligo")
+			if err != nil {
+				c.AbortWithError(500, err)
+				return
+			}
+		}
+
+		reports := []models.PrimerReport{}
+		for _, o := range oligos {
+			report := models.AnalyzePrimer(o.(*models.Oligo), template, mismatches)
+			if checkAll && len(report.Sites) == 0 {
+				continue
+			}
+			reports = append(reports, report)
+		}
+		products := []models.PCRProduct{}
+		for i := range reports {
+			for j := i + 1; j < len(reports); j++ {
+				products = append(products, models.PCRProducts(reports[i], reports[j], template)...)
+			}
+		}
+		c.JSON(200, gin.H{
+			"template": templateInfo(template),
+			"primers":  reports,
+			"products": products,
+		})
+	})
+
+	// An item's annotations, along with features from the library found in
+	// its sequence that aren't annotated yet.
+	apiM.GET("/:model/:id/features", func(c *gin.Context) {
+		m, ok := sequenceModel(c, s)
+		if !ok {
+			return
+		}
+		annotations, err := s.Annotations(c.Request.Context(), m)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		c.JSON(200, gin.H{
+			"features": annotations,
+			"proposed": models.ProposeAnnotations(m, features.Library, annotations),
+		})
+	})
+
+	// Replaces an item's annotations, e.g. to add accepted proposals. (This
+	// can't be a POST, as POST /:model/new would conflict.)
+	apiM.PUT("/:model/:id/features", func(c *gin.Context) {
+		m, ok := sequenceModel(c, s)
+		if !ok || !canEdit(c, s, m) {
+			return
+		}
+		annotations := []models.Annotation{}
+		if err := json.NewDecoder(c.Request.Body).Decode(&annotations); err != nil {
+			c.String(400, "Bad features: %s", err.Error())
+			return
+		}
+		n := len(sequence.Normalize(m.GetSequence()))
+		maxEnd := n
+		if m.IsCircular() {
+			maxEnd = 2 * n
+		}
+		for i := range annotations {
+			a := &annotations[i]
+			if a.Start < 0 || a.Start >= n || a.End < a.Start || a.End > maxEnd {
+				c.String(400, "Feature %q is outside the sequence", a.Name)
+				return
+			}
+			if a.Qualifiers == "" {
+				a.SetQualifiers(nil)
+			}
+		}
+		if err := s.SetAnnotations(c.Request.Context(), m, annotations); err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		c.JSON(200, annotations)
+	})
+
+	// Where each bundled enzyme cuts an item's sequence, and which cut it
+	// once.
+	apiM.GET("/:model/:id/sites", func(c *gin.Context) {
+		m, ok := sequenceModel(c, s)
+		if !ok {
+			return
+		}
+		seq := sequence.Normalize(m.GetSequence())
+		sites := map[string][]restriction.Cut{}
+		unique := []string{}
+		for i := range restriction.Enzymes {
+			e := &restriction.Enzymes[i]
+			cuts := restriction.Cuts(e, seq, m.IsCircular())
+			if len(cuts) == 0 {
+				continue
+			}
+			sites[e.Name] = cuts
+			if len(cuts) == 1 {
+				unique = append(unique, e.Name)
+			}
+		}
+		c.JSON(200, gin.H{
+			"template":      templateInfo(m),
+			"sites":         sites,
+			"uniqueCutters": unique,
+		})
+	})
+
+	// The fragments from digesting an item's sequence with one or more
+	// enzymes, e.g. ?enzyme=EcoRI&enzyme=BamHI for a double digest.
+	apiM.GET("/:model/:id/digest", func(c *gin.Context) {
+		m, ok := sequenceModel(c, s)
+		if !ok {
+			return
+		}
+		enzymes, ok := queryEnzymes(c)
+		if !ok {
+			return
+		}
+		if len(enzymes) == 0 {
+			c.String(400, "No enzymes")
+			return
+		}
+		seq := sequence.Normalize(m.GetSequence())
+		fragments := restriction.Digest(enzymes, seq, m.IsCircular())
+		c.JSON(200, gin.H{
+			"template":  templateInfo(m),
+			"enzymes":   enzymes,
+			"fragments": fragments,
+			"sizes":     restriction.Sizes(fragments),
+		})
+	})
+
+	// A virtual gel with a lane for each lane param (see gelLane), for
+	// embedding in pages as an image.
+	r.GET("/api/v1/gel.svg", func(c *gin.Context) {
+		ladder, ok := gel.Ladders[c.DefaultQuery("ladder", gel.DefaultLadder)]
+		if !ok {
+			c.String(400, "Unknown ladder")
+			return
+		}
+		lanes := []gel.Lane{}
+		for _, spec := range c.QueryArray("lane") {
+			lane, ok := gelLane(c, s, spec)
+			if !ok {
+				return
+			}
+			lanes = append(lanes, lane)
+		}
+		c.Header("Content-Type", "image/svg+xml")
+		gel.Render(c.Writer, ladder, lanes)
+	})
+
+	// A map of an item's sequence with its annotations and either the unique
+	// cutters or the enzymes given as enzyme params.
+	apiM.GET("/:model/:id/map.svg", func(c *gin.Context) {
+		m, ok := sequenceModel(c, s)
+		if !ok {
+			return
+		}
+		enzymes, ok := queryEnzymes(c)
+		if !ok {
+			return
+		}
+		annotations, err := s.Annotations(c.Request.Context(), m)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		seq := sequence.Normalize(m.GetSequence())
+		if len(enzymes) == 0 {
+			enzymes = restriction.UniqueCutters(restriction.Enzymes, seq, m.IsCircular())
+		}
+		seqMap := &seqmap.Map{
+			Name:     models.NameOf(m),
+			Length:   len(seq),
+			Circular: m.IsCircular(),
+			Features: []seqmap.Feature{},
+			Sites:    []seqmap.Site{},
+		}
+		for _, a := range annotations {
+			seqMap.Features = append(seqMap.Features, seqmap.Feature{Name: a.Name, Type: a.Type, Start: a.Start, End: a.End, Strand: a.Strand})
+		}
+		for _, e := range enzymes {
+			for _, cut := range restriction.Cuts(e, seq, m.IsCircular()) {
+				seqMap.Sites = append(seqMap.Sites, seqmap.Site{Name: e.Name, Position: cut.Position})
+			}
+		}
+		c.Header("Content-Type", "image/svg+xml")
+		seqmap.Render(c.Writer, seqMap)
+	})
+
+	// Open reading frames in all six frames of an item's sequence.
+	apiM.GET("/:model/:id/orfs", func(c *gin.Context) {
+		m, ok := sequenceModel(c, s)
+		if !ok {
+			return
+		}
+		table, ok := codonTable(c)
+		if !ok {
+			return
+		}
+		minLength, ok := intQuery(c, "min", defaultMinORFLength)
+		if !ok {
+			return
+		}
+		seq := sequence.Normalize(m.GetSequence())
+		c.JSON(200, gin.H{
+			"template": templateInfo(m),
+			"table":    table,
+			"orfs":     sequence.FindORFs(seq, m.IsCircular(), table, minLength, c.Query("alt") == "1"),
+		})
+	})
+
+	// The translation of a region of an item's sequence (by default all of
+	// it) on either strand.
+	apiM.GET("/:model/:id/translation", func(c *gin.Context) {
+		m, ok := sequenceModel(c, s)
+		if !ok {
+			return
+		}
+		table, ok := codonTable(c)
+		if !ok {
+			return
+		}
+		seq := sequence.Normalize(m.GetSequence())
+		start, ok := intQuery(c, "start", 0)
+		if !ok {
+			return
+		}
+		end, ok := intQuery(c, "end", len(seq))
+		if !ok {
+			return
+		}
+		maxEnd := len(seq)
+		if m.IsCircular() {
+			maxEnd = start + len(seq)
+		}
+		if start >= len(seq) || end < start || end > maxEnd {
+			c.String(400, "Bad region")
+			return
+		}
+		region := (seq + seq)[start:end]
+		// A + in a query string is a space, so take numbers too.
+		strand := sequence.Forward
+		switch strings.TrimSpace(c.Query("strand")) {
+		case "", "+", "1":
+		case "-", "-1":
+			strand = sequence.Reverse
+			region = sequence.ReverseComplement(region)
+		default:
+			c.String(400, "Bad strand")
+			return
+		}
+		c.JSON(200, gin.H{
+			"template": templateInfo(m),
+			"table":    table,
+			"start":    start,
+			"end":      end,
+			"strand":   strand,
+			"protein":  table.Translate(region),
+		})
+	})
+}
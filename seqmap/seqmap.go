@@ -0,0 +1,261 @@
+// Package seqmap draws maps of sequences, with their features and
+// restriction sites, as SVG.
+package seqmap
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"sort"
+)
+
+// Feature is an annotated region to draw, with the same conventions as
+// models.Annotation.
+type Feature struct {
+	Name   string
+	Type   string
+	Start  int
+	End    int
+	Strand int
+}
+
+// Site is a restriction site, drawn at the position the top strand is cut.
+type Site struct {
+	Name     string
+	Position int
+}
+
+type Map struct {
+	Name     string
+	Length   int
+	Circular bool
+	Features []Feature
+	Sites    []Site
+}
+
+var typeColors = map[string]string{
+	"CDS":          "#e6855f",
+	"gene":         "#e6855f",
+	"promoter":     "#8fce6e",
+	"terminator":   "#d64f4f",
+	"rep_origin":   "#f2d36b",
+	"primer_bind":  "#9aa6b2",
+	"protein_bind": "#6ba7d6",
+}
+
+func color(featureType string) string {
+	if c, ok := typeColors[featureType]; ok {
+		return c
+	}
+	return "#b58fd6"
+}
+
+// tickStep picks a round interval (1, 2 or 5 times a power of 10) giving
+// about ten ticks along a sequence of length n.
+func tickStep(n int) int {
+	step := 1
+	for {
+		for _, m := range []int{1, 2, 5} {
+			if n/(step*m) <= 10 {
+				return step * m
+			}
+		}
+		step *= 10
+	}
+}
+
+// tracks assigns features to tracks so those in the same track don't
+// overlap, returning the track of each and the number of tracks.
+func tracks(features []Feature) ([]int, int) {
+	order := make([]int, len(features))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return features[order[a]].Start < features[order[b]].Start })
+	assigned := make([]int, len(features))
+	ends := []int{}
+	for _, i := range order {
+		f := features[i]
+		track := -1
+		for t, end := range ends {
+			if f.Start >= end {
+				track = t
+				break
+			}
+		}
+		if track < 0 {
+			track = len(ends)
+			ends = append(ends, 0)
+		}
+		ends[track] = f.End
+		assigned[i] = track
+	}
+	return assigned, len(ends)
+}
+
+// Render draws m to w.
+func Render(w io.Writer, m *Map) error {
+	bw := bufio.NewWriter(w)
+	if m.Circular {
+		renderCircular(bw, m)
+	} else {
+		renderLinear(bw, m)
+	}
+	return bw.Flush()
+}
+
+func svgHeader(w io.Writer, width, height int) {
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`+"\n", width, height, width, height)
+}
+
+const (
+	circleSize   = 600
+	circleRadius = 170
+	trackWidth   = 14
+	trackGap     = 4
+)
+
+func renderCircular(w io.Writer, m *Map) {
+	cx, cy := float64(circleSize)/2, float64(circleSize)/2
+	n := float64(m.Length)
+	angle := func(pos int) float64 {
+		return 2*math.Pi*float64(pos)/n - math.Pi/2
+	}
+	point := func(pos int, r float64) (float64, float64) {
+		a := angle(pos)
+		return cx + r*math.Cos(a), cy + r*math.Sin(a)
+	}
+
+	svgHeader(w, circleSize, circleSize)
+	fmt.Fprintf(w, `<text x="%.1f" y="%.1f" text-anchor="middle" font-size="16" font-weight="bold">%s</text>`+"\n", cx, cy-4, html.EscapeString(m.Name))
+	fmt.Fprintf(w, `<text x="%.1f" y="%.1f" text-anchor="middle">%d bp</text>`+"\n", cx, cy+14, m.Length)
+	fmt.Fprintf(w, `<circle cx="%.1f" cy="%.1f" r="%d" fill="none" stroke="#333" stroke-width="2"/>`+"\n", cx, cy, circleRadius)
+
+	// Size ticks inside the backbone.
+	step := tickStep(m.Length)
+	for pos := 0; pos < m.Length; pos += step {
+		x1, y1 := point(pos, circleRadius)
+		x2, y2 := point(pos, circleRadius-6)
+		tx, ty := point(pos, circleRadius-18)
+		fmt.Fprintf(w, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#333"/>`+"\n", x1, y1, x2, y2)
+		fmt.Fprintf(w, `<text x="%.1f" y="%.1f" text-anchor="middle" dominant-baseline="middle" font-size="9" fill="#555">%d</text>`+"\n", tx, ty, pos)
+	}
+
+	// Features as arrows in tracks outside the backbone.
+	assigned, _ := tracks(m.Features)
+	for i, f := range m.Features {
+		inner := float64(circleRadius + trackGap + assigned[i]*(trackWidth+trackGap))
+		outer := inner + trackWidth
+		mid := (inner + outer) / 2
+		start, end := f.Start, f.End
+		if end-start >= m.Length {
+			end = start + m.Length - 1
+		}
+		// Leave room for the arrowhead, unless the feature is tiny.
+		head := int(math.Min(float64(end-start)/2, n*0.012))
+		bodyStart, bodyEnd := start, end
+		if f.Strand > 0 {
+			bodyEnd -= head
+		} else if f.Strand < 0 {
+			bodyStart += head
+		}
+		large := 0
+		if float64(bodyEnd-bodyStart) > n/2 {
+			large = 1
+		}
+		ox1, oy1 := point(bodyStart, outer)
+		ox2, oy2 := point(bodyEnd, outer)
+		ix2, iy2 := point(bodyEnd, inner)
+		ix1, iy1 := point(bodyStart, inner)
+		path := fmt.Sprintf("M%.1f,%.1f A%.1f,%.1f 0 %d 1 %.1f,%.1f ", ox1, oy1, outer, outer, large, ox2, oy2)
+		if f.Strand > 0 {
+			tx, ty := point(end, mid)
+			path += fmt.Sprintf("L%.1f,%.1f ", tx, ty)
+		}
+		path += fmt.Sprintf("L%.1f,%.1f A%.1f,%.1f 0 %d 0 %.1f,%.1f ", ix2, iy2, inner, inner, large, ix1, iy1)
+		if f.Strand < 0 {
+			tx, ty := point(start, mid)
+			path += fmt.Sprintf("L%.1f,%.1f ", tx, ty)
+		}
+		path += "Z"
+		fmt.Fprintf(w, `<path d="%s" fill="%s" stroke="#333" stroke-width="0.5"><title>%s (%d..%d)</title></path>`+"\n",
+			path, color(f.Type), html.EscapeString(f.Name), f.Start+1, f.End)
+		lx, ly := point((start+end)/2, outer+12)
+		anchor := "start"
+		if lx < cx {
+			anchor = "end"
+		}
+		fmt.Fprintf(w, `<text x="%.1f" y="%.1f" text-anchor="%s" dominant-baseline="middle">%s</text>`+"\n", lx, ly, anchor, html.EscapeString(f.Name))
+	}
+
+	// Restriction sites as lines through the backbone, labelled inside the
+	// ticks.
+	for _, s := range m.Sites {
+		x1, y1 := point(s.Position, circleRadius-3)
+		x2, y2 := point(s.Position, circleRadius+3)
+		tx, ty := point(s.Position, circleRadius-34)
+		fmt.Fprintf(w, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#c00" stroke-width="1.5"/>`+"\n", x1, y1, x2, y2)
+		fmt.Fprintf(w, `<text x="%.1f" y="%.1f" text-anchor="middle" dominant-baseline="middle" font-size="9" fill="#c00">%s (%d)</text>`+"\n", tx, ty, html.EscapeString(s.Name), s.Position)
+	}
+	fmt.Fprintln(w, "</svg>")
+}
+
+const (
+	linearWidth = 800
+	linearLeft  = 40
+	linearRight = 40
+)
+
+func renderLinear(w io.Writer, m *Map) {
+	span := float64(linearWidth - linearLeft - linearRight)
+	x := func(pos int) float64 {
+		return linearLeft + span*float64(pos)/float64(m.Length)
+	}
+	assigned, numTracks := tracks(m.Features)
+	siteSpace := 0
+	if len(m.Sites) > 0 {
+		siteSpace = 40
+	}
+	axis := 50 + siteSpace
+	height := axis + 40 + numTracks*(trackWidth+trackGap+14)
+
+	svgHeader(w, linearWidth, height)
+	fmt.Fprintf(w, `<text x="%d" y="20" font-size="16" font-weight="bold">%s</text>`+"\n", linearLeft, html.EscapeString(fmt.Sprintf("%s (%d bp)", m.Name, m.Length)))
+	fmt.Fprintf(w, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#333" stroke-width="2"/>`+"\n", x(0), axis, x(m.Length), axis)
+
+	step := tickStep(m.Length)
+	for pos := 0; pos <= m.Length; pos += step {
+		fmt.Fprintf(w, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#333"/>`+"\n", x(pos), axis, x(pos), axis+5)
+		fmt.Fprintf(w, `<text x="%.1f" y="%d" text-anchor="middle" font-size="9" fill="#555">%d</text>`+"\n", x(pos), axis+15, pos)
+	}
+
+	for i, s := range m.Sites {
+		// Stagger labels so neighbouring sites don't collide.
+		ly := axis - 8 - (i%3)*11
+		fmt.Fprintf(w, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#c00" stroke-width="1.5"/>`+"\n", x(s.Position), axis-4, x(s.Position), ly+2)
+		fmt.Fprintf(w, `<text x="%.1f" y="%d" text-anchor="middle" font-size="9" fill="#c00">%s</text>`+"\n", x(s.Position), ly, html.EscapeString(s.Name))
+	}
+
+	for i, f := range m.Features {
+		top := float64(axis + 24 + assigned[i]*(trackWidth+trackGap+14))
+		bottom := top + trackWidth
+		mid := (top + bottom) / 2
+		x1, x2 := x(f.Start), x(f.End)
+		head := math.Min((x2-x1)/2, 8)
+		var points string
+		switch {
+		case f.Strand > 0:
+			points = fmt.Sprintf("%.1f,%.1f %.1f,%.1f %.1f,%.1f %.1f,%.1f %.1f,%.1f", x1, top, x2-head, top, x2, mid, x2-head, bottom, x1, bottom)
+		case f.Strand < 0:
+			points = fmt.Sprintf("%.1f,%.1f %.1f,%.1f %.1f,%.1f %.1f,%.1f %.1f,%.1f", x1+head, top, x2, top, x2, bottom, x1+head, bottom, x1, mid)
+		default:
+			points = fmt.Sprintf("%.1f,%.1f %.1f,%.1f %.1f,%.1f %.1f,%.1f", x1, top, x2, top, x2, bottom, x1, bottom)
+		}
+		fmt.Fprintf(w, `<polygon points="%s" fill="%s" stroke="#333" stroke-width="0.5"><title>%s (%d..%d)</title></polygon>`+"\n",
+			points, color(f.Type), html.EscapeString(f.Name), f.Start+1, f.End)
+		fmt.Fprintf(w, `<text x="%.1f" y="%.1f" text-anchor="middle" font-size="10">%s</text>`+"\n", (x1+x2)/2, bottom+11, html.EscapeString(f.Name))
+	}
+	fmt.Fprintln(w, "</svg>")
+}
@@ -0,0 +1,58 @@
+package sequence
+
+type Strand string
+
+const (
+	Forward Strand = "+"
+	Reverse Strand = "-"
+)
+
+// Match is a place where a pattern matched a target sequence. Start and End
+// are 0-based, end-exclusive positions on the forward strand of the target.
+// On circular targets a match can span the origin, in which case End is past
+// the end of the target.
+type Match struct {
+	Start      int    `json:"start"`
+	End        int    `json:"end"`
+	Strand     Strand `json:"strand"`
+	Mismatches int    `json:"mismatches"`
+}
+
+// Find returns all matches of pattern to either strand of target with at most
+// maxMismatches mismatches, ordered by position. Both should be normalized;
+// IUPAC ambiguity codes in either match any base they could stand for.
+func Find(pattern, target string, maxMismatches int, circular bool) []Match {
+	matches := []Match{}
+	if len(pattern) == 0 || len(pattern) > len(target) {
+		return matches
+	}
+	searched := target
+	if circular {
+		searched = target + target[:len(pattern)-1]
+	}
+	rc := ReverseComplement(pattern)
+	for i := 0; i+len(pattern) <= len(searched); i++ {
+		if mm, ok := mismatchesAt(pattern, searched, i, maxMismatches); ok {
+			matches = append(matches, Match{Start: i, End: i + len(pattern), Strand: Forward, Mismatches: mm})
+		}
+		if mm, ok := mismatchesAt(rc, searched, i, maxMismatches); ok {
+			matches = append(matches, Match{Start: i, End: i + len(pattern), Strand: Reverse, Mismatches: mm})
+		}
+	}
+	return matches
+}
+
+// mismatchesAt counts the mismatches of pattern against target at offset,
+// giving up once there are more than max.
+func mismatchesAt(pattern, target string, offset int, max int) (int, bool) {
+	mm := 0
+	for j := 0; j < len(pattern); j++ {
+		if !Compatible(pattern[j], target[offset+j]) {
+			mm++
+			if mm > max {
+				return mm, false
+			}
+		}
+	}
+	return mm, true
+}
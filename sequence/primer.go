@@ -0,0 +1,212 @@
+package sequence
+
+import (
+	"math"
+)
+
+// Conditions used for melting temperatures, matching typical PCR setups.
+const (
+	SaltConc   = 0.05    // M Na+
+	PrimerConc = 250e-9  // M
+	gasConst   = 1.98720 // cal/(K mol)
+)
+
+// MinPrimingLength is how many bases at the 3' end of a primer have to match
+// the template for it to count as extending from a site.
+const MinPrimingLength = 12
+
+// nnParams are the SantaLucia (1998) unified nearest-neighbor parameters for
+// each dinucleotide (read 5'->3'), as {dH kcal/mol, dS cal/(K mol)}.
+var nnParams = map[string][2]float64{
+	"AA": {-7.9, -22.2}, "TT": {-7.9, -22.2},
+	"AT": {-7.2, -20.4},
+	"TA": {-7.2, -21.3},
+	"CA": {-8.5, -22.7}, "TG": {-8.5, -22.7},
+	"GT": {-8.4, -22.4}, "AC": {-8.4, -22.4},
+	"CT": {-7.8, -21.0}, "AG": {-7.8, -21.0},
+	"GA": {-8.2, -22.2}, "TC": {-8.2, -22.2},
+	"CG": {-10.6, -27.2},
+	"GC": {-9.8, -24.4},
+	"GG": {-8.0, -19.9}, "CC": {-8.0, -19.9},
+}
+
+func terminalParams(b byte) (float64, float64) {
+	if b == 'G' || b == 'C' {
+		return 0.1, -2.8
+	}
+	return 2.3, 4.1
+}
+
+// MeltingTemp estimates the melting temperature in °C of a normalized primer
+// annealed to its perfect complement, using the nearest-neighbor model at
+// SaltConc and PrimerConc. Steps involving ambiguous bases are skipped.
+func MeltingTemp(s string) float64 {
+	if len(s) < 2 {
+		return 0
+	}
+	dH, dS := terminalParams(s[0])
+	h, e := terminalParams(s[len(s)-1])
+	dH += h
+	dS += e
+	for i := 0; i+1 < len(s); i++ {
+		if p, ok := nnParams[s[i:i+2]]; ok {
+			dH += p[0]
+			dS += p[1]
+		}
+	}
+	ct := PrimerConc / 4
+	if s == ReverseComplement(s) {
+		// Self-complementary sequences pair with themselves.
+		dS += -1.4
+		ct = PrimerConc
+	}
+	dS += 0.368 * float64(len(s)-1) * math.Log(SaltConc)
+	return dH*1000/(dS+gasConst*math.Log(ct)) - 273.15
+}
+
+// GCContent returns the fraction of unambiguous bases in s that are G or C.
+func GCContent(s string) float64 {
+	gc := 0
+	total := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case 'G', 'C', 'S':
+			gc++
+			total++
+		case 'A', 'T', 'W':
+			total++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(gc) / float64(total)
+}
+
+// BindingSite is a place a primer anneals to a template. Start and End cover
+// the whole primer (including any 5' tail that doesn't match) in forward
+// strand positions; as with Match, End may run past the end of a circular
+// template. On the reverse strand the primer's 3' end is at Start.
+type BindingSite struct {
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	Strand Strand `json:"strand"`
+	// Mismatches counts unpaired bases along the whole primer, including any
+	// 5' tail.
+	Mismatches int `json:"mismatches"`
+	// ThreePrimeMatch is the number of consecutive bases matching the template
+	// at the primer's 3' end.
+	ThreePrimeMatch int `json:"threePrimeMatch"`
+}
+
+// Primes reports whether the primer can be extended from this site.
+func (b BindingSite) Primes() bool {
+	return b.ThreePrimeMatch >= MinPrimingLength
+}
+
+// BindingSites finds where primer anneals to either strand of template. A site
+// is anywhere the last MinPrimingLength bases of the primer (or all of it, if
+// it's shorter) match with at most maxMismatches mismatches. Both sequences
+// should be normalized.
+func BindingSites(primer, template string, circular bool, maxMismatches int) []BindingSite {
+	sites := []BindingSite{}
+	anchorLen := MinPrimingLength
+	if len(primer) < anchorLen {
+		anchorLen = len(primer)
+	}
+	anchor := primer[len(primer)-anchorLen:]
+	n := len(template)
+	at := func(i int) (byte, bool) {
+		if circular {
+			return template[((i%n)+n)%n], true
+		}
+		if i < 0 || i >= n {
+			return 0, false
+		}
+		return template[i], true
+	}
+	rc := ReverseComplement(primer)
+
+	for _, m := range Find(anchor, template, maxMismatches, circular) {
+		site := BindingSite{Strand: m.Strand}
+		if m.Strand == Forward {
+			// The primer reads along the template, ending at the anchor.
+			site.Start = m.End - len(primer)
+			site.End = m.End
+			for j := len(primer) - 1; j >= 0; j-- {
+				b, ok := at(site.Start + j)
+				if !ok || !Compatible(primer[j], b) {
+					site.Mismatches++
+					continue
+				}
+				if site.ThreePrimeMatch == len(primer)-1-j {
+					site.ThreePrimeMatch++
+				}
+			}
+		} else {
+			// The primer anneals to the top strand in reverse complement,
+			// starting from its 3' end at the anchor.
+			site.Start = m.Start
+			site.End = m.Start + len(primer)
+			for j := 0; j < len(rc); j++ {
+				b, ok := at(site.Start + j)
+				if !ok || !Compatible(rc[j], b) {
+					site.Mismatches++
+					continue
+				}
+				if site.ThreePrimeMatch == j {
+					site.ThreePrimeMatch++
+				}
+			}
+		}
+		if circular && site.Start < 0 {
+			site.Start += n
+			site.End += n
+		}
+		sites = append(sites, site)
+	}
+	return sites
+}
+
+// Product is a predicted PCR product between two primer binding sites.
+type Product struct {
+	Forward BindingSite `json:"forward"`
+	Reverse BindingSite `json:"reverse"`
+	// Start and End are the product's extent on the template's forward
+	// strand. On circular templates End may be past the end of the template.
+	Start int `json:"start"`
+	End   int `json:"end"`
+	Size  int `json:"size"`
+}
+
+// Products predicts the PCR products from one primer's sites on the forward
+// strand of a template of length n to another's downstream sites on the
+// reverse strand. Sites that can't prime are ignored.
+func Products(forward, reverse []BindingSite, n int, circular bool) []Product {
+	products := []Product{}
+	for _, f := range forward {
+		if f.Strand != Forward || !f.Primes() {
+			continue
+		}
+		for _, r := range reverse {
+			if r.Strand != Reverse || !r.Primes() {
+				continue
+			}
+			end := r.End
+			if end < f.End {
+				if !circular {
+					continue
+				}
+				end += n
+			}
+			products = append(products, Product{
+				Forward: f,
+				Reverse: r,
+				Start:   f.Start,
+				End:     end,
+				Size:    end - f.Start,
+			})
+		}
+	}
+	return products
+}
@@ -0,0 +1,90 @@
+package sequence
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Bases are represented as bitmasks of the unambiguous bases they stand for,
+// so that two IUPAC codes are compatible if their masks intersect.
+const (
+	baseA byte = 1 << iota
+	baseC
+	baseG
+	baseT
+)
+
+var iupacMasks = map[byte]byte{
+	'A': baseA,
+	'C': baseC,
+	'G': baseG,
+	'T': baseT,
+	'U': baseT,
+	'R': baseA | baseG,
+	'Y': baseC | baseT,
+	'S': baseC | baseG,
+	'W': baseA | baseT,
+	'K': baseG | baseT,
+	'M': baseA | baseC,
+	'B': baseC | baseG | baseT,
+	'D': baseA | baseG | baseT,
+	'H': baseA | baseC | baseT,
+	'V': baseA | baseC | baseG,
+	'N': baseA | baseC | baseG | baseT,
+}
+
+// maskTable is iupacMasks as an array, for the inner loops of matching.
+var maskTable [256]byte
+
+func init() {
+	for c, mask := range iupacMasks {
+		maskTable[c] = mask
+	}
+}
+
+var complements = map[byte]byte{
+	'A': 'T', 'C': 'G', 'G': 'C', 'T': 'A', 'U': 'A',
+	'R': 'Y', 'Y': 'R', 'S': 'S', 'W': 'W', 'K': 'M', 'M': 'K',
+	'B': 'V', 'V': 'B', 'D': 'H', 'H': 'D', 'N': 'N',
+}
+
+// Normalize strips everything but letters from a stored sequence (whitespace,
+// line numbers and so on) and upper cases it.
+func Normalize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if !unicode.IsLetter(r) || r > unicode.MaxASCII {
+			return -1
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
+
+// IsDNA reports whether s, which should already be normalized, consists only
+// of IUPAC nucleotide codes.
+func IsDNA(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if _, ok := iupacMasks[s[i]]; !ok {
+			return false
+		}
+	}
+	return len(s) > 0
+}
+
+// Compatible reports whether two IUPAC codes can stand for the same base.
+func Compatible(a, b byte) bool {
+	return maskTable[a]&maskTable[b] != 0
+}
+
+// ReverseComplement returns the reverse complement of a normalized sequence.
+// Characters that aren't nucleotide codes are complemented to N.
+func ReverseComplement(s string) string {
+	out := make([]byte, len(s))
+	for i := 0; i < len(s); i++ {
+		c, ok := complements[s[i]]
+		if !ok {
+			c = 'N'
+		}
+		out[len(s)-1-i] = c
+	}
+	return string(out)
+}
@@ -0,0 +1,215 @@
+package sequence
+
+import (
+	"sort"
+)
+
+// CodonTable is a genetic code in the format of the NCBI tables: the amino
+// acid and whether it can start translation for each codon, with the bases of
+// each position in the order TCAG.
+type CodonTable struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	AAs    string `json:"-"`
+	Starts string `json:"-"`
+}
+
+// CodonTables are the supported genetic codes, by NCBI table number.
+var CodonTables = map[int]*CodonTable{
+	1: {
+		ID:     1,
+		Name:   "Standard",
+		AAs:    "FFLLSSSSYY**CC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG",
+		Starts: "---M------**--*----M---------------M----------------------------",
+	},
+	2: {
+		ID:     2,
+		Name:   "Vertebrate Mitochondrial",
+		AAs:    "FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIMMTTTTNNKKSS**VVVVAAAADDEEGGGG",
+		Starts: "--------------------------------MMMM---------------M------------",
+	},
+	3: {
+		ID:     3,
+		Name:   "Yeast Mitochondrial",
+		AAs:    "FFLLSSSSYY**CCWWTTTTPPPPHHQQRRRRIIMMTTTTNNKKSSRRVVVVAAAADDEEGGGG",
+		Starts: "----------------------------------MM----------------------------",
+	},
+	4: {
+		ID:     4,
+		Name:   "Mold, Protozoan, and Coelenterate Mitochondrial and Mycoplasma/Spiroplasma",
+		AAs:    "FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG",
+		Starts: "--MM------**-------M------------MMMM---------------M------------",
+	},
+	5: {
+		ID:     5,
+		Name:   "Invertebrate Mitochondrial",
+		AAs:    "FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIMMTTTTNNKKSSSSVVVVAAAADDEEGGGG",
+		Starts: "---M----------------------------MMMM---------------M------------",
+	},
+	6: {
+		ID:     6,
+		Name:   "Ciliate, Dasycladacean and Hexamita Nuclear",
+		AAs:    "FFLLSSSSYYQQCC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG",
+		Starts: "-----------------------------------M----------------------------",
+	},
+	11: {
+		ID:     11,
+		Name:   "Bacterial, Archaeal and Plant Plastid",
+		AAs:    "FFLLSSSSYY**CC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG",
+		Starts: "---M------**--*----M------------MMMM---------------M------------",
+	},
+}
+
+// StandardCode is NCBI table 1.
+var StandardCode = CodonTables[1]
+
+func baseIndex(b byte) int {
+	switch b {
+	case 'T', 'U':
+		return 0
+	case 'C':
+		return 1
+	case 'A':
+		return 2
+	case 'G':
+		return 3
+	}
+	return -1
+}
+
+// codon returns the index into the table of the codon at s[i:i+3], or -1 if
+// it has ambiguous bases.
+func codon(s string, i int) int {
+	a, b, c := baseIndex(s[i]), baseIndex(s[i+1]), baseIndex(s[i+2])
+	if a < 0 || b < 0 || c < 0 {
+		return -1
+	}
+	return a*16 + b*4 + c
+}
+
+// Translate translates s from its first base, ignoring any incomplete codon
+// at the end. Stop codons translate to * and codons with ambiguous bases to
+// X.
+func (t *CodonTable) Translate(s string) string {
+	protein := make([]byte, 0, len(s)/3)
+	for i := 0; i+3 <= len(s); i += 3 {
+		if c := codon(s, i); c >= 0 {
+			protein = append(protein, t.AAs[c])
+		} else {
+			protein = append(protein, 'X')
+		}
+	}
+	return string(protein)
+}
+
+// Translate translates s using the standard genetic code.
+func Translate(s string) string {
+	return StandardCode.Translate(s)
+}
+
+// atg is the index of ATG in the tables.
+const atg = 2*16 + 0*4 + 3
+
+func (t *CodonTable) isStart(c int, altStarts bool) bool {
+	if altStarts {
+		return c >= 0 && t.Starts[c] == 'M'
+	}
+	return c == atg
+}
+
+func (t *CodonTable) isStop(c int) bool {
+	return c >= 0 && t.AAs[c] == '*'
+}
+
+// ORF is an open reading frame, from the first base of the start codon to
+// the last of the stop codon, in the same coordinates as Match. Frame is 1,
+// 2 or 3 on the forward strand and -1, -2 or -3 on the reverse, counting from
+// the start of each strand.
+type ORF struct {
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+	Strand  Strand `json:"strand"`
+	Frame   int    `json:"frame"`
+	Length  int    `json:"length"`
+	Protein string `json:"protein"`
+}
+
+// FindORFs finds the open reading frames in all six frames of seq that code
+// for at least minLength amino acids, ordered by position. Only the longest
+// ORF ending at each stop codon is reported. With altStarts, any of the
+// table's start codons can start an ORF, and not just ATG.
+func FindORFs(seq string, circular bool, table *CodonTable, minLength int, altStarts bool) []ORF {
+	orfs := []ORF{}
+	n := len(seq)
+	if n < 3 {
+		return orfs
+	}
+	for _, strand := range []Strand{Forward, Reverse} {
+		s := seq
+		if strand == Reverse {
+			s = ReverseComplement(seq)
+		}
+		for _, o := range strandORFs(s, circular, table, minLength, altStarts) {
+			o.Strand = strand
+			o.Frame = o.Start%3 + 1
+			if strand == Reverse {
+				o.Frame = -o.Frame
+				o.Start, o.End = n-o.End, n-o.Start
+				if o.Start < 0 {
+					o.Start += n
+					o.End += n
+				}
+			}
+			orfs = append(orfs, o)
+		}
+	}
+	sort.SliceStable(orfs, func(i, j int) bool { return orfs[i].Start < orfs[j].Start })
+	return orfs
+}
+
+// strandORFs finds ORFs reading along s.
+func strandORFs(s string, circular bool, table *CodonTable, minLength int, altStarts bool) []ORF {
+	orfs := []ORF{}
+	n := len(s)
+	scanned := s
+	if circular {
+		// Going round three times covers every frame, and ORFs starting
+		// anywhere up to a full turn after the first stop.
+		scanned = s + s + s
+	}
+	seen := map[int]bool{}
+	for frame := 0; frame < 3; frame++ {
+		start := -1
+		// On circular sequences, a start before the first stop might have an
+		// earlier one in frame before the origin, so only look for starts
+		// after the first stop.
+		afterStop := !circular
+		for i := frame; i+3 <= len(scanned); i += 3 {
+			c := codon(scanned, i)
+			if table.isStop(c) {
+				if start >= 0 && i+3-start <= n {
+					length := (i - start) / 3
+					if length >= minLength && !seen[start%n] {
+						seen[start%n] = true
+						protein := []byte(table.Translate(scanned[start:i]))
+						// Alternative start codons are read as methionine.
+						protein[0] = 'M'
+						orfs = append(orfs, ORF{
+							Start:   start % n,
+							End:     start%n + i + 3 - start,
+							Length:  length,
+							Protein: string(protein),
+						})
+					}
+				}
+				start = -1
+				afterStop = true
+				continue
+			}
+			if start < 0 && afterStop && table.isStart(c, altStarts) {
+				start = i
+			}
+		}
+	}
+	return orfs
+}
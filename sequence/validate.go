@@ -0,0 +1,68 @@
+package sequence
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Alphabet is the set of characters a stored sequence may contain.
+type Alphabet struct {
+	Name    string
+	allowed string
+}
+
+var (
+	// IUPAC allows the unambiguous bases, U and the IUPAC ambiguity codes.
+	IUPAC = Alphabet{Name: "IUPAC DNA codes", allowed: "ACGTURYSWKMBDHVN"}
+	// ACGT allows only the four unambiguous bases.
+	ACGT = Alphabet{Name: "A, C, G or T", allowed: "ACGT"}
+)
+
+// InvalidBaseError describes the first character of a sequence that isn't
+// in the alphabet. Position is 1-based.
+type InvalidBaseError struct {
+	Position int
+	Char     rune
+	Alphabet Alphabet
+}
+
+func (e *InvalidBaseError) Error() string {
+	return fmt.Sprintf("%q at position %d isn't a base (expected %s)", e.Char, e.Position, e.Alphabet.Name)
+}
+
+// Validate checks that s, as returned by Clean, only contains characters
+// from the alphabet, in either case.
+func (a Alphabet) Validate(s string) error {
+	for i, r := range []rune(s) {
+		if !strings.ContainsRune(a.allowed, unicode.ToUpper(r)) {
+			return &InvalidBaseError{Position: i + 1, Char: r, Alphabet: a}
+		}
+	}
+	return nil
+}
+
+// Clean strips what tends to come along with a pasted sequence: FASTA header
+// and comment lines, whitespace and position numbers. Unlike Normalize it
+// keeps anything else, so that Validate can reject it, and only upper cases
+// the sequence if fold is set.
+func Clean(s string, fold bool) string {
+	lines := strings.Split(s, "\n")
+	var b strings.Builder
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, ">") || strings.HasPrefix(trimmed, ";") {
+			continue
+		}
+		for _, r := range trimmed {
+			if unicode.IsSpace(r) || unicode.IsDigit(r) {
+				continue
+			}
+			if fold {
+				r = unicode.ToUpper(r)
+			}
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
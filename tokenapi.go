@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"labdb.org/labdb/auth"
+	"labdb.org/labdb/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type tokenRequest struct {
+	Name      string     `json:"name"`
+	ReadOnly  bool       `json:"readOnly"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// tokenUser returns the signed in user for managing API tokens, which has to
+// be done from a browser session rather than with another token. These routes
+// aren't behind requireAuthorization, so this is all the checking they get.
+func tokenUser(c *gin.Context, s *models.Store) (models.User, bool) {
+	if auth.CurrentAPIToken(c) != nil {
+		c.String(403, "API tokens can't be managed with an API token")
+		return models.User{}, false
+	}
+	u, err := auth.CurrentUser(c, s)
+	if err == models.ErrNotFound || (err == nil && u.Deactivated) {
+		c.String(403, "Forbidden")
+		return models.User{}, false
+	}
+	if err != nil {
+		c.AbortWithError(500, err)
+		return models.User{}, false
+	}
+	return u, true
+}
+
+func tokenAPI(r *gin.Engine, s *models.Store) {
+	api := r.Group("/api/v1/tokens")
+
+	api.GET("", func(c *gin.Context) {
+		u, ok := tokenUser(c, s)
+		if !ok {
+			return
+		}
+		ts, err := s.APITokens(c.Request.Context(), u)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		c.JSON(200, ts)
+	})
+
+	// Mints a token. The token itself is only ever returned here.
+	api.POST("", func(c *gin.Context) {
+		u, ok := tokenUser(c, s)
+		if !ok {
+			return
+		}
+		req := tokenRequest{}
+		if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+			c.String(400, "Bad token request: %s", err.Error())
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" {
+			c.String(400, "Tokens need a name")
+			return
+		}
+		if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+			c.String(400, "Expiry is in the past")
+			return
+		}
+		t := &models.APIToken{Name: req.Name, ReadOnly: req.ReadOnly, ExpiresAt: req.ExpiresAt}
+		token, err := s.CreateAPIToken(c.Request.Context(), u, t)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		c.JSON(201, gin.H{"token": token, "apiToken": t})
+	})
+
+	api.DELETE("/:id", func(c *gin.Context) {
+		u, ok := tokenUser(c, s)
+		if !ok {
+			return
+		}
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.String(400, "Bad id")
+			return
+		}
+		err = s.RevokeAPIToken(c.Request.Context(), u, uint(id))
+		if err == models.ErrNotFound {
+			c.String(404, "Not found")
+			return
+		}
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		c.Status(204)
+	})
+}
@@ -47,8 +47,8 @@ var outputFile = "routes/routes.go"
 func genAllRoutesCode() string {
 	code := &bytes.Buffer{}
 	for _, t := range allTypes {
-		fmt.Fprintln(code, "install"+t+"(r)")
-		fmt.Fprintln(code, "install"+pluralize(t)+"(r)")
+		fmt.Fprintln(code, "install"+t+"(r, s)")
+		fmt.Fprintln(code, "install"+pluralize(t)+"(r, s)")
 	}
 	return string(code.Bytes())
 }
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"labdb.org/labdb/auth"
+	"labdb.org/labdb/formats"
+	"labdb.org/labdb/models"
+	"labdb.org/labdb/sanger"
+	"labdb.org/labdb/sequence"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxUploadSize = 32 << 20
+
+// maxReadLength is the longest read accepted. Sanger reads are rarely over
+// 1.5 kb, and aligning one takes memory in proportion to its length times the
+// reference's.
+const maxReadLength = 2000
+
+// fractionParam parses an optional form value between 0 and 1.
+func fractionParam(c *gin.Context, name string, def float64) (float64, bool) {
+	v := c.Request.FormValue(name)
+	if v == "" {
+		return def, true
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 || f > 1 {
+		c.String(400, "Bad %s", name)
+		return 0, false
+	}
+	return f, true
+}
+
+// uploadedReads reads the Sanger reads uploaded as "read" files.
+func uploadedReads(c *gin.Context) ([]formats.Read, bool) {
+	reads := []formats.Read{}
+	for _, fh := range c.Request.MultipartForm.File["read"] {
+		f, err := fh.Open()
+		if err != nil {
+			c.String(400, "Bad upload: %s", err.Error())
+			return nil, false
+		}
+		data, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			c.String(400, "Bad upload: %s", err.Error())
+			return nil, false
+		}
+		rs, err := formats.ParseReads(data, fh.Filename)
+		if err != nil {
+			c.String(400, "Couldn't read %s: %s", fh.Filename, err.Error())
+			return nil, false
+		}
+		for _, r := range rs {
+			if len(r.Sequence) > maxReadLength {
+				c.String(400, "%s is %d bases; reads can be at most %d", r.Name, len(r.Sequence), maxReadLength)
+				return nil, false
+			}
+		}
+		reads = append(reads, rs...)
+	}
+	if len(reads) == 0 {
+		c.String(400, "No reads")
+		return nil, false
+	}
+	return reads, true
+}
+
+// verificationReference returns the sequence reads of m should match: its
+// own, or for items that don't store one (e.g. RNAi clones) one uploaded as
+// a "reference" GenBank or FASTA file.
+func verificationReference(c *gin.Context, m models.Entity) (string, bool, bool) {
+	if seq := sequence.Normalize(m.GetSequence()); seq != "" {
+		return seq, m.IsCircular(), true
+	}
+	files := c.Request.MultipartForm.File["reference"]
+	if len(files) == 0 {
+		c.String(400, "No sequence to check against; upload a reference")
+		return "", false, false
+	}
+	f, err := files[0].Open()
+	if err != nil {
+		c.String(400, "Bad upload: %s", err.Error())
+		return "", false, false
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		c.String(400, "Bad upload: %s", err.Error())
+		return "", false, false
+	}
+	data = bytes.TrimSpace(data)
+	gb, err := parseSequenceFile(bytes.NewReader(data), data)
+	if err != nil {
+		c.String(400, "Couldn't read reference: %s", err.Error())
+		return "", false, false
+	}
+	if gb.Sequence == "" {
+		c.String(400, "No sequence in reference")
+		return "", false, false
+	}
+	return gb.Sequence, gb.Circular, true
+}
+
+func verifyAPI(r *gin.Engine, s *models.Store) {
+	// These live outside /api/v1/m, where a POST to /:model/:id/... would
+	// conflict with /:model/new.
+	api := r.Group("/api/v1/verify")
+
+	// Checks Sanger reads (AB1, SCF or FASTA files uploaded as "read") of an
+	// item against its sequence, optionally limited to start:end. With mark=1
+	// the item is recorded as verified if the reads reach minIdentity and
+	// minCoverage.
+	api.POST("/:model/:id", func(c *gin.Context) {
+		m, ok := existingModel(c, s)
+		if !ok {
+			return
+		}
+		if err := c.Request.ParseMultipartForm(maxUploadSize); err != nil {
+			c.String(400, "Bad upload: %s", err.Error())
+			return
+		}
+		reads, ok := uploadedReads(c)
+		if !ok {
+			return
+		}
+		reference, circular, ok := verificationReference(c, m)
+		if !ok {
+			return
+		}
+		t := sanger.DefaultThresholds
+		if t.MinIdentity, ok = fractionParam(c, "minIdentity", t.MinIdentity); !ok {
+			return
+		}
+		if t.MinCoverage, ok = fractionParam(c, "minCoverage", t.MinCoverage); !ok {
+			return
+		}
+		start, err := strconv.Atoi(c.DefaultPostForm("start", "0"))
+		if err != nil {
+			c.String(400, "Bad start")
+			return
+		}
+		end, err := strconv.Atoi(c.DefaultPostForm("end", strconv.Itoa(len(reference))))
+		if err != nil {
+			c.String(400, "Bad end")
+			return
+		}
+		maxEnd := len(reference)
+		if circular {
+			maxEnd = start + len(reference)
+		}
+		if start < 0 || start >= len(reference) || end <= start || end > maxEnd {
+			c.String(400, "Bad region")
+			return
+		}
+
+		report := sanger.Verify(reference, circular, start, end, reads, t)
+		var verification *models.Verification
+		if c.Request.FormValue("mark") == "1" && report.Passed {
+			if !canEdit(c, s, m) {
+				return
+			}
+			u, err := auth.CurrentUser(c, s)
+			if err != nil {
+				c.AbortWithError(500, err)
+				return
+			}
+			names := []string{}
+			for _, r := range reads {
+				names = append(names, r.Name)
+			}
+			verification = &models.Verification{
+				Reads:      strings.Join(names, ","),
+				Identity:   report.Identity,
+				Coverage:   report.Coverage,
+				VerifiedBy: u.Name,
+			}
+			if err := s.MarkVerified(c.Request.Context(), m, verification); err != nil {
+				c.AbortWithError(500, err)
+				return
+			}
+		}
+		c.JSON(200, gin.H{
+			"item":         models.AsResourceDef(m),
+			"report":       report,
+			"verification": verification,
+		})
+	})
+
+	// The verifications recorded for an item.
+	api.GET("/:model/:id", func(c *gin.Context) {
+		m, ok := existingModel(c, s)
+		if !ok {
+			return
+		}
+		vs, err := s.Verifications(c.Request.Context(), m)
+		if err != nil {
+			c.AbortWithError(500, err)
+			return
+		}
+		c.JSON(200, vs)
+	})
+}